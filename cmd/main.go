@@ -6,11 +6,19 @@ import (
 
 	"szu_market/internal/auth"
 	"szu_market/internal/cart"
+	"szu_market/internal/coupon"
 	"szu_market/internal/db"
 	"szu_market/internal/favorite"
 	"szu_market/internal/info"
+	"szu_market/internal/notify"
 	"szu_market/internal/order"
 	"szu_market/internal/product"
+	"szu_market/internal/qa"
+	"szu_market/internal/recommend"
+	"szu_market/internal/review"
+	"szu_market/internal/seller"
+	"szu_market/internal/sensitive"
+	"szu_market/internal/trending"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -38,11 +46,26 @@ func main() {
 	defer producer.Close()
 
 	// 启动Kafka消费者（并发启动多个消费者）
-	consumerService := order.NewConsumerService(db.DB)
+	consumerService := order.NewConsumerService(db.DB, producer)
 
 	// 启动所有消费者（后台运行）
 	go consumerService.StartConsumers()
 
+	// 定时刷新热门商品榜单（后台运行）
+	trendingService := trending.NewTrendingService(db.DB)
+	go trendingService.StartScheduler()
+
+	// 定时重算"买了又买"推荐（后台运行）
+	recommendService := recommend.NewRecommendService(db.DB)
+	go recommendService.StartScheduler()
+
+	// 定时对账购物车缓存与数据库（后台运行）
+	cartService := cart.NewCartService(db.DB)
+	go cartService.StartReconciler()
+
+	// 定时提醒用户结算闲置的购物车（后台运行）
+	go cartService.StartReminderScheduler()
+
 	r := gin.Default()
 	// 配置CORS（更安全的配置）
 	r.Use(cors.New(cors.Config{
@@ -74,4 +97,20 @@ func registerAllRoutes(r *gin.Engine, db *gorm.DB, producer *order.KafkaProducer
 	info.RegisterInfoRoutes(r, db)
 	// 注册收藏路由
 	favorite.RegisterFavoriteRoutes(r, db)
+	// 注册评价路由
+	review.RegisterReviewRoutes(r, db)
+	// 注册热门榜单路由
+	trending.RegisterTrendingRoutes(r, db)
+	// 注册推荐路由
+	recommend.RegisterRecommendRoutes(r, db)
+	// 注册通知路由
+	notify.RegisterNotifyRoutes(r, db)
+	// 注册卖家店铺路由
+	seller.RegisterSellerRoutes(r, db)
+	// 注册敏感词库管理路由
+	sensitive.RegisterSensitiveRoutes(r, db)
+	// 注册商品问答路由
+	qa.RegisterQARoutes(r, db)
+	// 注册优惠券路由
+	coupon.RegisterCouponRoutes(r, db)
 }
@@ -1,8 +1,11 @@
 package auth
 
 import (
+	"log"
 	"net/http"
 
+	"szu_market/internal/cart"
+
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
@@ -56,11 +59,23 @@ func loginHandler(c *gin.Context, db *gorm.DB) {
 		return
 	}
 
+	// 合并访客购物车，失败不影响登录
+	var merged *cart.MergeResult
+	if token, err := c.Cookie(cart.GuestCookieName); err == nil && cart.ValidGuestToken(token) {
+		merged, err = cart.NewCartService(db).MergeGuestCart(user.UserID, token)
+		if err != nil {
+			log.Printf("WARN: 合并访客购物车失败 user:%d - %v", user.UserID, err)
+		} else {
+			cart.ClearGuestCookie(c)
+		}
+	}
+
 	// 登录成功响应
 	c.JSON(http.StatusOK, gin.H{
-		"message": "登录成功",
-		"role":    user.Role,
-		"userId":  user.UserID,
+		"message":    "登录成功",
+		"role":       user.Role,
+		"userId":     user.UserID,
+		"cart_merge": merged,
 	})
 }
 
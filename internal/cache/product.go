@@ -0,0 +1,213 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"szu_market/internal/db"
+
+	"github.com/go-redis/redis/v8"
+	"golang.org/x/sync/singleflight"
+	"gorm.io/gorm"
+)
+
+const (
+	productTTL  = 30 * time.Minute // 单个商品缓存时间
+	missingTTL  = time.Minute      // 不存在的商品缓存时间，防止缓存穿透
+	listTTL     = 5 * time.Minute  // 列表页缓存时间
+	listVerKey  = "product:list:ver"
+	missingMark = "null"
+)
+
+// group 合并同一进程内对同一个键的并发回源，冷键只查询一次 MySQL
+var group singleflight.Group
+
+func productKey(productID uint) string {
+	return fmt.Sprintf("product:%d", productID)
+}
+
+// GetProduct 读取单个商品（含规格与价格区间，包含已软删除的商品，调用方自行判断 DeletedAt）
+// 商品不存在时返回 gorm.ErrRecordNotFound
+func GetProduct(database *gorm.DB, productID uint) (*db.SpecialProduct, error) {
+	products, err := GetProducts(database, []uint{productID})
+	if err != nil {
+		return nil, err
+	}
+	p, ok := products[productID]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &p, nil
+}
+
+// GetProducts 批量读取商品，未命中的部分一次性回源并写回缓存，不存在的ID不会出现在结果中
+func GetProducts(database *gorm.DB, productIDs []uint) (map[uint]db.SpecialProduct, error) {
+	result := make(map[uint]db.SpecialProduct, len(productIDs))
+	ids := uniqueIDs(productIDs)
+	if len(ids) == 0 {
+		return result, nil
+	}
+	ctx := context.Background()
+
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = productKey(id)
+	}
+
+	var misses []uint
+	values, err := db.RDB.MGet(ctx, keys...).Result()
+	if err != nil {
+		// Redis 不可用时直接回源，不影响读取
+		log.Printf("WARN: 读取商品缓存失败: %v", err)
+		values = make([]interface{}, len(ids))
+	}
+	for i, v := range values {
+		str, ok := v.(string)
+		if !ok {
+			misses = append(misses, ids[i])
+			continue
+		}
+		if str == missingMark {
+			continue
+		}
+		var p db.SpecialProduct
+		if err := json.Unmarshal([]byte(str), &p); err != nil {
+			misses = append(misses, ids[i])
+			continue
+		}
+		result[p.ProductID] = p
+	}
+	if len(misses) == 0 {
+		return result, nil
+	}
+
+	loaded, err, _ := group.Do("products:"+joinIDs(misses), func() (interface{}, error) {
+		return loadProducts(database, misses)
+	})
+	if err != nil {
+		return nil, err
+	}
+	for id, p := range loaded.(map[uint]db.SpecialProduct) {
+		result[id] = p
+	}
+	return result, nil
+}
+
+// loadProducts 从数据库加载商品并写回缓存
+func loadProducts(database *gorm.DB, ids []uint) (map[uint]db.SpecialProduct, error) {
+	var products []db.SpecialProduct
+	if err := database.Unscoped().Preload("SKUs").Where("product_id IN ?", ids).Find(&products).Error; err != nil {
+		return nil, fmt.Errorf("查询商品失败: %w", err)
+	}
+
+	ctx := context.Background()
+	pipe := db.RDB.Pipeline()
+	result := make(map[uint]db.SpecialProduct, len(products))
+	for _, p := range products {
+		p.FillPriceRange()
+		result[p.ProductID] = p
+		if data, err := json.Marshal(p); err == nil {
+			pipe.Set(ctx, productKey(p.ProductID), data, productTTL)
+		}
+	}
+	for _, id := range ids {
+		if _, ok := result[id]; !ok {
+			pipe.Set(ctx, productKey(id), missingMark, missingTTL)
+		}
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("WARN: 写入商品缓存失败: %v", err)
+	}
+	return result, nil
+}
+
+// GetList 读取缓存的商品列表页，name 标识列表（如 "shouye"），未命中时调用 loader 回源
+func GetList(name string, loader func() ([]db.SpecialProduct, error)) ([]db.SpecialProduct, error) {
+	ctx := context.Background()
+
+	ver, err := db.RDB.Get(ctx, listVerKey).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		log.Printf("WARN: 读取列表缓存版本失败: %v", err)
+		return loader()
+	}
+	if ver == "" {
+		ver = "0"
+	}
+	key := fmt.Sprintf("product:list:v%s:%s", ver, name)
+
+	if data, err := db.RDB.Get(ctx, key).Bytes(); err == nil {
+		var products []db.SpecialProduct
+		if err := json.Unmarshal(data, &products); err == nil {
+			return products, nil
+		}
+	}
+
+	loaded, err, _ := group.Do(key, func() (interface{}, error) {
+		products, err := loader()
+		if err != nil {
+			return nil, err
+		}
+		if data, err := json.Marshal(products); err == nil {
+			if err := db.RDB.Set(ctx, key, data, listTTL).Err(); err != nil {
+				log.Printf("WARN: 写入列表缓存失败: %v", err)
+			}
+		}
+		return products, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return loaded.([]db.SpecialProduct), nil
+}
+
+// InvalidateProducts 删除单个商品缓存（不影响列表页），用于销量、库存等高频变化
+func InvalidateProducts(productIDs ...uint) {
+	if len(productIDs) == 0 {
+		return
+	}
+	keys := make([]string, 0, len(productIDs))
+	for _, id := range uniqueIDs(productIDs) {
+		keys = append(keys, productKey(id))
+	}
+	if err := db.RDB.Del(context.Background(), keys...).Err(); err != nil {
+		log.Printf("WARN: 删除商品缓存失败: %v", err)
+	}
+}
+
+// Invalidate 删除商品缓存并使所有列表页失效，用于新增、修改、删除和审核变更
+func Invalidate(productIDs ...uint) {
+	InvalidateProducts(productIDs...)
+	// 列表页键带版本号，递增版本即可让旧列表全部失效，旧键随 TTL 过期
+	if err := db.RDB.Incr(context.Background(), listVerKey).Err(); err != nil {
+		log.Printf("WARN: 更新列表缓存版本失败: %v", err)
+	}
+}
+
+func uniqueIDs(ids []uint) []uint {
+	seen := make(map[uint]bool, len(ids))
+	result := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		if id != 0 && !seen[id] {
+			seen[id] = true
+			result = append(result, id)
+		}
+	}
+	return result
+}
+
+func joinIDs(ids []uint) string {
+	sorted := append([]uint(nil), ids...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	parts := make([]string, len(sorted))
+	for i, id := range sorted {
+		parts[i] = strconv.FormatUint(uint64(id), 10)
+	}
+	return strings.Join(parts, ",")
+}
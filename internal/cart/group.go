@@ -0,0 +1,264 @@
+package cart
+
+import (
+	"fmt"
+	"time"
+
+	"szu_market/internal/db"
+
+	"gorm.io/gorm"
+)
+
+// PromotionView 卖家满减活动在购物车中的适用情况
+type PromotionView struct {
+	PromotionID uint   `json:"promotion_id"`
+	Title       string `json:"title"`
+	Threshold   string `json:"threshold"`
+	Reduction   string `json:"reduction"`
+	Applied     bool   `json:"applied"` // 是否为当前使用的活动
+	Gap         string `json:"gap"`     // 距离门槛还差的金额，已满足时为 0
+}
+
+// SellerGroup 购物车中同一卖家的商品，金额只统计已勾选且可购买的商品
+type SellerGroup struct {
+	SellerID        uint               `json:"seller_id"`
+	SellerName      string             `json:"seller_name"`
+	Items           []CartItemResponse `json:"items"`
+	AllSelected     bool               `json:"all_selected"`
+	SelectedCount   int                `json:"selected_count"`
+	Subtotal        string             `json:"subtotal"`          // 商品金额
+	Discount        string             `json:"discount"`          // 满减优惠
+	ShippingFee     string             `json:"shipping_fee"`      // 运费，满额包邮时为 0
+	FreeShippingGap string             `json:"free_shipping_gap"` // 距离包邮还差的金额，已包邮或不支持包邮时为 0
+	Total           string             `json:"total"`             // 商品金额 - 优惠 + 运费
+	Promotions      []PromotionView    `json:"promotions"`
+}
+
+// GroupedCartResponse 按卖家分组的购物车
+type GroupedCartResponse struct {
+	Groups      []SellerGroup `json:"groups"`
+	Summary     CartSummary   `json:"summary"`
+	Discount    string        `json:"discount"`
+	ShippingFee string        `json:"shipping_fee"`
+	Total       string        `json:"total"`
+}
+
+// GetGroupedCart 获取按卖家分组的用户购物车
+func (s *CartService) GetGroupedCart(userID uint) (*GroupedCartResponse, error) {
+	res, err := s.GetCartItems(userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.groupBySeller(res)
+}
+
+// GetGroupedGuestCart 获取按卖家分组的访客购物车
+func (s *CartService) GetGroupedGuestCart(token string) (*GroupedCartResponse, error) {
+	res, err := s.GetGuestCart(token)
+	if err != nil {
+		return nil, err
+	}
+	return s.groupBySeller(res)
+}
+
+// groupBySeller 按卖家分组并计算各卖家的满减与运费
+// 分组按卖家商品在购物车中首次出现的顺序排列
+func (s *CartService) groupBySeller(res *CartResponse) (*GroupedCartResponse, error) {
+	var sellerIDs []uint
+	groupIndex := make(map[uint]int)
+	var groups []SellerGroup
+	for _, item := range res.Items {
+		i, ok := groupIndex[item.SellerID]
+		if !ok {
+			i = len(groups)
+			groupIndex[item.SellerID] = i
+			groups = append(groups, SellerGroup{SellerID: item.SellerID, AllSelected: true})
+			if item.SellerID != 0 {
+				sellerIDs = append(sellerIDs, item.SellerID)
+			}
+		}
+		groups[i].Items = append(groups[i].Items, item)
+	}
+
+	names, err := s.sellerNames(sellerIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	subtotals := make(map[uint]int64, len(groups))
+	for i := range groups {
+		g := &groups[i]
+		g.SellerName = names[g.SellerID]
+
+		var subtotal int64
+		purchasable := 0
+		for _, item := range g.Items {
+			if !item.Purchasable {
+				continue
+			}
+			purchasable++
+			if !item.Selected {
+				g.AllSelected = false
+				continue
+			}
+			price, err := db.PriceToCents(item.Price)
+			if err != nil {
+				return nil, fmt.Errorf("商品 %d 价格无效: %w", item.ProductID, err)
+			}
+			subtotal += price * int64(item.Quantity)
+			g.SelectedCount++
+		}
+		if purchasable == 0 {
+			g.AllSelected = false
+		}
+		subtotals[g.SellerID] = subtotal
+	}
+
+	charges, err := SellerCharges(s.DB, subtotals)
+	if err != nil {
+		return nil, err
+	}
+
+	grouped := &GroupedCartResponse{Groups: []SellerGroup{}, Summary: res.Summary}
+	var totalDiscount, totalShipping, total int64
+	for _, g := range groups {
+		subtotal, charge := subtotals[g.SellerID], charges[g.SellerID]
+		g.Promotions = charge.Promotions
+		if g.Promotions == nil {
+			g.Promotions = []PromotionView{}
+		}
+		g.Subtotal = db.CentsToPrice(subtotal)
+		g.Discount = db.CentsToPrice(charge.Discount)
+		g.ShippingFee = db.CentsToPrice(charge.ShippingFee)
+		g.FreeShippingGap = db.CentsToPrice(charge.FreeShippingGap)
+		g.Total = db.CentsToPrice(subtotal - charge.Discount + charge.ShippingFee)
+		grouped.Groups = append(grouped.Groups, g)
+
+		totalDiscount += charge.Discount
+		totalShipping += charge.ShippingFee
+		total += subtotal - charge.Discount + charge.ShippingFee
+	}
+	grouped.Discount = db.CentsToPrice(totalDiscount)
+	grouped.ShippingFee = db.CentsToPrice(totalShipping)
+	grouped.Total = db.CentsToPrice(total)
+	return grouped, nil
+}
+
+// sellerNames 批量查询卖家用户名
+func (s *CartService) sellerNames(sellerIDs []uint) (map[uint]string, error) {
+	names := make(map[uint]string, len(sellerIDs))
+	if len(sellerIDs) == 0 {
+		return names, nil
+	}
+	var users []db.User
+	if err := s.DB.Select("user_id", "username").Where("user_id IN ?", sellerIDs).Find(&users).Error; err != nil {
+		return nil, fmt.Errorf("查询卖家信息失败: %w", err)
+	}
+	for _, u := range users {
+		names[u.UserID] = u.Username
+	}
+	return names, nil
+}
+
+// SellerCharge 卖家维度的满减优惠与运费，金额单位为分
+type SellerCharge struct {
+	Discount        int64
+	ShippingFee     int64
+	FreeShippingGap int64
+	Promotions      []PromotionView
+}
+
+// SellerCharges 按各卖家的商品金额（单位：分）计算满减与运费，购物车分组与下单计价共用
+// 每个卖家只使用优惠最大的一个进行中的满减活动；包邮门槛按商品金额判断，商品金额为 0 时不收运费
+func SellerCharges(database *gorm.DB, subtotals map[uint]int64) (map[uint]SellerCharge, error) {
+	charges := make(map[uint]SellerCharge, len(subtotals))
+	sellerIDs := make([]uint, 0, len(subtotals))
+	for id := range subtotals {
+		if id != 0 {
+			sellerIDs = append(sellerIDs, id)
+		}
+	}
+	if len(sellerIDs) == 0 {
+		return charges, nil
+	}
+
+	var settings []db.SellerShipping
+	if err := database.Where("seller_id IN ?", sellerIDs).Find(&settings).Error; err != nil {
+		return nil, fmt.Errorf("查询运费设置失败: %w", err)
+	}
+	shipping := make(map[uint]db.SellerShipping, len(settings))
+	for _, setting := range settings {
+		shipping[setting.SellerID] = setting
+	}
+
+	now := time.Now()
+	var active []db.SellerPromotion
+	if err := database.Where("seller_id IN ? AND start_at <= ? AND end_at > ?", sellerIDs, now, now).
+		Order("threshold, promotion_id").Find(&active).Error; err != nil {
+		return nil, fmt.Errorf("查询满减活动失败: %w", err)
+	}
+	promotions := make(map[uint][]db.SellerPromotion, len(sellerIDs))
+	for _, p := range active {
+		promotions[p.SellerID] = append(promotions[p.SellerID], p)
+	}
+
+	for _, id := range sellerIDs {
+		var charge SellerCharge
+		charge.Discount, charge.Promotions = applyPromotions(promotions[id], subtotals[id])
+		charge.ShippingFee, charge.FreeShippingGap = shippingFee(shipping[id], subtotals[id])
+		charges[id] = charge
+	}
+	return charges, nil
+}
+
+// applyPromotions 选出优惠最大的满减活动，返回优惠金额与各活动的适用情况
+func applyPromotions(promotions []db.SellerPromotion, subtotal int64) (int64, []PromotionView) {
+	views := make([]PromotionView, 0, len(promotions))
+	best, bestIndex := int64(0), -1
+	for _, p := range promotions {
+		threshold, err := db.PriceToCents(p.Threshold)
+		if err != nil {
+			continue
+		}
+		reduction, err := db.PriceToCents(p.Reduction)
+		if err != nil {
+			continue
+		}
+		var gap int64
+		if subtotal < threshold {
+			gap = threshold - subtotal
+		} else if reduction > best {
+			best, bestIndex = reduction, len(views)
+		}
+		views = append(views, PromotionView{
+			PromotionID: p.PromotionID,
+			Title:       p.Title,
+			Threshold:   p.Threshold,
+			Reduction:   p.Reduction,
+			Gap:         db.CentsToPrice(gap),
+		})
+	}
+	if bestIndex >= 0 {
+		views[bestIndex].Applied = true
+	}
+	return best, views
+}
+
+// shippingFee 计算卖家运费，返回运费与距离包邮还差的金额
+func shippingFee(setting db.SellerShipping, subtotal int64) (int64, int64) {
+	if subtotal == 0 {
+		return 0, 0
+	}
+	fee, err := db.PriceToCents(setting.Fee)
+	if err != nil || fee <= 0 {
+		return 0, 0
+	}
+	threshold, err := db.PriceToCents(setting.FreeThreshold)
+	if err != nil || threshold <= 0 {
+		return fee, 0
+	}
+	if subtotal >= threshold {
+		return 0, 0
+	}
+	return fee, threshold - subtotal
+}
@@ -0,0 +1,78 @@
+package cart
+
+import (
+	"testing"
+
+	"szu_market/internal/db"
+)
+
+func TestApplyPromotions(t *testing.T) {
+	promotions := []db.SellerPromotion{
+		{PromotionID: 1, Title: "满50减5", Threshold: "50.00", Reduction: "5.00"},
+		{PromotionID: 2, Title: "满100减15", Threshold: "100.00", Reduction: "15.00"},
+		{PromotionID: 3, Title: "满120减12", Threshold: "120.00", Reduction: "12.00"},
+	}
+	tests := []struct {
+		name       string
+		promotions []db.SellerPromotion
+		subtotal   int64
+		discount   int64
+		applied    uint     // 期望使用的活动，0 表示不使用
+		gaps       []string // 各活动距离门槛的金额
+	}{
+		{"没有活动", nil, 10000, 0, 0, []string{}},
+		{"未满任何门槛", promotions, 3000, 0, 0, []string{"20.00", "70.00", "90.00"}},
+		{"恰好满足门槛", promotions, 5000, 500, 1, []string{"0.00", "50.00", "70.00"}},
+		{"取优惠最大而非门槛最高的活动", promotions, 13000, 1500, 2, []string{"0.00", "0.00", "0.00"}},
+		{"商品金额为 0", promotions, 0, 0, 0, []string{"50.00", "100.00", "120.00"}},
+		{"金额无效的活动跳过", []db.SellerPromotion{
+			{PromotionID: 4, Title: "无效", Threshold: "abc", Reduction: "5.00"},
+			{PromotionID: 5, Title: "满10减1", Threshold: "10.00", Reduction: "1.00"},
+		}, 2000, 100, 5, []string{"0.00"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			discount, views := applyPromotions(tt.promotions, tt.subtotal)
+			if discount != tt.discount {
+				t.Errorf("discount = %d, want %d", discount, tt.discount)
+			}
+			if len(views) != len(tt.gaps) {
+				t.Fatalf("views = %+v, want %d views", views, len(tt.gaps))
+			}
+			for i, v := range views {
+				if v.Gap != tt.gaps[i] {
+					t.Errorf("views[%d].Gap = %q, want %q", i, v.Gap, tt.gaps[i])
+				}
+				if want := v.PromotionID == tt.applied; v.Applied != want {
+					t.Errorf("views[%d] (promotion %d).Applied = %v, want %v", i, v.PromotionID, v.Applied, want)
+				}
+			}
+		})
+	}
+}
+
+func TestShippingFee(t *testing.T) {
+	tests := []struct {
+		name     string
+		setting  db.SellerShipping
+		subtotal int64
+		fee      int64
+		gap      int64
+	}{
+		{"未设置运费", db.SellerShipping{}, 5000, 0, 0},
+		{"运费为 0", db.SellerShipping{Fee: "0.00", FreeThreshold: "99.00"}, 5000, 0, 0},
+		{"不包邮", db.SellerShipping{Fee: "8.00", FreeThreshold: "0.00"}, 50000, 800, 0},
+		{"未满包邮门槛", db.SellerShipping{Fee: "8.00", FreeThreshold: "99.00"}, 5000, 800, 4900},
+		{"恰好满足包邮门槛", db.SellerShipping{Fee: "8.00", FreeThreshold: "99.00"}, 9900, 0, 0},
+		{"商品金额为 0 不收运费", db.SellerShipping{Fee: "8.00", FreeThreshold: "99.00"}, 0, 0, 0},
+		{"包邮门槛无效按不包邮", db.SellerShipping{Fee: "8.00", FreeThreshold: "abc"}, 5000, 800, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fee, gap := shippingFee(tt.setting, tt.subtotal)
+			if fee != tt.fee || gap != tt.gap {
+				t.Errorf("shippingFee() = (%d, %d), want (%d, %d)", fee, gap, tt.fee, tt.gap)
+			}
+		})
+	}
+}
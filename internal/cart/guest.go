@@ -0,0 +1,367 @@
+package cart
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"szu_market/internal/cache"
+	"szu_market/internal/db"
+	"szu_market/internal/limit"
+
+	"github.com/go-redis/redis/v8"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GuestCookieName 未登录访客购物车令牌的 Cookie 名称
+const GuestCookieName = "cart_token"
+
+// guestCartTTL 访客购物车有效期，每次修改后顺延
+const guestCartTTL = 30 * 24 * time.Hour
+
+// guestTokenLength 访客令牌长度（十六进制字符数）
+const guestTokenLength = 32
+
+// guestKey 访客购物车在 Redis 中的键，结构与登录用户的购物车哈希相同
+func guestKey(token string) string {
+	return "cart:guest:" + token
+}
+
+// NewGuestToken 生成新的访客购物车令牌
+func NewGuestToken() (string, error) {
+	buf := make([]byte, guestTokenLength/2)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("生成访客令牌失败: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// ValidGuestToken 校验令牌格式，避免任意字符串拼入 Redis 键
+func ValidGuestToken(token string) bool {
+	if len(token) != guestTokenLength {
+		return false
+	}
+	_, err := hex.DecodeString(token)
+	return err == nil
+}
+
+// guestAddScript 累加访客购物车数量、勾选并记录加入时的单价，值格式见 cartEntry
+// ARGV[2] 为增加的数量，ARGV[3] 为当前单价，ARGV[4] 为过期秒数
+var guestAddScript = redis.NewScript(`
+local v = redis.call('HGET', KEYS[1], ARGV[1])
+local qty = 0
+if v then qty = tonumber(string.match(v, '^%d+')) or 0 end
+qty = qty + tonumber(ARGV[2])
+redis.call('HSET', KEYS[1], ARGV[1], qty .. ':1:0:' .. ARGV[3])
+redis.call('EXPIRE', KEYS[1], ARGV[4])
+return qty
+`)
+
+// guestUpdateScript 修改已存在的访客购物车项，ARGV[2] 为数量、ARGV[3] 为勾选状态，传 -1 表示保持不变
+// 购物车项不存在时返回 0
+var guestUpdateScript = redis.NewScript(`
+local v = redis.call('HGET', KEYS[1], ARGV[1])
+if not v then return 0 end
+local qty = tonumber(string.match(v, '^%d+')) or 0
+local sel = string.match(v, '^%d+:(%d)') or '1'
+local rest = string.match(v, '^%d+:%d(:.*)$') or ''
+if ARGV[2] ~= '-1' then qty = tonumber(ARGV[2]) end
+if ARGV[3] ~= '-1' then sel = ARGV[3] end
+redis.call('HSET', KEYS[1], ARGV[1], qty .. ':' .. sel .. rest)
+redis.call('EXPIRE', KEYS[1], ARGV[4])
+return 1
+`)
+
+// GetGuestCart 获取访客购物车
+func (s *CartService) GetGuestCart(token string) (*CartResponse, error) {
+	cartMap, err := s.guestEntries(token)
+	if err != nil {
+		return nil, err
+	}
+	items, err := s.buildCartItems(cartMap)
+	if err != nil {
+		return nil, err
+	}
+	summary, err := s.summarize(items)
+	if err != nil {
+		return nil, err
+	}
+	if items == nil {
+		items = []CartItemResponse{}
+	}
+	return &CartResponse{Items: items, Summary: *summary}, nil
+}
+
+// AddToGuestCart 添加商品到访客购物车，校验规则与登录用户相同
+func (s *CartService) AddToGuestCart(token string, input *AddToCartInput) error {
+	if input.ProductID == 0 {
+		return errors.New("invalid user/product ID")
+	}
+	if input.Quantity <= 0 {
+		input.Quantity = 1
+	}
+
+	var product db.SpecialProduct
+	if err := s.DB.First(&product, input.ProductID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("product not exist")
+		}
+		return fmt.Errorf("query product failed: %w", err)
+	}
+	sku, err := s.checkSKU(input.ProductID, input.SKUID)
+	if err != nil {
+		return err
+	}
+
+	if err := guestAddScript.Run(context.Background(), db.RDB,
+		[]string{guestKey(token)},
+		cartField(input.ProductID, input.SKUID), input.Quantity, unitPrice(&product, sku), int(guestCartTTL.Seconds()),
+	).Err(); err != nil {
+		return fmt.Errorf("更新购物车失败: %w", err)
+	}
+	return nil
+}
+
+// RemoveGuestItem 从访客购物车中删除商品
+func (s *CartService) RemoveGuestItem(token string, productID, skuID uint) error {
+	if productID == 0 {
+		return errors.New("无效的商品ID")
+	}
+	if err := db.RDB.HDel(context.Background(), guestKey(token), cartField(productID, skuID)).Err(); err != nil {
+		return fmt.Errorf("删除购物车项失败: %w", err)
+	}
+	return nil
+}
+
+// UpdateGuestQuantity 更新访客购物车项数量
+func (s *CartService) UpdateGuestQuantity(token string, productID, skuID uint, quantity int) error {
+	if productID == 0 {
+		return errors.New("无效的商品ID")
+	}
+	if quantity <= 0 {
+		return errors.New("数量必须大于0")
+	}
+	return s.updateGuestItem(token, productID, skuID, quantity, -1)
+}
+
+// SetGuestSelected 勾选或取消勾选访客购物车项
+func (s *CartService) SetGuestSelected(token string, productID, skuID uint, selected bool) error {
+	if productID == 0 {
+		return errors.New("无效的商品ID")
+	}
+	return s.updateGuestItem(token, productID, skuID, -1, boolFlag(selected))
+}
+
+// SelectAllGuest 勾选或取消勾选访客购物车中的全部商品
+func (s *CartService) SelectAllGuest(token string, selected bool) error {
+	return s.selectGuestItems(token, selected, func(uint) bool { return true })
+}
+
+// SelectGuestBySeller 勾选或取消勾选访客购物车中某个卖家的全部商品
+func (s *CartService) SelectGuestBySeller(token string, sellerID uint, selected bool) error {
+	if sellerID == 0 {
+		return errors.New("卖家ID无效")
+	}
+	return s.selectGuestItems(token, selected, func(seller uint) bool { return seller == sellerID })
+}
+
+func (s *CartService) updateGuestItem(token string, productID, skuID uint, quantity, selected int) error {
+	n, err := guestUpdateScript.Run(context.Background(), db.RDB,
+		[]string{guestKey(token)},
+		cartField(productID, skuID), quantity, selected, int(guestCartTTL.Seconds()),
+	).Int()
+	if err != nil {
+		return fmt.Errorf("更新购物车失败: %w", err)
+	}
+	if n == 0 {
+		return errors.New("购物车中未找到该商品")
+	}
+	return nil
+}
+
+// selectGuestItems 按卖家筛选访客购物车项并批量修改勾选状态
+func (s *CartService) selectGuestItems(token string, selected bool, match func(sellerID uint) bool) error {
+	items, err := s.GetGuestCart(token)
+	if err != nil {
+		return err
+	}
+	for _, item := range items.Items {
+		if item.Selected == selected || !match(item.SellerID) {
+			continue
+		}
+		if err := s.updateGuestItem(token, item.ProductID, item.SKUID, -1, boolFlag(selected)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// guestEntries 读取访客购物车，访客购物车只存在于 Redis，无法解析的字段直接删除
+func (s *CartService) guestEntries(token string) (map[string]string, error) {
+	ctx := context.Background()
+	key := guestKey(token)
+	cartMap, err := db.RDB.HGetAll(ctx, key).Result()
+	if err != nil {
+		return nil, fmt.Errorf("读取购物车失败: %w", err)
+	}
+	for field, value := range cartMap {
+		_, _, fieldErr := parseCartField(field)
+		_, valueErr := parseCartEntry(value)
+		if fieldErr == nil && valueErr == nil {
+			continue
+		}
+		log.Printf("WARN: 删除无效的访客购物车字段 token:%s field:%s value:%s", token, field, value)
+		db.RDB.HDel(ctx, key, field)
+		delete(cartMap, field)
+	}
+	return cartMap, nil
+}
+
+func boolFlag(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// MergeResult 访客购物车合并结果
+type MergeResult struct {
+	Merged   int       `json:"merged"`   // 合并的商品种类数
+	Adjusted []ItemKey `json:"adjusted"` // 数量超过库存或限购被下调的商品
+	Skipped  []ItemKey `json:"skipped"`  // 已下架、已删除、无库存或已达限购而未合并的商品
+}
+
+// MergeGuestCart 登录后将访客购物车合并到用户购物车
+// 合并规则：同一商品规格的数量相加，有规格的商品数量不超过当前库存，限购商品不超过剩余可购件数；
+// 已下架、已删除、规格失效或库存为 0 的商品不合并；访客勾选状态覆盖用户原有状态。
+// 合并成功后删除访客购物车。
+func (s *CartService) MergeGuestCart(userID uint, token string) (*MergeResult, error) {
+	if userID == 0 {
+		return nil, errors.New("用户未登录")
+	}
+	result := &MergeResult{Adjusted: []ItemKey{}, Skipped: []ItemKey{}}
+
+	ctx := context.Background()
+	key := guestKey(token)
+	cartMap, err := s.guestEntries(token)
+	if err != nil {
+		return nil, err
+	}
+	if len(cartMap) == 0 {
+		return result, nil
+	}
+
+	type guestItem struct {
+		ItemKey
+		cartEntry
+	}
+	guestItems := make([]guestItem, 0, len(cartMap))
+	productIDs := make([]uint, 0, len(cartMap))
+	for field, value := range cartMap {
+		pid, skuID, _ := parseCartField(field)
+		entry, _ := parseCartEntry(value)
+		guestItems = append(guestItems, guestItem{ItemKey{pid, skuID}, entry})
+		productIDs = append(productIDs, pid)
+	}
+	productMap, err := cache.GetProducts(s.DB, productIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.mutate(userID, func(tx *gorm.DB) error {
+		for _, item := range guestItems {
+			p, ok := productMap[item.ProductID]
+			if !ok || p.DeletedAt.Valid || !p.IsActive || p.IsViolation {
+				result.Skipped = append(result.Skipped, item.ItemKey)
+				continue
+			}
+
+			// 有规格的商品必须选择规格，规格需有效且有库存
+			maxQuantity := -1
+			price := p.Price
+			if item.SKUID == 0 {
+				if hasActiveSKU(&p) {
+					result.Skipped = append(result.Skipped, item.ItemKey)
+					continue
+				}
+			} else {
+				var sku db.ProductSKU
+				err := tx.Where("sku_id = ? AND product_id = ?", item.SKUID, item.ProductID).First(&sku).Error
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					result.Skipped = append(result.Skipped, item.ItemKey)
+					continue
+				}
+				if err != nil {
+					return fmt.Errorf("查询规格失败: %w", err)
+				}
+				if !sku.IsActive || sku.Stock <= 0 {
+					result.Skipped = append(result.Skipped, item.ItemKey)
+					continue
+				}
+				maxQuantity = sku.Stock
+				price = sku.Price
+			}
+			// 保留访客加入时的单价，以便合并后仍能提示价格变化
+			if item.PriceAtAdd != "" {
+				price = item.PriceAtAdd
+			}
+
+			var existing db.CartItem
+			err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where("user_id = ? AND product_id = ? AND sku_id = ?", userID, item.ProductID, item.SKUID).
+				Find(&existing).Error
+			if err != nil {
+				return fmt.Errorf("查询购物车失败: %w", err)
+			}
+			quantity := item.Quantity
+			if existing.CartID != 0 && existing.Status == "in_cart" {
+				quantity += existing.Quantity
+			}
+			adjusted := false
+			if maxQuantity >= 0 && quantity > maxQuantity {
+				quantity = maxQuantity
+				adjusted = true
+			}
+			// 限购：合并后的数量不超过买家还能购买的件数，已无余量时不合并
+			if err := s.checkLimit(tx, userID, &p, &item.SKUID, quantity); err != nil {
+				var exceeded *limit.ExceededError
+				if !errors.As(err, &exceeded) {
+					return err
+				}
+				quantity = int(exceeded.Remaining())
+				adjusted = true
+			}
+			if quantity <= 0 {
+				result.Skipped = append(result.Skipped, item.ItemKey)
+				continue
+			}
+			if adjusted {
+				result.Adjusted = append(result.Adjusted, item.ItemKey)
+			}
+
+			if err := tx.Exec(`
+        INSERT INTO cart_items (user_id, product_id, sku_id, quantity, status, selected, price_at_add)
+        VALUES (?, ?, ?, ?, 'in_cart', ?, ?)
+        ON DUPLICATE KEY UPDATE quantity = ?, status = 'in_cart', selected = ?, price_at_add = ?`,
+				userID, item.ProductID, item.SKUID, quantity, item.Selected, price, quantity, item.Selected, price,
+			).Error; err != nil {
+				return fmt.Errorf("合并购物车失败: %w", err)
+			}
+			result.Merged++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.RDB.Del(ctx, key).Err(); err != nil {
+		log.Printf("WARN: 删除访客购物车失败 token:%s - %v", token, err)
+	}
+	return result, nil
+}
@@ -1,10 +1,13 @@
 package cart
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
 
+	"szu_market/internal/limit"
+
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
@@ -21,10 +24,10 @@ func NewCartHandler(service *CartService) *CartHandler {
 
 // GetCartItems 获取购物车项
 func (h *CartHandler) GetCartItems(c *gin.Context) {
-	// 获取用户ID
+	// 获取用户ID，未登录时读取访客购物车
 	userIDStr := c.Query("user_id")
 	if userIDStr == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"message": "用户未登录"})
+		h.getGuestCart(c)
 		return
 	}
 
@@ -36,13 +39,54 @@ func (h *CartHandler) GetCartItems(c *gin.Context) {
 	}
 
 	// 调用服务层获取购物车项
-	items, err := h.Service.GetCartItems(uint(userID))
+	res, err := h.Service.GetCartItems(uint(userID))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"items": items})
+	c.JSON(http.StatusOK, gin.H{"items": res.Items, "summary": res.Summary})
+}
+
+// getGuestCart 获取访客购物车，没有令牌时返回空购物车
+func (h *CartHandler) getGuestCart(c *gin.Context) {
+	token := guestToken(c)
+	if token == "" {
+		summary, _ := h.Service.summarize(nil)
+		c.JSON(http.StatusOK, gin.H{"items": []CartItemResponse{}, "summary": summary})
+		return
+	}
+
+	res, err := h.Service.GetGuestCart(token)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": res.Items, "summary": res.Summary})
+}
+
+// GetGroupedCart 获取按卖家分组的购物车，含各卖家的小计、满减与运费
+func (h *CartHandler) GetGroupedCart(c *gin.Context) {
+	var res *GroupedCartResponse
+	var err error
+	if userIDStr := c.Query("user_id"); userIDStr != "" {
+		userID, parseErr := strconv.ParseUint(userIDStr, 10, 32)
+		if parseErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "用户ID无效"})
+			return
+		}
+		res, err = h.Service.GetGroupedCart(uint(userID))
+	} else if token := guestToken(c); token != "" {
+		res, err = h.Service.GetGroupedGuestCart(token)
+	} else {
+		summary, _ := h.Service.summarize(nil)
+		res, err = h.Service.groupBySeller(&CartResponse{Items: []CartItemResponse{}, Summary: *summary})
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, res)
 }
 
 // AddToCart 添加商品到购物车
@@ -54,8 +98,26 @@ func (h *CartHandler) AddToCart(c *gin.Context) {
 		return
 	}
 
+	// 未登录时添加到访客购物车
+	if input.UserID == 0 {
+		token, err := ensureGuestToken(c)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+			return
+		}
+		if err := h.Service.AddToGuestCart(token, &input); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+			return
+		}
+		c.Status(http.StatusNoContent)
+		return
+	}
+
 	// 调用服务层添加商品
 	if err := h.Service.AddToCart(&input); err != nil {
+		if respondLimitError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
 		return
 	}
@@ -73,25 +135,29 @@ func (h *CartHandler) RemoveCartItem(c *gin.Context) {
 		return
 	}
 
-	// 获取用户ID
-	userIDStr := c.Query("user_id")
-	if userIDStr == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"message": "用户未登录"})
+	// 获取用户ID或访客令牌
+	userID, token, ok := cartOwner(c)
+	if !ok {
 		return
 	}
 
-	// 转换用户ID
-	userID, err := strconv.ParseUint(userIDStr, 10, 32)
+	// 规格ID（可选）
+	skuID, err := parseSKUID(c)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"message": "用户ID无效"})
+		c.JSON(http.StatusBadRequest, gin.H{"message": "规格ID无效"})
 		return
 	}
 
 	// 调用服务层删除商品
-	err = h.Service.RemoveCartItem(&RemoveCartItemInput{
-		UserID:    uint(userID),
-		ProductID: uint(productID),
-	})
+	if token != "" {
+		err = h.Service.RemoveGuestItem(token, uint(productID), skuID)
+	} else {
+		err = h.Service.RemoveCartItem(&RemoveCartItemInput{
+			UserID:    userID,
+			ProductID: uint(productID),
+			SKUID:     skuID,
+		})
+	}
 
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
@@ -112,18 +178,16 @@ func (h *CartHandler) UpdateCartItemQuantity(c *gin.Context) {
 		return
 	}
 
-	// 获取用户ID
-	userIDStr := c.Query("user_id")
-	if userIDStr == "" {
-		fmt.Println("用户未登录")
-		c.JSON(http.StatusUnauthorized, gin.H{"message": "用户未登录"})
+	// 获取用户ID或访客令牌
+	userID, token, ok := cartOwner(c)
+	if !ok {
 		return
 	}
 
-	// 转换用户ID
-	userID, err := strconv.ParseUint(userIDStr, 10, 32)
+	// 规格ID（可选）
+	skuID, err := parseSKUID(c)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"message": "用户ID无效"})
+		c.JSON(http.StatusBadRequest, gin.H{"message": "规格ID无效"})
 		return
 	}
 
@@ -138,14 +202,22 @@ func (h *CartHandler) UpdateCartItemQuantity(c *gin.Context) {
 	}
 
 	// 调用服务层更新数量
-	err = h.Service.UpdateCartItemQuantity(&UpdateCartItemQuantityInput{
-		UserID:    uint(userID),
-		ProductID: uint(productID),
-		Quantity:  body.Quantity,
-	})
+	if token != "" {
+		err = h.Service.UpdateGuestQuantity(token, uint(productID), skuID, body.Quantity)
+	} else {
+		err = h.Service.UpdateCartItemQuantity(&UpdateCartItemQuantityInput{
+			UserID:    userID,
+			ProductID: uint(productID),
+			SKUID:     skuID,
+			Quantity:  body.Quantity,
+		})
+	}
 
 	if err != nil {
 		fmt.Println(err)
+		if respondLimitError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
 		return
 	}
@@ -153,6 +225,318 @@ func (h *CartHandler) UpdateCartItemQuantity(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"success": true, "message": "数量更新成功"})
 }
 
+// SaveForLater 将单个购物车项移入收藏，返回移动后的购物车
+func (h *CartHandler) SaveForLater(c *gin.Context) {
+	productID, err := strconv.ParseUint(c.Param("product_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "商品ID无效"})
+		return
+	}
+	userID, err := strconv.ParseUint(c.Query("user_id"), 10, 32)
+	if err != nil || userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "用户未登录"})
+		return
+	}
+	skuID, err := parseSKUID(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "规格ID无效"})
+		return
+	}
+
+	res, err := h.Service.MoveToFavorites(&MoveToFavoritesInput{
+		UserID: uint(userID),
+		Items:  []ItemKey{{ProductID: uint(productID), SKUID: skuID}},
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": res.Items, "summary": res.Summary})
+}
+
+// SaveSelectedForLater 批量将购物车项移入收藏，未指定商品时移动所有已勾选的商品
+func (h *CartHandler) SaveSelectedForLater(c *gin.Context) {
+	var input MoveToFavoritesInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "请求数据无效"})
+		return
+	}
+
+	res, err := h.Service.MoveToFavorites(&input)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": res.Items, "summary": res.Summary})
+}
+
+// MoveFavoriteToCart 将单个收藏加入购物车并取消收藏，返回加入后的购物车
+func (h *CartHandler) MoveFavoriteToCart(c *gin.Context) {
+	productID, err := strconv.ParseUint(c.Param("product_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "商品ID无效"})
+		return
+	}
+	var body struct {
+		UserID   uint `json:"user_id"`
+		SKUID    uint `json:"sku_id"`
+		Quantity int  `json:"quantity"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "请求数据无效"})
+		return
+	}
+
+	res, err := h.Service.MoveToCart(&MoveToCartInput{
+		UserID: body.UserID,
+		Items:  []FavoriteItem{{ProductID: uint(productID), SKUID: body.SKUID, Quantity: body.Quantity}},
+	})
+	if err != nil {
+		if respondLimitError(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": res.Items, "summary": res.Summary})
+}
+
+// MoveFavoritesToCart 批量将收藏加入购物车并取消收藏
+func (h *CartHandler) MoveFavoritesToCart(c *gin.Context) {
+	var input MoveToCartInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "请求数据无效"})
+		return
+	}
+
+	res, err := h.Service.MoveToCart(&input)
+	if err != nil {
+		if respondLimitError(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": res.Items, "summary": res.Summary})
+}
+
+// SetReminderOptOut 设置是否接收购物车未结算提醒
+func (h *CartHandler) SetReminderOptOut(c *gin.Context) {
+	var body struct {
+		UserID uint `json:"user_id"`
+		OptOut bool `json:"opt_out"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "请求数据无效"})
+		return
+	}
+
+	if err := h.Service.SetReminderOptOut(body.UserID, body.OptOut); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "提醒设置已更新"})
+}
+
+// GetReminderStats 查询购物车提醒的发送量与转化率，days 默认为 30
+func (h *CartHandler) GetReminderStats(c *gin.Context) {
+	days, err := strconv.Atoi(c.DefaultQuery("days", "30"))
+	if err != nil || days <= 0 || days > 365 {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "统计天数无效"})
+		return
+	}
+
+	stats, err := h.Service.GetReminderStats(days)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, stats)
+}
+
+// respondLimitError 超出限购时输出限购详情，返回是否已处理
+func respondLimitError(c *gin.Context, err error) bool {
+	var limitErr *limit.ExceededError
+	if !errors.As(err, &limitErr) {
+		return false
+	}
+	c.JSON(http.StatusConflict, gin.H{
+		"success":   false,
+		"code":      "PURCHASE_LIMIT",
+		"message":   limitErr.Error(),
+		"limit":     limitErr,
+		"remaining": limitErr.Remaining(),
+	})
+	return true
+}
+
+// SetSelected 勾选或取消勾选单个购物车项
+func (h *CartHandler) SetSelected(c *gin.Context) {
+	productID, err := strconv.ParseUint(c.Param("product_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "商品ID无效"})
+		return
+	}
+
+	userID, token, ok := cartOwner(c)
+	if !ok {
+		return
+	}
+
+	skuID, err := parseSKUID(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "规格ID无效"})
+		return
+	}
+
+	var body struct {
+		Selected bool `json:"selected"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "请求数据无效"})
+		return
+	}
+
+	if token != "" {
+		err = h.Service.SetGuestSelected(token, uint(productID), skuID, body.Selected)
+	} else {
+		err = h.Service.SetSelected(&SelectInput{
+			UserID:    userID,
+			ProductID: uint(productID),
+			SKUID:     skuID,
+			Selected:  body.Selected,
+		})
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "勾选状态已更新"})
+}
+
+// SelectAll 勾选或取消勾选全部购物车项
+func (h *CartHandler) SelectAll(c *gin.Context) {
+	var body struct {
+		UserID   uint `json:"user_id"`
+		Selected bool `json:"selected"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "请求数据无效"})
+		return
+	}
+
+	var err error
+	if body.UserID != 0 {
+		err = h.Service.SelectAll(body.UserID, body.Selected)
+	} else if token := guestToken(c); token != "" {
+		err = h.Service.SelectAllGuest(token, body.Selected)
+	} else {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "用户未登录"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "勾选状态已更新"})
+}
+
+// SelectBySeller 勾选或取消勾选某个卖家的全部购物车项
+func (h *CartHandler) SelectBySeller(c *gin.Context) {
+	sellerID, err := strconv.ParseUint(c.Param("seller_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "卖家ID无效"})
+		return
+	}
+
+	var body struct {
+		UserID   uint `json:"user_id"`
+		Selected bool `json:"selected"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "请求数据无效"})
+		return
+	}
+
+	if body.UserID != 0 {
+		err = h.Service.SelectBySeller(body.UserID, uint(sellerID), body.Selected)
+	} else if token := guestToken(c); token != "" {
+		err = h.Service.SelectGuestBySeller(token, uint(sellerID), body.Selected)
+	} else {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "用户未登录"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "勾选状态已更新"})
+}
+
+// cartOwner 解析购物车所属的用户ID，未提供 user_id 时使用访客令牌
+// 两者都没有时写入错误响应并返回 ok=false
+func cartOwner(c *gin.Context) (userID uint, token string, ok bool) {
+	userIDStr := c.Query("user_id")
+	if userIDStr == "" {
+		token = guestToken(c)
+		if token == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"message": "用户未登录"})
+			return 0, "", false
+		}
+		return 0, token, true
+	}
+	id, err := strconv.ParseUint(userIDStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "用户ID无效"})
+		return 0, "", false
+	}
+	return uint(id), "", true
+}
+
+// guestToken 读取访客购物车令牌，不存在或格式无效时返回空字符串
+func guestToken(c *gin.Context) string {
+	token, err := c.Cookie(GuestCookieName)
+	if err != nil || !ValidGuestToken(token) {
+		return ""
+	}
+	return token
+}
+
+// ensureGuestToken 读取访客购物车令牌，没有时生成新令牌并写入 Cookie
+func ensureGuestToken(c *gin.Context) (string, error) {
+	token := guestToken(c)
+	if token == "" {
+		var err error
+		if token, err = NewGuestToken(); err != nil {
+			return "", err
+		}
+	}
+	// 每次写入都顺延 Cookie 有效期，与 Redis 中的过期时间保持一致
+	c.SetCookie(GuestCookieName, token, int(guestCartTTL.Seconds()), "/", "", false, true)
+	return token, nil
+}
+
+// ClearGuestCookie 清除访客购物车令牌，登录合并后调用
+func ClearGuestCookie(c *gin.Context) {
+	c.SetCookie(GuestCookieName, "", -1, "/", "", false, true)
+}
+
+// parseSKUID 解析可选的规格ID查询参数，未提供时为 0
+func parseSKUID(c *gin.Context) (uint, error) {
+	skuIDStr := c.Query("sku_id")
+	if skuIDStr == "" {
+		return 0, nil
+	}
+	skuID, err := strconv.ParseUint(skuIDStr, 10, 32)
+	if err != nil {
+		return 0, err
+	}
+	return uint(skuID), nil
+}
+
 // RegisterCartRoutes 注册购物车路由
 func RegisterCartRoutes(r *gin.Engine, db *gorm.DB) {
 	// 创建服务和处理程序
@@ -161,7 +545,17 @@ func RegisterCartRoutes(r *gin.Engine, db *gorm.DB) {
 
 	// 注册购物车路由
 	r.GET("/cart", cartHandler.GetCartItems)
+	r.GET("/cart/grouped", cartHandler.GetGroupedCart)
 	r.POST("/cart", cartHandler.AddToCart)
 	r.DELETE("/cart/:product_id", cartHandler.RemoveCartItem)
 	r.PUT("/cart/:product_id/quantity", cartHandler.UpdateCartItemQuantity)
+	r.PUT("/cart/:product_id/selected", cartHandler.SetSelected)
+	r.PUT("/cart/selected", cartHandler.SelectAll)
+	r.PUT("/cart/sellers/:seller_id/selected", cartHandler.SelectBySeller)
+	r.POST("/cart/:product_id/later", cartHandler.SaveForLater)
+	r.POST("/cart/later", cartHandler.SaveSelectedForLater)
+	r.POST("/cart/favorites/:product_id", cartHandler.MoveFavoriteToCart)
+	r.POST("/cart/favorites", cartHandler.MoveFavoritesToCart)
+	r.PUT("/cart/reminders/opt_out", cartHandler.SetReminderOptOut)
+	r.GET("/cart/reminders/stats", cartHandler.GetReminderStats)
 }
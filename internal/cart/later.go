@@ -0,0 +1,208 @@
+package cart
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"szu_market/internal/cache"
+	"szu_market/internal/db"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 稍后再买：购物车项与收藏之间的移动在同一事务中完成，
+// 提交后购物车快照写入 Redis，并返回移动后的购物车
+
+// MoveToFavoritesInput 将购物车项移入收藏的输入，Items 为空时移动所有已勾选的商品
+type MoveToFavoritesInput struct {
+	UserID uint      `json:"user_id"`
+	Items  []ItemKey `json:"items"`
+}
+
+// MoveToFavorites 将购物车项移入收藏，已收藏的商品只从购物车中移除
+// 收藏按商品记录，同一商品的多个规格只生成一条收藏
+func (s *CartService) MoveToFavorites(input *MoveToFavoritesInput) (*CartResponse, error) {
+	if input.UserID == 0 {
+		return nil, errors.New("用户未登录")
+	}
+	if err := checkDuplicateKeys(input.Items); err != nil {
+		return nil, err
+	}
+
+	err := s.mutate(input.UserID, func(tx *gorm.DB) error {
+		query := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ? AND status = ?", input.UserID, "in_cart")
+		if len(input.Items) > 0 {
+			pairs := make([][]interface{}, 0, len(input.Items))
+			for _, item := range input.Items {
+				pairs = append(pairs, []interface{}{item.ProductID, item.SKUID})
+			}
+			query = query.Where("(product_id, sku_id) IN ?", pairs)
+		} else {
+			query = query.Where("selected = ?", true)
+		}
+		var items []db.CartItem
+		if err := query.Order("cart_id").Find(&items).Error; err != nil {
+			return fmt.Errorf("查询购物车失败: %w", err)
+		}
+		if len(items) == 0 {
+			return errors.New("请选择要移入收藏的商品")
+		}
+		if len(input.Items) > 0 && len(items) != len(input.Items) {
+			return errors.New("购物车中部分商品不存在，请刷新后重试")
+		}
+
+		cartIDs := make([]uint, 0, len(items))
+		productIDs := make([]uint, 0, len(items))
+		seen := make(map[uint]bool, len(items))
+		for _, item := range items {
+			cartIDs = append(cartIDs, item.CartID)
+			if !seen[item.ProductID] {
+				seen[item.ProductID] = true
+				productIDs = append(productIDs, item.ProductID)
+			}
+		}
+		if err := addFavorites(tx, input.UserID, productIDs); err != nil {
+			return err
+		}
+		if err := tx.Where("cart_id IN ?", cartIDs).Delete(&db.CartItem{}).Error; err != nil {
+			return fmt.Errorf("删除购物车项失败: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetCartItems(input.UserID)
+}
+
+// addFavorites 将商品收藏到默认收藏夹，记录当前最低价；已收藏的商品保持不变，已删除的商品不再收藏
+func addFavorites(tx *gorm.DB, userID uint, productIDs []uint) error {
+	productMap, err := cache.GetProducts(tx, productIDs)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	var favorites []db.Favorite
+	for _, id := range productIDs {
+		p, ok := productMap[id]
+		if !ok || p.DeletedAt.Valid {
+			continue
+		}
+		favorites = append(favorites, db.Favorite{
+			UserID:          userID,
+			ProductID:       id,
+			FavoriteTime:    now,
+			PriceAtFavorite: p.MinPrice,
+			AlertPrice:      "0",
+		})
+	}
+	if len(favorites) == 0 {
+		return nil
+	}
+	// (user_id, product_id) 唯一索引保证已收藏的商品不会重复收藏
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&favorites).Error; err != nil {
+		return fmt.Errorf("添加收藏失败: %w", err)
+	}
+	return nil
+}
+
+// FavoriteItem 从收藏加入购物车的商品，有规格的商品必须指定规格
+type FavoriteItem struct {
+	ProductID uint `json:"product_id"`
+	SKUID     uint `json:"sku_id"`
+	Quantity  int  `json:"quantity"`
+}
+
+// MoveToCartInput 将收藏移入购物车的输入
+type MoveToCartInput struct {
+	UserID uint           `json:"user_id"`
+	Items  []FavoriteItem `json:"items"`
+}
+
+// MoveToCart 将收藏的商品加入购物车并取消收藏，数量默认为 1
+// 同一收藏可以按多个规格加入购物车，任一商品不可加购时整体失败
+func (s *CartService) MoveToCart(input *MoveToCartInput) (*CartResponse, error) {
+	if input.UserID == 0 {
+		return nil, errors.New("用户未登录")
+	}
+	if len(input.Items) == 0 {
+		return nil, errors.New("请选择要加入购物车的收藏")
+	}
+	keys := make([]ItemKey, 0, len(input.Items))
+	for _, item := range input.Items {
+		if item.ProductID == 0 {
+			return nil, errors.New("无效的商品ID")
+		}
+		keys = append(keys, ItemKey{ProductID: item.ProductID, SKUID: item.SKUID})
+	}
+	if err := checkDuplicateKeys(keys); err != nil {
+		return nil, err
+	}
+
+	type pendingItem struct {
+		product  *db.SpecialProduct
+		sku      *db.ProductSKU
+		quantity int
+	}
+	pending := make([]pendingItem, 0, len(input.Items))
+	productIDs := make([]uint, 0, len(input.Items))
+	products := make(map[uint]*db.SpecialProduct, len(input.Items))
+	for _, item := range input.Items {
+		product, ok := products[item.ProductID]
+		if !ok {
+			product = &db.SpecialProduct{}
+			if err := s.DB.First(product, item.ProductID).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return nil, fmt.Errorf("商品 %d 不存在或已删除", item.ProductID)
+				}
+				return nil, fmt.Errorf("query product failed: %w", err)
+			}
+			products[item.ProductID] = product
+			productIDs = append(productIDs, item.ProductID)
+		}
+		sku, err := s.checkSKU(item.ProductID, item.SKUID)
+		if err != nil {
+			return nil, err
+		}
+		quantity := item.Quantity
+		if quantity <= 0 {
+			quantity = 1
+		}
+		pending = append(pending, pendingItem{product: product, sku: sku, quantity: quantity})
+	}
+
+	err := s.mutate(input.UserID, func(tx *gorm.DB) error {
+		result := tx.Where("user_id = ? AND product_id IN ?", input.UserID, productIDs).Delete(&db.Favorite{})
+		if result.Error != nil {
+			return fmt.Errorf("取消收藏失败: %w", result.Error)
+		}
+		if result.RowsAffected < int64(len(productIDs)) {
+			return errors.New("部分商品未收藏，请刷新后重试")
+		}
+		for _, item := range pending {
+			if err := s.addItem(tx, input.UserID, item.product, item.sku, item.quantity); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetCartItems(input.UserID)
+}
+
+// checkDuplicateKeys 校验批量操作中没有重复的商品规格
+func checkDuplicateKeys(keys []ItemKey) error {
+	seen := make(map[ItemKey]bool, len(keys))
+	for _, key := range keys {
+		if seen[key] {
+			return errors.New("商品重复")
+		}
+		seen[key] = true
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package cart
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"szu_market/internal/db"
+)
+
+const (
+	reconcileInterval  = 10 * time.Minute // 对账周期
+	reconcileLockTTL   = 5 * time.Minute  // 对账锁过期时间，避免多实例重复对账
+	reconcileScanCount = 200              // 每次 SCAN 的键数量
+)
+
+// ReconcileReport 一次对账的统计结果
+type ReconcileReport struct {
+	Checked  int // 检查的购物车缓存数
+	Repaired int // 内容或版本与数据库不一致并已修复的缓存数
+	Failed   int // 检查或修复失败的缓存数
+}
+
+// StartReconciler 启动定时对账（后台运行）
+func (s *CartService) StartReconciler() {
+	ticker := time.NewTicker(reconcileInterval)
+	defer ticker.Stop()
+
+	for {
+		<-ticker.C
+		s.reconcileWithLock()
+	}
+}
+
+// reconcileWithLock 获取分布式锁后对账，未抢到锁说明其他实例正在对账
+func (s *CartService) reconcileWithLock() {
+	unlock, ok, err := db.TryLock(context.Background(), "cart:reconcile:lock", reconcileLockTTL)
+	if err != nil {
+		log.Printf("WARN: 获取购物车对账锁失败: %v", err)
+		return
+	}
+	if !ok {
+		return
+	}
+	defer unlock()
+
+	start := time.Now()
+	report, err := s.Reconcile()
+	if err != nil {
+		log.Printf("购物车对账失败: %v", err)
+		return
+	}
+	log.Printf("购物车对账完成，检查 %d 个，修复 %d 个，失败 %d 个，耗时 %v",
+		report.Checked, report.Repaired, report.Failed, time.Since(start))
+}
+
+// Reconcile 扫描 Redis 中所有登录用户的购物车缓存，与 cart_items 比对并修复
+// 以数据库为准：版本或内容不一致的缓存用数据库快照覆盖
+func (s *CartService) Reconcile() (*ReconcileReport, error) {
+	ctx := context.Background()
+	report := &ReconcileReport{}
+
+	var cursor uint64
+	for {
+		keys, next, err := db.RDB.Scan(ctx, cursor, "cart:*", reconcileScanCount).Result()
+		if err != nil {
+			return report, fmt.Errorf("扫描购物车缓存失败: %w", err)
+		}
+		for _, key := range keys {
+			userID, ok := parseUserKey(key)
+			if !ok {
+				continue
+			}
+			report.Checked++
+			repaired, err := s.reconcileUser(userID)
+			if err != nil {
+				report.Failed++
+				log.Printf("WARN: 购物车对账失败 user:%d - %v", userID, err)
+				continue
+			}
+			if repaired {
+				report.Repaired++
+			}
+		}
+		cursor = next
+		if cursor == 0 {
+			return report, nil
+		}
+	}
+}
+
+// reconcileUser 比对单个用户的购物车缓存，不一致时用数据库快照覆盖，返回是否修复
+func (s *CartService) reconcileUser(userID uint) (bool, error) {
+	ctx := context.Background()
+	key := userKey(userID)
+
+	snap, err := readSnapshot(s.DB, userID)
+	if err != nil {
+		return false, err
+	}
+	cartMap, err := db.RDB.HGetAll(ctx, key).Result()
+	if err != nil {
+		return false, fmt.Errorf("读取购物车缓存失败: %w", err)
+	}
+	if len(cartMap) == 0 {
+		// 缓存已过期或被删除，读取时会重建
+		return false, nil
+	}
+
+	cached, ok := cachedVersion(cartMap)
+	if ok && cached > snap.Version {
+		// 对账期间购物车又被修改，留给下一轮检查
+		return false, nil
+	}
+	if ok && cached == snap.Version && sameEntries(cartMap, snap.entries()) {
+		return false, nil
+	}
+
+	written, err := snap.write(true)
+	if err != nil {
+		return false, fmt.Errorf("修复购物车缓存失败: %w", err)
+	}
+	if written {
+		log.Printf("购物车缓存已修复 user:%d 缓存版本:%s 数据库版本:%d", userID, cartMap[versionField], snap.Version)
+	}
+	return written, nil
+}
+
+// sameEntries 比较缓存字段与数据库快照是否一致（忽略版本字段）
+func sameEntries(cartMap, expected map[string]string) bool {
+	if len(cartMap)-1 != len(expected) {
+		return false
+	}
+	for field, value := range expected {
+		if cartMap[field] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// parseUserKey 从 cart:<user_id> 中解析用户ID，访客购物车等其他键返回 false
+func parseUserKey(key string) (uint, bool) {
+	idStr, ok := strings.CutPrefix(key, "cart:")
+	if !ok {
+		return 0, false
+	}
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil || id == 0 {
+		return 0, false
+	}
+	return uint(id), true
+}
@@ -0,0 +1,266 @@
+package cart
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"szu_market/internal/cache"
+	"szu_market/internal/db"
+	"szu_market/internal/notify"
+
+	"gorm.io/gorm"
+)
+
+// 购物车未结算提醒：定时找出长时间未修改、之后也没有下单的购物车，发送站内通知
+// 频率控制：同一版本的购物车只提醒一次，同一用户两次提醒之间至少间隔 reminderMinGap
+// 用户提醒后 reminderAttribution 内下单视为转化，记录在提醒记录上用于统计效果
+
+const (
+	reminderInterval    = time.Hour           // 提醒任务执行周期
+	reminderLockTTL     = 30 * time.Minute    // 提醒任务锁过期时间，避免多实例重复发送
+	reminderBatchSize   = 200                 // 每批处理的购物车数
+	reminderMaxIdle     = 30 * 24 * time.Hour // 闲置超过该时长的购物车不再提醒
+	reminderAttribution = 7 * 24 * time.Hour  // 提醒后多长时间内下单计为转化
+	reminderPreviewSize = 3                   // 提醒内容中列出的商品数
+
+	defaultReminderIdle   = 48 * time.Hour     // 默认闲置多久后提醒
+	defaultReminderMinGap = 7 * 24 * time.Hour // 默认两次提醒的最小间隔
+)
+
+// reminderIdle 购物车闲置多久后提醒，可通过环境变量 CART_REMINDER_IDLE_HOURS 配置
+func reminderIdle() time.Duration {
+	return envHours("CART_REMINDER_IDLE_HOURS", defaultReminderIdle)
+}
+
+// reminderMinGap 同一用户两次提醒的最小间隔，可通过环境变量 CART_REMINDER_MIN_GAP_HOURS 配置
+func reminderMinGap() time.Duration {
+	return envHours("CART_REMINDER_MIN_GAP_HOURS", defaultReminderMinGap)
+}
+
+// envHours 读取以小时为单位的环境变量，未设置或非法时使用默认值
+func envHours(name string, def time.Duration) time.Duration {
+	hours, err := strconv.Atoi(os.Getenv(name))
+	if err != nil || hours <= 0 {
+		return def
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// idleCart 待提醒的购物车
+type idleCart struct {
+	UserID  uint
+	Version uint64
+}
+
+// StartReminderScheduler 启动购物车未结算提醒任务（后台运行）
+func (s *CartService) StartReminderScheduler() {
+	ticker := time.NewTicker(reminderInterval)
+	defer ticker.Stop()
+
+	for {
+		<-ticker.C
+		s.remindWithLock()
+	}
+}
+
+// remindWithLock 获取分布式锁后发送提醒，未抢到锁说明其他实例正在执行
+func (s *CartService) remindWithLock() {
+	unlock, ok, err := db.TryLock(context.Background(), "cart:reminder:lock", reminderLockTTL)
+	if err != nil {
+		log.Printf("WARN: 获取购物车提醒锁失败: %v", err)
+		return
+	}
+	if !ok {
+		return
+	}
+	defer unlock()
+
+	start := time.Now()
+	sent, err := s.SendReminders()
+	if err != nil {
+		log.Printf("购物车提醒任务失败: %v", err)
+		return
+	}
+	log.Printf("购物车提醒任务完成，发送 %d 条，耗时 %v", sent, time.Since(start))
+}
+
+// SendReminders 向闲置购物车的用户发送提醒，返回发送的数量
+func (s *CartService) SendReminders() (int, error) {
+	now := time.Now()
+	idleBefore := now.Add(-reminderIdle())
+	idleAfter := now.Add(-reminderMaxIdle)
+	gapBefore := now.Add(-reminderMinGap())
+
+	sent := 0
+	var lastUserID uint
+	for {
+		var carts []idleCart
+		err := s.DB.Table("cart_versions AS cv").
+			Select("cv.user_id, cv.version").
+			Where("cv.user_id > ? AND cv.updated_at < ? AND cv.updated_at > ?", lastUserID, idleBefore, idleAfter).
+			Where("EXISTS (SELECT 1 FROM cart_items ci WHERE ci.user_id = cv.user_id AND ci.status = ?)", "in_cart").
+			Where("NOT EXISTS (SELECT 1 FROM users u WHERE u.user_id = cv.user_id AND u.cart_reminder_opt_out = ?)", true).
+			Where("NOT EXISTS (SELECT 1 FROM orders o WHERE o.user_id = cv.user_id AND o.created_at >= cv.updated_at)").
+			Where("NOT EXISTS (SELECT 1 FROM cart_reminders r WHERE r.user_id = cv.user_id AND (r.cart_version = cv.version OR r.sent_at > ?))", gapBefore).
+			Order("cv.user_id").Limit(reminderBatchSize).
+			Scan(&carts).Error
+		if err != nil {
+			return sent, fmt.Errorf("查询闲置购物车失败: %w", err)
+		}
+		for _, c := range carts {
+			ok, err := s.remind(c, now)
+			if err != nil {
+				log.Printf("WARN: 发送购物车提醒失败 user:%d - %v", c.UserID, err)
+				continue
+			}
+			if ok {
+				sent++
+			}
+		}
+		if len(carts) < reminderBatchSize {
+			return sent, nil
+		}
+		lastUserID = carts[len(carts)-1].UserID
+	}
+}
+
+// remind 给单个用户发送提醒并记录，返回是否发送
+// 提醒内容列出购物车中最早加入的几件可购买商品
+func (s *CartService) remind(c idleCart, now time.Time) (bool, error) {
+	var items []db.CartItem
+	if err := s.DB.Where("user_id = ? AND status = ?", c.UserID, "in_cart").
+		Order("cart_id").Find(&items).Error; err != nil {
+		return false, fmt.Errorf("查询购物车失败: %w", err)
+	}
+	if len(items) == 0 {
+		return false, nil
+	}
+
+	productIDs := make([]uint, 0, len(items))
+	for _, item := range items {
+		productIDs = append(productIDs, item.ProductID)
+	}
+	productMap, err := cache.GetProducts(s.DB, productIDs)
+	if err != nil {
+		return false, err
+	}
+	var names []string
+	var firstProductID uint
+	available, quantity := 0, 0
+	for _, item := range items {
+		p, ok := productMap[item.ProductID]
+		if !ok || p.DeletedAt.Valid || !p.IsActive || p.IsViolation {
+			continue
+		}
+		available++
+		quantity += item.Quantity
+		if firstProductID == 0 {
+			firstProductID = p.ProductID
+		}
+		if len(names) < reminderPreviewSize {
+			names = append(names, "「"+p.ProductName+"」")
+		}
+	}
+	if len(names) == 0 {
+		// 购物车中已没有可购买的商品，不发送提醒
+		return false, nil
+	}
+
+	content := fmt.Sprintf("您的购物车中还有 %d 件商品等待结算：%s", quantity, names[0])
+	for _, name := range names[1:] {
+		content += "、" + name
+	}
+	if available > len(names) {
+		content += " 等"
+	}
+
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		reminder := db.CartReminder{
+			UserID:      c.UserID,
+			CartVersion: c.Version,
+			ItemCount:   len(items),
+			SentAt:      now,
+		}
+		if err := tx.Create(&reminder).Error; err != nil {
+			return fmt.Errorf("记录购物车提醒失败: %w", err)
+		}
+		return notify.Send(tx, []db.Notification{{
+			UserID:    c.UserID,
+			Type:      notify.TypeCartReminder,
+			Title:     "购物车商品还未结算",
+			Content:   content,
+			ProductID: firstProductID,
+		}})
+	})
+	return err == nil, err
+}
+
+// RecordReminderConversion 用户下单后，把归因期内最近一次未转化的提醒标记为已转化
+func RecordReminderConversion(database *gorm.DB, userID, orderID uint) error {
+	now := time.Now()
+	var reminder db.CartReminder
+	if err := database.Where("user_id = ? AND order_id = ? AND sent_at > ?", userID, 0, now.Add(-reminderAttribution)).
+		Order("sent_at DESC").Limit(1).Find(&reminder).Error; err != nil {
+		return fmt.Errorf("查询购物车提醒失败: %w", err)
+	}
+	if reminder.ReminderID == 0 {
+		return nil
+	}
+	if err := database.Model(&db.CartReminder{}).
+		Where("reminder_id = ? AND order_id = ?", reminder.ReminderID, 0).
+		Updates(map[string]interface{}{"order_id": orderID, "converted_at": now}).Error; err != nil {
+		return fmt.Errorf("记录提醒转化失败: %w", err)
+	}
+	return nil
+}
+
+// SetReminderOptOut 设置用户是否接收购物车未结算提醒
+func (s *CartService) SetReminderOptOut(userID uint, optOut bool) error {
+	if userID == 0 {
+		return errors.New("用户未登录")
+	}
+	result := s.DB.Model(&db.User{}).Where("user_id = ?", userID).Update("cart_reminder_opt_out", optOut)
+	if result.Error != nil {
+		return fmt.Errorf("更新提醒设置失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		var count int64
+		if err := s.DB.Model(&db.User{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+			return fmt.Errorf("查询用户失败: %w", err)
+		}
+		if count == 0 {
+			return errors.New("用户不存在")
+		}
+	}
+	return nil
+}
+
+// ReminderStats 一段时间内的提醒效果统计
+type ReminderStats struct {
+	Days           int     `json:"days"`
+	Sent           int64   `json:"sent"`
+	Converted      int64   `json:"converted"`
+	ConversionRate float64 `json:"conversion_rate"`
+}
+
+// GetReminderStats 统计最近 days 天发送的提醒数量与转化率
+func (s *CartService) GetReminderStats(days int) (*ReminderStats, error) {
+	stats := &ReminderStats{Days: days}
+	since := time.Now().AddDate(0, 0, -days)
+	err := s.DB.Model(&db.CartReminder{}).
+		Select("COUNT(*) AS sent, COALESCE(SUM(order_id > 0), 0) AS converted").
+		Where("sent_at > ?", since).
+		Scan(stats).Error
+	if err != nil {
+		return nil, fmt.Errorf("统计购物车提醒失败: %w", err)
+	}
+	if stats.Sent > 0 {
+		stats.ConversionRate = float64(stats.Converted) / float64(stats.Sent)
+	}
+	return stats, nil
+}
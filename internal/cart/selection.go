@@ -0,0 +1,174 @@
+package cart
+
+import (
+	"errors"
+	"fmt"
+
+	"szu_market/internal/db"
+
+	"gorm.io/gorm"
+)
+
+// SelectInput 勾选或取消勾选购物车项的输入
+type SelectInput struct {
+	UserID    uint `json:"user_id"`
+	ProductID uint `json:"product_id"`
+	SKUID     uint `json:"sku_id"`
+	Selected  bool `json:"selected"`
+}
+
+// SetSelected 勾选或取消勾选单个购物车项
+func (s *CartService) SetSelected(input *SelectInput) error {
+	if input.UserID == 0 || input.ProductID == 0 {
+		return errors.New("无效的用户或商品ID")
+	}
+
+	return s.mutate(input.UserID, func(tx *gorm.DB) error {
+		result := tx.Model(&db.CartItem{}).
+			Where("user_id = ? AND product_id = ? AND sku_id = ? AND status = ?",
+				input.UserID, input.ProductID, input.SKUID, "in_cart").
+			Update("selected", input.Selected)
+		if result.Error != nil {
+			return fmt.Errorf("更新勾选状态失败: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			// 状态未变化时 RowsAffected 也为 0，需确认购物车项是否存在
+			var count int64
+			if err := tx.Model(&db.CartItem{}).
+				Where("user_id = ? AND product_id = ? AND sku_id = ? AND status = ?",
+					input.UserID, input.ProductID, input.SKUID, "in_cart").
+				Count(&count).Error; err != nil {
+				return fmt.Errorf("查询购物车失败: %w", err)
+			}
+			if count == 0 {
+				return errors.New("购物车中未找到该商品")
+			}
+		}
+		return nil
+	})
+}
+
+// SelectAll 勾选或取消勾选购物车中的全部商品
+func (s *CartService) SelectAll(userID uint, selected bool) error {
+	if userID == 0 {
+		return errors.New("用户未登录")
+	}
+	return s.mutate(userID, func(tx *gorm.DB) error {
+		if err := tx.Model(&db.CartItem{}).
+			Where("user_id = ? AND status = ?", userID, "in_cart").
+			Update("selected", selected).Error; err != nil {
+			return fmt.Errorf("更新勾选状态失败: %w", err)
+		}
+		return nil
+	})
+}
+
+// SelectBySeller 勾选或取消勾选购物车中某个卖家的全部商品
+func (s *CartService) SelectBySeller(userID, sellerID uint, selected bool) error {
+	if userID == 0 {
+		return errors.New("用户未登录")
+	}
+	if sellerID == 0 {
+		return errors.New("卖家ID无效")
+	}
+	// 已删除的商品仍属于原卖家，一并处理
+	return s.mutate(userID, func(tx *gorm.DB) error {
+		sellerProducts := tx.Unscoped().Model(&db.SpecialProduct{}).
+			Select("product_id").Where("user_id = ?", sellerID)
+		if err := tx.Model(&db.CartItem{}).
+			Where("user_id = ? AND status = ? AND product_id IN (?)", userID, "in_cart", sellerProducts).
+			Update("selected", selected).Error; err != nil {
+			return fmt.Errorf("更新勾选状态失败: %w", err)
+		}
+		return nil
+	})
+}
+
+// summarize 汇总已勾选且可购买的商品数量与金额
+// 节省金额按商品最近一次降价前的价格计算，只统计当前仍处于该降价价格的商品
+func (s *CartService) summarize(items []CartItemResponse) (*CartSummary, error) {
+	summary := &CartSummary{TotalCount: len(items), AllSelected: true}
+
+	var subtotal int64
+	var selected []CartItemResponse
+	purchasable := 0
+	for _, item := range items {
+		if item.Status == StatusPriceChanged {
+			summary.PriceChanged++
+		}
+		if !item.Purchasable {
+			summary.Unavailable++
+			continue
+		}
+		purchasable++
+		if !item.Selected {
+			summary.AllSelected = false
+			continue
+		}
+		price, err := db.PriceToCents(item.Price)
+		if err != nil {
+			return nil, fmt.Errorf("商品 %d 价格无效: %w", item.ProductID, err)
+		}
+		subtotal += price * int64(item.Quantity)
+		summary.SelectedCount++
+		summary.SelectedQuantity += item.Quantity
+		selected = append(selected, item)
+	}
+	if purchasable == 0 {
+		summary.AllSelected = false
+	}
+	summary.Subtotal = db.CentsToPrice(subtotal)
+
+	savings, err := s.priceDropSavings(selected)
+	if err != nil {
+		return nil, err
+	}
+	summary.Savings = db.CentsToPrice(savings)
+	return summary, nil
+}
+
+// priceDropSavings 计算商品相对最近一次降价前价格节省的金额（单位：分）
+func (s *CartService) priceDropSavings(items []CartItemResponse) (int64, error) {
+	if len(items) == 0 {
+		return 0, nil
+	}
+	pairs := make([][]interface{}, 0, len(items))
+	for _, item := range items {
+		pairs = append(pairs, []interface{}{item.ProductID, item.SKUID})
+	}
+	latest := s.DB.Model(&db.PriceHistory{}).
+		Select("MAX(history_id)").
+		Where("(product_id, sku_id) IN ?", pairs).
+		Group("product_id, sku_id")
+	var histories []db.PriceHistory
+	if err := s.DB.Where("history_id IN (?)", latest).Find(&histories).Error; err != nil {
+		return 0, fmt.Errorf("查询价格历史失败: %w", err)
+	}
+
+	previous := make(map[ItemKey]db.PriceHistory, len(histories))
+	for _, h := range histories {
+		previous[ItemKey{ProductID: h.ProductID, SKUID: h.SKUID}] = h
+	}
+
+	var savings int64
+	for _, item := range items {
+		h, ok := previous[ItemKey{ProductID: item.ProductID, SKUID: item.SKUID}]
+		if !ok {
+			continue
+		}
+		oldCents, err := db.PriceToCents(h.OldPrice)
+		if err != nil {
+			continue
+		}
+		newCents, err := db.PriceToCents(h.NewPrice)
+		if err != nil {
+			continue
+		}
+		current, err := db.PriceToCents(item.Price)
+		if err != nil || current != newCents || oldCents <= newCents {
+			continue
+		}
+		savings += (oldCents - newCents) * int64(item.Quantity)
+	}
+	return savings, nil
+}
@@ -5,8 +5,12 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"sort"
 	"strconv"
+	"strings"
+	"szu_market/internal/cache"
 	"szu_market/internal/db"
+	"szu_market/internal/limit"
 	"time"
 
 	"gorm.io/gorm"
@@ -24,102 +28,345 @@ func NewCartService(db *gorm.DB) *CartService {
 
 // CartItemResponse 购物车项响应结构
 type CartItemResponse struct {
-	CartID             uint   `json:"cart_id"`
-	ProductID          uint   `json:"product_id"`
-	ProductName        string `json:"product_name"`
-	ProductDescription string `json:"product_description"`
-	Price              string `json:"price"`
-	Quantity           int    `json:"quantity"`
-	ImageURL           string `json:"image_url"`
+	CartID             uint             `json:"cart_id"`
+	ProductID          uint             `json:"product_id"`
+	SKUID              uint             `gorm:"column:sku_id" json:"sku_id"`
+	SKUAttributes      db.SKUAttributes `json:"sku_attributes,omitempty"`
+	ProductName        string           `json:"product_name"`
+	ProductDescription string           `json:"product_description"`
+	Price              string           `json:"price"`
+	Quantity           int              `json:"quantity"`
+	ImageURL           string           `json:"image_url"`
+	IsRemoved          bool             `json:"is_removed"` // 商品已被卖家删除
+	SellerID           uint             `json:"seller_id"`
+	Selected           bool             `json:"selected"`        // 是否勾选结算
+	Purchasable        bool             `json:"purchasable"`     // 状态为 ok 或 price_changed 时可以结算
+	PriceAtAdd         string           `json:"price_at_add"`    // 加入购物车时的单价，旧数据可能为空
+	Stock              *int             `json:"stock,omitempty"` // 规格当前库存，无规格商品不返回
+	Status             string           `json:"status"`          // 见 Status* 常量
 }
 
-// GetCartItems 获取用户购物车项
-func (s *CartService) GetCartItems(userID uint) ([]CartItemResponse, error) {
-	key := fmt.Sprintf("cart:%d", userID)
+// 购物车项状态
+const (
+	StatusOK                = "ok"                 // 正常
+	StatusPriceChanged      = "price_changed"      // 价格与加入购物车时不同
+	StatusUnavailable       = "unavailable"        // 商品已下架、违规、删除或规格失效
+	StatusInsufficientStock = "insufficient_stock" // 库存不足购物车中的数量
+)
+
+// CartSummary 购物车结算汇总，金额与件数只统计已勾选且可购买的商品
+type CartSummary struct {
+	TotalCount       int    `json:"total_count"`       // 购物车商品种类数
+	SelectedCount    int    `json:"selected_count"`    // 已勾选的可购买商品种类数
+	SelectedQuantity int    `json:"selected_quantity"` // 已勾选的可购买商品件数
+	Subtotal         string `json:"subtotal"`          // 按当前价格计算的金额
+	Savings          string `json:"savings"`           // 相比降价前价格节省的金额
+	AllSelected      bool   `json:"all_selected"`      // 可购买的商品是否已全部勾选
+	PriceChanged     int    `json:"price_changed"`     // 价格与加入时不同的商品种类数
+	Unavailable      int    `json:"unavailable"`       // 已失效或库存不足、无法结算的商品种类数
+}
+
+// CartResponse 购物车响应结构
+type CartResponse struct {
+	Items   []CartItemResponse `json:"items"`
+	Summary CartSummary        `json:"summary"`
+}
+
+// cartField 生成购物车哈希中的字段名：无规格为 "<product_id>"，有规格为 "<product_id>:<sku_id>"
+func cartField(productID, skuID uint) string {
+	if skuID == 0 {
+		return strconv.FormatUint(uint64(productID), 10)
+	}
+	return fmt.Sprintf("%d:%d", productID, skuID)
+}
+
+// parseCartField 解析购物车哈希字段名
+func parseCartField(field string) (productID, skuID uint, err error) {
+	pidStr, skuStr, hasSKU := strings.Cut(field, ":")
+	pid, err := strconv.ParseUint(pidStr, 10, 32)
+	if err != nil {
+		return 0, 0, fmt.Errorf("无效的购物车字段: %s", field)
+	}
+	if !hasSKU {
+		return uint(pid), 0, nil
+	}
+	sku, err := strconv.ParseUint(skuStr, 10, 32)
+	if err != nil {
+		return 0, 0, fmt.Errorf("无效的购物车字段: %s", field)
+	}
+	return uint(pid), uint(sku), nil
+}
+
+// cartEntry 购物车哈希中的值，格式为 "<quantity>:<selected>:<cart_id>:<price_at_add>"
+// selected 为 1 或 0；访客购物车的 cart_id 为 0；旧格式缺少的部分按零值处理
+type cartEntry struct {
+	Quantity   int
+	Selected   bool
+	CartID     uint
+	PriceAtAdd string
+}
 
+func (e cartEntry) String() string {
+	return fmt.Sprintf("%d:%d:%d:%s", e.Quantity, boolFlag(e.Selected), e.CartID, e.PriceAtAdd)
+}
+
+// entryOf 数据库购物车项对应的缓存值
+func entryOf(item db.CartItem) cartEntry {
+	e := cartEntry{Quantity: item.Quantity, Selected: item.Selected, CartID: item.CartID}
+	if cents, err := db.PriceToCents(item.PriceAtAdd); err == nil && cents > 0 {
+		e.PriceAtAdd = db.CentsToPrice(cents)
+	}
+	return e
+}
+
+// parseCartEntry 解析购物车哈希中的值
+func parseCartEntry(value string) (cartEntry, error) {
+	var e cartEntry
+	parts := strings.SplitN(value, ":", 4)
+	if len(parts) < 2 {
+		return e, fmt.Errorf("无效的购物车数据: %s", value)
+	}
+	quantity, err := strconv.Atoi(parts[0])
+	if err != nil || quantity <= 0 {
+		return e, fmt.Errorf("无效的购物车数据: %s", value)
+	}
+	e.Quantity = quantity
+	e.Selected = parts[1] != "0"
+	if len(parts) >= 3 {
+		id, err := strconv.ParseUint(parts[2], 10, 32)
+		if err != nil {
+			return e, fmt.Errorf("无效的购物车数据: %s", value)
+		}
+		e.CartID = uint(id)
+	}
+	if len(parts) == 4 && parts[3] != "" {
+		if _, err := db.PriceToCents(parts[3]); err != nil {
+			return e, fmt.Errorf("无效的购物车数据: %s", value)
+		}
+		e.PriceAtAdd = parts[3]
+	}
+	return e, nil
+}
+
+// ItemKey 购物车项的唯一标识（商品 + 规格）
+type ItemKey struct {
+	ProductID uint `json:"product_id"`
+	SKUID     uint `json:"sku_id"`
+}
+
+// GetCartItems 获取用户购物车项及已勾选商品的结算汇总
+func (s *CartService) GetCartItems(userID uint) (*CartResponse, error) {
+	items, err := s.loadCartItems(userID)
+	if err != nil {
+		return nil, err
+	}
+	summary, err := s.summarize(items)
+	if err != nil {
+		return nil, err
+	}
+	if items == nil {
+		items = []CartItemResponse{}
+	}
+	return &CartResponse{Items: items, Summary: *summary}, nil
+}
+
+// loadCartItems 优先从 Redis 读取购物车，缓存版本与数据库一致时才使用缓存，否则从数据库加载并回填缓存
+func (s *CartService) loadCartItems(userID uint) ([]CartItemResponse, error) {
 	start := time.Now()
 	// 1. 先尝试从Redis获取完整购物车
-	if cartMap, err := db.RDB.HGetAll(context.Background(), key).Result(); err == nil && len(cartMap) > 0 {
-		return s.buildCartFromRedis(userID, cartMap)
+	version, err := currentVersion(s.DB, userID)
+	if err != nil {
+		return nil, err
+	}
+	cartMap, err := db.RDB.HGetAll(context.Background(), userKey(userID)).Result()
+	if err != nil {
+		log.Printf("WARN: 读取购物车缓存失败 user:%d - %v", userID, err)
+	} else if cached, ok := cachedVersion(cartMap); ok && version != 0 && cached == version {
+		delete(cartMap, versionField)
+		items, err := s.buildCartItems(cartMap)
+		if err == nil {
+			return items, nil
+		}
+		log.Printf("WARN: 购物车缓存数据无效 user:%d - %v", userID, err)
 	}
 	duration := time.Since(start)
 	fmt.Printf("Redis响应时间: %v\n", duration)
 
 	start = time.Now()
-	// 2. Redis无数据时从数据库加载
-	var results []CartItemResponse
-	err := s.DB.Table("cart_items").
-		Select("cart_items.cart_id, special_products.product_id, special_products.product_name, "+
-			"special_products.product_description, special_products.price, special_products.image_url, "+
-			"cart_items.quantity").
-		Joins("JOIN special_products ON cart_items.product_id = special_products.product_id").
-		Where("cart_items.user_id = ? AND cart_items.status = ?", userID, "in_cart").
-		Scan(&results).Error
-
+	// 2. 缓存缺失或过期时从数据库加载
+	snap, err := readSnapshot(s.DB, userID)
 	if err != nil {
-		return nil, fmt.Errorf("查询失败: %w", err)
+		return nil, err
+	}
+	results, err := s.buildCartItems(snap.entries())
+	if err != nil {
+		return nil, err
 	}
 	duration = time.Since(start)
 	fmt.Printf("Mysql响应时间: %v\n", duration)
 
-	// 3. 将数据库数据写入Redis缓存
-	go s.cacheCartItems(userID, results)
+	// 3. 将快照写入Redis缓存
+	go snap.Apply()
 
 	return results, nil
 }
 
-// 将DB结果缓存到Redis
-func (s *CartService) cacheCartItems(userID uint, items []CartItemResponse) {
-	key := fmt.Sprintf("cart:%d", userID)
-	pipe := db.RDB.Pipeline()
-
-	for _, item := range items {
-		pipe.HSet(context.Background(), key,
-			strconv.Itoa(int(item.ProductID)),
-			item.Quantity)
+// buildCartItems 根据购物车哈希内容构建响应，商品详情通过商品缓存获取
+func (s *CartService) buildCartItems(cartMap map[string]string) ([]CartItemResponse, error) {
+	keys := make(map[string]ItemKey, len(cartMap))
+	entries := make(map[string]cartEntry, len(cartMap))
+	var productIDs []uint
+	for field, value := range cartMap {
+		pid, skuID, err := parseCartField(field)
+		if err != nil {
+			return nil, errCacheCorrupted
+		}
+		entry, err := parseCartEntry(value)
+		if err != nil {
+			return nil, errCacheCorrupted
+		}
+		keys[field] = ItemKey{ProductID: pid, SKUID: skuID}
+		entries[field] = entry
+		productIDs = append(productIDs, pid)
 	}
-	pipe.Expire(context.Background(), key, 24*time.Hour)
-	_, err := pipe.Exec(context.Background()) // 传入 context
+
+	// 通过商品缓存获取详情（包含已删除的商品，以便标记而不是丢弃）
+	productMap, err := cache.GetProducts(s.DB, productIDs)
 	if err != nil {
-		log.Printf("WARN: Redis pipeline execution failed: %v", err)
+		return nil, err
 	}
+
+	// 构建响应
+	results := make([]CartItemResponse, 0, len(cartMap))
+	for field, entry := range entries {
+		key := keys[field]
+		item := CartItemResponse{
+			CartID:     entry.CartID,
+			ProductID:  key.ProductID,
+			SKUID:      key.SKUID,
+			Quantity:   entry.Quantity,
+			Selected:   entry.Selected,
+			PriceAtAdd: entry.PriceAtAdd,
+		}
+		if p, ok := productMap[key.ProductID]; ok {
+			fillItem(&item, &p)
+		} else {
+			// 商品记录已不存在，保留条目并标记为已删除
+			item.IsRemoved = true
+			item.Status = StatusUnavailable
+		}
+		results = append(results, item)
+	}
+
+	// 哈希遍历顺序不固定，按加入顺序排列；访客购物车没有 cart_id，按商品排列
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].CartID != results[j].CartID {
+			return results[i].CartID < results[j].CartID
+		}
+		if results[i].ProductID != results[j].ProductID {
+			return results[i].ProductID < results[j].ProductID
+		}
+		return results[i].SKUID < results[j].SKUID
+	})
+	return results, nil
 }
 
-// 从Redis数据构建响应
-func (s *CartService) buildCartFromRedis(userID uint, cartMap map[string]string) ([]CartItemResponse, error) {
-	var productIDs []uint
-	for pid := range cartMap {
-		id, _ := strconv.ParseUint(pid, 10, 32)
-		productIDs = append(productIDs, uint(id))
+// hasActiveSKU 商品是否有上架的规格，有时必须选择规格才能购买
+func hasActiveSKU(p *db.SpecialProduct) bool {
+	for _, sku := range p.SKUs {
+		if sku.IsActive {
+			return true
+		}
 	}
+	return false
+}
 
-	// 从数据库获取商品详情
-	var products []db.SpecialProduct
-	if err := s.DB.Where("product_id IN ?", productIDs).Find(&products).Error; err != nil {
-		return nil, err
+// fillItem 填充商品信息并根据商品、规格当前状态标注购物车项状态
+func fillItem(item *CartItemResponse, p *db.SpecialProduct) {
+	item.ProductName = p.ProductName
+	item.ProductDescription = p.ProductDescription
+	item.Price = p.Price
+	item.ImageURL = p.ImageURL
+	item.IsRemoved = p.DeletedAt.Valid
+	item.SellerID = p.UserID
+
+	var sku *db.ProductSKU
+	for i := range p.SKUs {
+		if p.SKUs[i].SKUID == item.SKUID {
+			sku = &p.SKUs[i]
+		}
+	}
+	if sku != nil {
+		item.SKUAttributes = sku.Attributes
+		item.Price = sku.Price
+		if sku.ImageURL != "" {
+			item.ImageURL = sku.ImageURL
+		}
+		stock := sku.Stock
+		item.Stock = &stock
 	}
 
-	// 构建响应
-	var results []CartItemResponse
-	for _, p := range products {
-		qty, _ := strconv.Atoi(cartMap[strconv.Itoa(int(p.ProductID))])
-		results = append(results, CartItemResponse{
-			ProductID:          p.ProductID,
-			ProductName:        p.ProductName,
-			ProductDescription: p.ProductDescription,
-			Price:              p.Price,
-			ImageURL:           p.ImageURL,
-			Quantity:           qty,
-		})
+	switch {
+	case p.DeletedAt.Valid || !p.IsActive || p.IsViolation:
+		item.Status = StatusUnavailable
+	case item.SKUID != 0 && (sku == nil || !sku.IsActive):
+		item.Status = StatusUnavailable
+	case item.SKUID == 0 && hasActiveSKU(p):
+		// 商品加入购物车后新增了规格，需要重新选择规格
+		item.Status = StatusUnavailable
+	case sku != nil && sku.Stock < item.Quantity:
+		item.Status = StatusInsufficientStock
+	case item.PriceAtAdd != "" && !samePrice(item.PriceAtAdd, item.Price):
+		item.Status = StatusPriceChanged
+	default:
+		item.Status = StatusOK
 	}
+	item.Purchasable = item.Status == StatusOK || item.Status == StatusPriceChanged
+}
 
-	return results, nil
+// PurchasableItems 筛选出可以结算的购物车项，规则与购物车结算汇总一致（见 fillItem）
+func PurchasableItems(database *gorm.DB, items []db.CartItem) ([]db.CartItem, error) {
+	productIDs := make([]uint, 0, len(items))
+	for _, item := range items {
+		productIDs = append(productIDs, item.ProductID)
+	}
+	productMap, err := cache.GetProducts(database, productIDs)
+	if err != nil {
+		return nil, err
+	}
+	purchasable := make([]db.CartItem, 0, len(items))
+	for _, item := range items {
+		p, ok := productMap[item.ProductID]
+		if !ok {
+			continue
+		}
+		view := CartItemResponse{ProductID: item.ProductID, SKUID: item.SKUID, Quantity: item.Quantity}
+		fillItem(&view, &p)
+		if view.Purchasable {
+			purchasable = append(purchasable, item)
+		}
+	}
+	return purchasable, nil
+}
+
+// samePrice 比较两个金额字符串是否相等，无法解析时视为相等
+func samePrice(a, b string) bool {
+	ac, err := db.PriceToCents(a)
+	if err != nil {
+		return true
+	}
+	bc, err := db.PriceToCents(b)
+	if err != nil {
+		return true
+	}
+	return ac == bc
 }
 
 // AddToCartInput 添加到购物车输入
 type AddToCartInput struct {
 	UserID    uint `json:"user_id"`
 	ProductID uint `json:"product_id"`
+	SKUID     uint `json:"sku_id"`
 	Quantity  int  `json:"quantity"`
 }
 
@@ -141,40 +388,103 @@ func (s *CartService) AddToCart(input *AddToCartInput) error {
 		}
 		return fmt.Errorf("query product failed: %w", err)
 	}
+	sku, err := s.checkSKU(input.ProductID, input.SKUID)
+	if err != nil {
+		return err
+	}
+
+	// 3. 更新数据库 (使用原子操作避免并发问题)，提交后同步Redis
+	return s.mutate(input.UserID, func(tx *gorm.DB) error {
+		return s.addItem(tx, input.UserID, &product, sku, input.Quantity)
+	})
+}
 
-	// 3. 更新数据库 (使用原子操作避免并发问题)
-	result := s.DB.Exec(`
-        INSERT INTO cart_items (user_id, product_id, quantity, status) 
-        VALUES (?, ?, ?, 'in_cart')
-        ON DUPLICATE KEY UPDATE quantity = quantity + ?`,
-		input.UserID, input.ProductID, input.Quantity, input.Quantity,
+// addItem 在事务中把商品加入购物车，已在购物车中时累加数量
+func (s *CartService) addItem(tx *gorm.DB, userID uint, product *db.SpecialProduct, sku *db.ProductSKU, quantity int) error {
+	if err := s.checkLimit(tx, userID, product, nil, quantity); err != nil {
+		return err
+	}
+	var skuID uint
+	if sku != nil {
+		skuID = sku.SKUID
+	}
+	// 记录本次加入时的单价，再次加入视为买家已确认当前价格
+	price := unitPrice(product, sku)
+	result := tx.Exec(`
+        INSERT INTO cart_items (user_id, product_id, sku_id, quantity, status, price_at_add) 
+        VALUES (?, ?, ?, ?, 'in_cart', ?)
+        ON DUPLICATE KEY UPDATE quantity = IF(status = 'in_cart', quantity + ?, ?), status = 'in_cart', selected = TRUE,
+            price_at_add = ?`,
+		userID, product.ProductID, skuID, quantity, price, quantity, quantity, price,
 	)
 	if result.Error != nil {
 		return fmt.Errorf("update DB failed: %w", result.Error)
 	}
+	return nil
+}
+
+// checkLimit 校验限购：已购买件数、购物车中该商品各规格的数量与本次数量之和不能超过限购
+// replaceSKU 不为 nil 时，该规格的购物车数量将被 quantity 替换，不再计入购物车已有数量
+func (s *CartService) checkLimit(tx *gorm.DB, userID uint, product *db.SpecialProduct, replaceSKU *uint, quantity int) error {
+	if product.PurchaseLimit == 0 {
+		return nil
+	}
+	if err := limit.Lock(tx, userID); err != nil {
+		return err
+	}
+	query := tx.Model(&db.CartItem{}).
+		Where("user_id = ? AND product_id = ? AND status = ?", userID, product.ProductID, "in_cart")
+	if replaceSKU != nil {
+		query = query.Where("sku_id <> ?", *replaceSKU)
+	}
+	var pending int64
+	if err := query.Select("COALESCE(SUM(quantity), 0)").Scan(&pending).Error; err != nil {
+		return fmt.Errorf("查询购物车失败: %w", err)
+	}
+	return limit.Check(tx, userID, product, pending, int64(quantity))
+}
 
-	// 4. 更新Redis (原子操作)
-	key := fmt.Sprintf("cart:%d", input.UserID)
-	if err := db.RDB.HIncrBy(
-		context.Background(),
-		key,
-		strconv.FormatUint(uint64(input.ProductID), 10),
-		int64(input.Quantity),
-	).Err(); err != nil {
-		log.Printf("WARN: update redis failed: %v", err)
-		// 可加入重试机制或异步修复队列
+// checkSKU 校验规格：有规格的商品必须选择有效规格，返回选择的规格（无规格时为 nil）
+func (s *CartService) checkSKU(productID, skuID uint) (*db.ProductSKU, error) {
+	if skuID == 0 {
+		var count int64
+		if err := s.DB.Model(&db.ProductSKU{}).
+			Where("product_id = ? AND is_active = ?", productID, true).
+			Count(&count).Error; err != nil {
+			return nil, fmt.Errorf("query sku failed: %w", err)
+		}
+		if count > 0 {
+			return nil, errors.New("please select a sku")
+		}
+		return nil, nil
 	}
 
-	// 5. 设置缓存过期时间
-	db.RDB.Expire(context.Background(), key, 7*24*time.Hour)
+	var sku db.ProductSKU
+	if err := s.DB.Where("sku_id = ? AND product_id = ?", skuID, productID).First(&sku).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("sku not exist")
+		}
+		return nil, fmt.Errorf("query sku failed: %w", err)
+	}
+	if !sku.IsActive {
+		return nil, errors.New("sku not available")
+	}
+	return &sku, nil
+}
 
-	return nil
+// unitPrice 商品或所选规格的当前单价
+func unitPrice(product *db.SpecialProduct, sku *db.ProductSKU) string {
+	if sku != nil {
+		return sku.Price
+	}
+	return product.Price
 }
 
 // RemoveCartItemInput 删除购物车项输入
 type RemoveCartItemInput struct {
 	UserID    uint `json:"user_id"`
 	ProductID uint `json:"product_id"`
+	SKUID     uint `json:"sku_id"`
 }
 
 // RemoveCartItem 从购物车中删除商品
@@ -183,31 +493,22 @@ func (s *CartService) RemoveCartItem(input *RemoveCartItemInput) error {
 		return errors.New("无效的用户或商品ID")
 	}
 
-	// 先操作数据库
-	if err := s.DB.Where("user_id = ? AND product_id = ? AND status = ?",
-		input.UserID, input.ProductID, "in_cart").
-		Delete(&db.CartItem{}).Error; err != nil {
-		return fmt.Errorf("删除购物车项失败: %w", err)
-	}
-
-	// 再操作Redis
-	key := fmt.Sprintf("cart:%d", input.UserID)
-	if err := db.RDB.HDel(
-		context.Background(),
-		key,
-		strconv.Itoa(int(input.ProductID)),
-	).Err(); err != nil {
-		log.Printf("WARN: Redis删除失败 user:%d product:%d - %v",
-			input.UserID, input.ProductID, err)
-	}
-
-	return nil
+	// 先操作数据库，提交后同步Redis
+	return s.mutate(input.UserID, func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ? AND product_id = ? AND sku_id = ? AND status = ?",
+			input.UserID, input.ProductID, input.SKUID, "in_cart").
+			Delete(&db.CartItem{}).Error; err != nil {
+			return fmt.Errorf("删除购物车项失败: %w", err)
+		}
+		return nil
+	})
 }
 
 // UpdateCartItemQuantityInput 更新购物车项数量输入
 type UpdateCartItemQuantityInput struct {
 	UserID    uint `json:"user_id"`
 	ProductID uint `json:"product_id"`
+	SKUID     uint `json:"sku_id"`
 	Quantity  int  `json:"quantity"`
 }
 
@@ -220,30 +521,41 @@ func (s *CartService) UpdateCartItemQuantity(input *UpdateCartItemQuantityInput)
 		return errors.New("数量必须大于0")
 	}
 
-	// 先更新数据库
-	result := s.DB.Model(&db.CartItem{}).
-		Where("user_id = ? AND product_id = ? AND status = ?",
-			input.UserID, input.ProductID, "in_cart").
-		Update("quantity", input.Quantity)
+	// 先更新数据库，提交后同步Redis
+	return s.mutate(input.UserID, func(tx *gorm.DB) error {
+		if err := s.checkQuantityLimit(tx, input); err != nil {
+			return err
+		}
+		result := tx.Model(&db.CartItem{}).
+			Where("user_id = ? AND product_id = ? AND sku_id = ? AND status = ?",
+				input.UserID, input.ProductID, input.SKUID, "in_cart").
+			Update("quantity", input.Quantity)
 
-	if result.Error != nil {
-		return fmt.Errorf("更新数量失败: %w", result.Error)
+		if result.Error != nil {
+			return fmt.Errorf("更新数量失败: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("购物车中未找到该商品")
+		}
+		return nil
+	})
+}
+
+// checkQuantityLimit 调大购物车数量时校验限购，调小数量总是允许
+func (s *CartService) checkQuantityLimit(tx *gorm.DB, input *UpdateCartItemQuantityInput) error {
+	var item db.CartItem
+	if err := tx.Where("user_id = ? AND product_id = ? AND sku_id = ? AND status = ?",
+		input.UserID, input.ProductID, input.SKUID, "in_cart").
+		Limit(1).Find(&item).Error; err != nil {
+		return fmt.Errorf("查询购物车失败: %w", err)
 	}
-	if result.RowsAffected == 0 {
-		return errors.New("购物车中未找到该商品")
+	if item.CartID == 0 || input.Quantity <= item.Quantity {
+		return nil
 	}
-
-	// 再更新Redis
-	key := fmt.Sprintf("cart:%d", input.UserID)
-	if err := db.RDB.HSet(
-		context.Background(),
-		key,
-		strconv.Itoa(int(input.ProductID)),
-		input.Quantity,
-	).Err(); err != nil {
-		log.Printf("WARN: Redis更新失败 user:%d product:%d - %v",
-			input.UserID, input.ProductID, err)
+	var product db.SpecialProduct
+	if err := tx.Unscoped().Select("product_id", "product_name", "purchase_limit", "limit_window_days").
+		Where("product_id = ?", input.ProductID).Limit(1).Find(&product).Error; err != nil {
+		return fmt.Errorf("query product failed: %w", err)
 	}
-
-	return nil
+	return s.checkLimit(tx, input.UserID, &product, &input.SKUID, input.Quantity)
 }
@@ -0,0 +1,175 @@
+package cart
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"szu_market/internal/db"
+
+	"github.com/go-redis/redis/v8"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 数据一致性约定：
+//   - cart_items 是购物车的唯一数据源，Redis 中的 cart:<user_id> 哈希只是缓存；
+//   - 每次修改购物车都在同一事务中递增 cart_versions 中的版本号，并读取修改后的完整快照；
+//   - 事务提交后用 Lua 脚本以快照整体替换 Redis 哈希，哈希中的 _v 字段记录快照版本，
+//     脚本只接受比缓存更新的版本，并发写入不会让旧快照覆盖新快照；
+//   - 读取时只有 _v 与数据库版本一致的缓存才会被使用，否则从数据库重建；
+//   - Redis 写入失败只影响缓存命中率，不影响正确性，残留的不一致由定时对账任务修复。
+
+const (
+	versionField = "_v"               // 购物车哈希中记录快照版本的字段
+	cacheTTL     = 7 * 24 * time.Hour // 购物车缓存过期时间
+)
+
+// errCacheCorrupted 缓存中存在无法解析的字段
+var errCacheCorrupted = errors.New("购物车缓存数据无效")
+
+// replaceScript 用快照替换购物车哈希
+// ARGV[1] 快照版本，ARGV[2] 过期秒数，ARGV[3] 为 1 时允许覆盖同版本缓存（对账修复用），其后为字段、值对
+var replaceScript = redis.NewScript(`
+local cur = tonumber(redis.call('HGET', KEYS[1], '_v') or '0')
+local ver = tonumber(ARGV[1])
+if cur > ver or (cur == ver and ARGV[3] ~= '1') then return 0 end
+redis.call('DEL', KEYS[1])
+redis.call('HSET', KEYS[1], '_v', ARGV[1])
+for i = 4, #ARGV, 2 do
+  redis.call('HSET', KEYS[1], ARGV[i], ARGV[i + 1])
+end
+redis.call('EXPIRE', KEYS[1], ARGV[2])
+return 1
+`)
+
+func userKey(userID uint) string {
+	return fmt.Sprintf("cart:%d", userID)
+}
+
+// Snapshot 某个版本的用户购物车内容
+type Snapshot struct {
+	UserID  uint
+	Version uint64
+	Items   []db.CartItem
+}
+
+// Commit 在修改购物车的事务中调用：递增购物车版本并读取修改后的快照
+// 版本行的写锁会持续到事务结束，同一用户的购物车修改因此串行执行
+// 事务提交后需调用 Apply 将快照写入 Redis
+func Commit(tx *gorm.DB, userID uint) (*Snapshot, error) {
+	if err := tx.Exec(`
+        INSERT INTO cart_versions (user_id, version, updated_at) VALUES (?, 1, NOW())
+        ON DUPLICATE KEY UPDATE version = version + 1, updated_at = NOW()`, userID).Error; err != nil {
+		return nil, fmt.Errorf("更新购物车版本失败: %w", err)
+	}
+	return loadSnapshot(tx, userID)
+}
+
+// readSnapshot 读取当前购物车快照，读取期间加共享锁，保证版本与内容一致
+func readSnapshot(database *gorm.DB, userID uint) (*Snapshot, error) {
+	var snap *Snapshot
+	err := database.Transaction(func(tx *gorm.DB) error {
+		var version db.CartVersion
+		err := tx.Clauses(clause.Locking{Strength: "SHARE"}).
+			Where("user_id = ?", userID).Limit(1).Find(&version).Error
+		if err != nil {
+			return fmt.Errorf("查询购物车版本失败: %w", err)
+		}
+		if version.UserID == 0 {
+			// 尚无版本记录（旧数据），初始化后再读取
+			snap, err = Commit(tx, userID)
+			return err
+		}
+		snap, err = loadSnapshot(tx, userID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return snap, nil
+}
+
+func loadSnapshot(tx *gorm.DB, userID uint) (*Snapshot, error) {
+	var version db.CartVersion
+	if err := tx.Where("user_id = ?", userID).First(&version).Error; err != nil {
+		return nil, fmt.Errorf("查询购物车版本失败: %w", err)
+	}
+	snap := &Snapshot{UserID: userID, Version: version.Version}
+	if err := tx.Where("user_id = ? AND status = ?", userID, "in_cart").
+		Order("cart_id").Find(&snap.Items).Error; err != nil {
+		return nil, fmt.Errorf("查询购物车失败: %w", err)
+	}
+	return snap, nil
+}
+
+// Apply 将快照写入 Redis，缓存中已有更新的版本时不写入
+// 失败只记录日志：读取时会校验版本，过期缓存不会被使用
+func (snap *Snapshot) Apply() {
+	if _, err := snap.write(false); err != nil {
+		log.Printf("WARN: 更新购物车缓存失败 user:%d version:%d - %v", snap.UserID, snap.Version, err)
+	}
+}
+
+// write 执行替换脚本，force 为 true 时覆盖同版本的缓存，返回是否写入
+func (snap *Snapshot) write(force bool) (bool, error) {
+	args := make([]interface{}, 0, 3+2*len(snap.Items))
+	args = append(args, snap.Version, int(cacheTTL.Seconds()), boolFlag(force))
+	for _, item := range snap.Items {
+		args = append(args, cartField(item.ProductID, item.SKUID), entryOf(item).String())
+	}
+	n, err := replaceScript.Run(context.Background(), db.RDB, []string{userKey(snap.UserID)}, args...).Int()
+	return n == 1, err
+}
+
+// entries 快照对应的缓存字段，不含版本字段
+func (snap *Snapshot) entries() map[string]string {
+	m := make(map[string]string, len(snap.Items))
+	for _, item := range snap.Items {
+		m[cartField(item.ProductID, item.SKUID)] = entryOf(item).String()
+	}
+	return m
+}
+
+// mutate 在事务中修改用户购物车，提交后把最新快照写入 Redis
+func (s *CartService) mutate(userID uint, fn func(tx *gorm.DB) error) error {
+	var snap *Snapshot
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := fn(tx); err != nil {
+			return err
+		}
+		var err error
+		snap, err = Commit(tx, userID)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	snap.Apply()
+	return nil
+}
+
+// cachedVersion 解析缓存中的版本号，没有版本字段的缓存视为无效
+func cachedVersion(cartMap map[string]string) (uint64, bool) {
+	v, ok := cartMap[versionField]
+	if !ok {
+		return 0, false
+	}
+	version, err := strconv.ParseUint(v, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return version, true
+}
+
+// currentVersion 查询数据库中的购物车版本，没有记录时返回 0
+func currentVersion(database *gorm.DB, userID uint) (uint64, error) {
+	var version db.CartVersion
+	if err := database.Where("user_id = ?", userID).Limit(1).Find(&version).Error; err != nil {
+		return 0, fmt.Errorf("查询购物车版本失败: %w", err)
+	}
+	return version.Version, nil
+}
@@ -0,0 +1,141 @@
+package coupon
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// CouponHandler 优惠券处理程序
+type CouponHandler struct {
+	Service *CouponService
+}
+
+// NewCouponHandler 创建新的优惠券处理程序
+func NewCouponHandler(service *CouponService) *CouponHandler {
+	return &CouponHandler{Service: service}
+}
+
+// CreateTemplate 创建平台或指定卖家的优惠券模板
+func (h *CouponHandler) CreateTemplate(c *gin.Context) {
+	var input TemplateInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "请求数据无效"})
+		return
+	}
+
+	template, err := h.Service.CreateTemplate(&input)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"success": true, "data": template})
+}
+
+// CreateSellerTemplate 卖家创建只适用于本店商品的优惠券，仅卖家本人可操作
+func (h *CouponHandler) CreateSellerTemplate(c *gin.Context) {
+	sellerID, err := strconv.ParseUint(c.Param("seller_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "卖家ID无效"})
+		return
+	}
+	var input struct {
+		TemplateInput
+		UserID uint `json:"user_id"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "请求数据无效"})
+		return
+	}
+	if input.UserID != uint(sellerID) {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "message": "只能为自己的店铺创建优惠券"})
+		return
+	}
+
+	input.SellerID = uint(sellerID)
+	template, err := h.Service.CreateTemplate(&input.TemplateInput)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"success": true, "data": template})
+}
+
+// GetClaimable 获取可领取的优惠券，可按 seller_id 筛选
+func (h *CouponHandler) GetClaimable(c *gin.Context) {
+	userID, _ := strconv.ParseUint(c.Query("user_id"), 10, 32)
+	sellerID, _ := strconv.ParseUint(c.Query("seller_id"), 10, 32)
+
+	templates, err := h.Service.GetClaimable(uint(userID), uint(sellerID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": templates})
+}
+
+// Claim 领取优惠券
+func (h *CouponHandler) Claim(c *gin.Context) {
+	var body struct {
+		UserID     uint `json:"user_id"`
+		TemplateID uint `json:"template_id"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "请求数据无效"})
+		return
+	}
+
+	coupon, err := h.Service.Claim(body.UserID, body.TemplateID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"success": true, "message": "领取成功", "data": coupon})
+}
+
+// GetUserCoupons 获取用户的优惠券，status 可选 usable、used、expired
+func (h *CouponHandler) GetUserCoupons(c *gin.Context) {
+	userID, err := strconv.ParseUint(c.Query("user_id"), 10, 32)
+	if err != nil || userID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "用户ID无效"})
+		return
+	}
+
+	coupons, err := h.Service.GetUserCoupons(uint(userID), c.Query("status"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": coupons})
+}
+
+// SuggestForCart 为购物车中已勾选的商品推荐优惠券，第一张为最优券
+func (h *CouponHandler) SuggestForCart(c *gin.Context) {
+	userID, err := strconv.ParseUint(c.Query("user_id"), 10, 32)
+	if err != nil || userID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "用户ID无效"})
+		return
+	}
+
+	suggestions, err := h.Service.SuggestForCart(uint(userID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": suggestions})
+}
+
+// RegisterCouponRoutes 注册优惠券路由
+func RegisterCouponRoutes(r *gin.Engine, db *gorm.DB) {
+	couponService := NewCouponService(db)
+	couponHandler := NewCouponHandler(couponService)
+
+	r.POST("/admin/coupon_templates", couponHandler.CreateTemplate)
+	r.POST("/sellers/:seller_id/coupon_templates", couponHandler.CreateSellerTemplate)
+	r.GET("/coupon_templates", couponHandler.GetClaimable)
+	r.POST("/coupons/claim", couponHandler.Claim)
+	r.GET("/coupons", couponHandler.GetUserCoupons)
+	r.GET("/cart/coupons", couponHandler.SuggestForCart)
+}
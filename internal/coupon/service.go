@@ -0,0 +1,492 @@
+package coupon
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"szu_market/internal/cache"
+	"szu_market/internal/cart"
+	"szu_market/internal/db"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 用户优惠券状态
+const (
+	StatusUnused = "unused"
+	StatusUsed   = "used"
+)
+
+// 查询用户优惠券时的筛选条件
+const (
+	FilterUsable  = "usable"  // 未使用且在有效期内
+	FilterUsed    = "used"    // 已使用
+	FilterExpired = "expired" // 未使用但已过期
+)
+
+// ErrCouponNotFound 优惠券不存在或不属于该用户
+var ErrCouponNotFound = errors.New("优惠券不存在")
+
+// CouponService 定义优惠券服务
+type CouponService struct {
+	DB *gorm.DB
+}
+
+// NewCouponService 创建新的优惠券服务实例
+func NewCouponService(db *gorm.DB) *CouponService {
+	return &CouponService{DB: db}
+}
+
+// TemplateInput 创建优惠券模板的输入，时间格式为 2006-01-02 15:04:05
+type TemplateInput struct {
+	Name         string `json:"name"`
+	Type         string `json:"type"`
+	Amount       string `json:"amount"`
+	Percent      uint   `json:"percent"`
+	MaxDiscount  string `json:"max_discount"`
+	Threshold    string `json:"threshold"`
+	SellerID     uint   `json:"seller_id"`
+	Category     string `json:"category"`
+	TotalCount   uint   `json:"total_count"`
+	PerUserLimit uint   `json:"per_user_limit"` // 为 0 时每人限领 1 张
+	ClaimStart   string `json:"claim_start"`    // 为空表示立即开始领取
+	ClaimEnd     string `json:"claim_end"`
+	ValidDays    uint   `json:"valid_days"`
+	UseStart     string `json:"use_start"` // ValidDays 为 0 时必填
+	UseEnd       string `json:"use_end"`
+}
+
+// CreateTemplate 创建优惠券模板
+func (s *CouponService) CreateTemplate(input *TemplateInput) (*db.CouponTemplate, error) {
+	if input.Name == "" {
+		return nil, errors.New("优惠券名称不能为空")
+	}
+	threshold, err := db.ParseAmount(input.Threshold, true)
+	if err != nil {
+		return nil, errors.New("使用门槛无效")
+	}
+	amount, err := db.ParseAmount(input.Amount, true)
+	if err != nil {
+		return nil, errors.New("优惠金额无效")
+	}
+	maxDiscount, err := db.ParseAmount(input.MaxDiscount, true)
+	if err != nil {
+		return nil, errors.New("优惠上限无效")
+	}
+
+	template := &db.CouponTemplate{
+		Name:         input.Name,
+		Type:         input.Type,
+		Threshold:    db.CentsToPrice(threshold),
+		Amount:       "0",
+		MaxDiscount:  "0",
+		SellerID:     input.SellerID,
+		Category:     input.Category,
+		TotalCount:   input.TotalCount,
+		PerUserLimit: input.PerUserLimit,
+		ValidDays:    input.ValidDays,
+	}
+	switch input.Type {
+	case db.CouponFixed, db.CouponThreshold:
+		if amount <= 0 {
+			return nil, errors.New("优惠金额无效")
+		}
+		if input.Type == db.CouponThreshold && threshold <= amount {
+			return nil, errors.New("满减券的门槛必须大于优惠金额")
+		}
+		template.Amount = db.CentsToPrice(amount)
+	case db.CouponPercent:
+		if input.Percent == 0 || input.Percent >= 100 {
+			return nil, errors.New("折扣比例必须在 1~99 之间")
+		}
+		template.Percent = input.Percent
+		template.MaxDiscount = db.CentsToPrice(maxDiscount)
+	default:
+		return nil, errors.New("优惠券类型无效")
+	}
+	if template.PerUserLimit == 0 {
+		template.PerUserLimit = 1
+	}
+
+	now := time.Now()
+	template.ClaimStart = now
+	if input.ClaimStart != "" {
+		if template.ClaimStart, err = parseTime(input.ClaimStart); err != nil {
+			return nil, errors.New("领取开始时间格式无效")
+		}
+	}
+	if template.ClaimEnd, err = parseTime(input.ClaimEnd); err != nil {
+		return nil, errors.New("领取结束时间格式无效")
+	}
+	if !template.ClaimEnd.After(template.ClaimStart) {
+		return nil, errors.New("领取结束时间必须晚于开始时间")
+	}
+	if template.ValidDays == 0 {
+		if template.UseStart, err = parseTime(input.UseStart); err != nil {
+			return nil, errors.New("使用开始时间格式无效")
+		}
+		if template.UseEnd, err = parseTime(input.UseEnd); err != nil {
+			return nil, errors.New("使用结束时间格式无效")
+		}
+		if !template.UseEnd.After(template.UseStart) {
+			return nil, errors.New("使用结束时间必须晚于开始时间")
+		}
+	} else {
+		// 有效期从领取时开始计算，模板上记录最早与最晚可使用的时间
+		template.UseStart, template.UseEnd = template.ClaimStart, template.ClaimEnd.AddDate(0, 0, int(template.ValidDays))
+	}
+
+	if err := s.DB.Create(template).Error; err != nil {
+		return nil, fmt.Errorf("创建优惠券失败: %w", err)
+	}
+	return template, nil
+}
+
+// ClaimableTemplate 可领取的优惠券模板，附带当前用户已领取的张数
+type ClaimableTemplate struct {
+	db.CouponTemplate
+	Claimed int64 `json:"claimed"`
+}
+
+// GetClaimable 获取正在发放且仍有余量的优惠券，sellerID 不为 0 时只返回该卖家的券
+func (s *CouponService) GetClaimable(userID, sellerID uint) ([]ClaimableTemplate, error) {
+	now := time.Now()
+	query := s.DB.Where("claim_start <= ? AND claim_end > ? AND (total_count = 0 OR claimed_count < total_count)", now, now)
+	if sellerID != 0 {
+		query = query.Where("seller_id = ?", sellerID)
+	}
+	var templates []db.CouponTemplate
+	if err := query.Order("template_id DESC").Find(&templates).Error; err != nil {
+		return nil, fmt.Errorf("查询优惠券失败: %w", err)
+	}
+
+	claimed := make(map[uint]int64)
+	if userID != 0 && len(templates) > 0 {
+		ids := make([]uint, 0, len(templates))
+		for _, t := range templates {
+			ids = append(ids, t.TemplateID)
+		}
+		var counts []struct {
+			TemplateID uint
+			Count      int64
+		}
+		if err := s.DB.Model(&db.UserCoupon{}).
+			Select("template_id, COUNT(*) AS count").
+			Where("user_id = ? AND template_id IN ?", userID, ids).
+			Group("template_id").Scan(&counts).Error; err != nil {
+			return nil, fmt.Errorf("查询领取记录失败: %w", err)
+		}
+		for _, c := range counts {
+			claimed[c.TemplateID] = c.Count
+		}
+	}
+
+	res := make([]ClaimableTemplate, 0, len(templates))
+	for _, t := range templates {
+		res = append(res, ClaimableTemplate{CouponTemplate: t, Claimed: claimed[t.TemplateID]})
+	}
+	return res, nil
+}
+
+// Claim 领取优惠券
+// 先原子地占用发行名额，模板行的写锁使同一模板的领取串行执行，再校验每人限领张数
+func (s *CouponService) Claim(userID, templateID uint) (*db.UserCoupon, error) {
+	if userID == 0 {
+		return nil, errors.New("用户未登录")
+	}
+
+	var coupon *db.UserCoupon
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		result := tx.Model(&db.CouponTemplate{}).
+			Where("template_id = ? AND claim_start <= ? AND claim_end > ?", templateID, now, now).
+			Where("total_count = 0 OR claimed_count < total_count").
+			UpdateColumn("claimed_count", gorm.Expr("claimed_count + 1"))
+		if result.Error != nil {
+			return fmt.Errorf("领取优惠券失败: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("优惠券不存在、不在领取时间内或已领完")
+		}
+
+		var template db.CouponTemplate
+		if err := tx.First(&template, templateID).Error; err != nil {
+			return fmt.Errorf("查询优惠券失败: %w", err)
+		}
+		var count int64
+		if err := tx.Model(&db.UserCoupon{}).
+			Where("user_id = ? AND template_id = ?", userID, templateID).
+			Count(&count).Error; err != nil {
+			return fmt.Errorf("查询领取记录失败: %w", err)
+		}
+		if count >= int64(template.PerUserLimit) {
+			return fmt.Errorf("该优惠券每人限领 %d 张", template.PerUserLimit)
+		}
+
+		coupon = &db.UserCoupon{
+			TemplateID: templateID,
+			UserID:     userID,
+			Status:     StatusUnused,
+			ValidFrom:  template.UseStart,
+			ValidUntil: template.UseEnd,
+		}
+		if template.ValidDays > 0 {
+			coupon.ValidFrom, coupon.ValidUntil = now, now.AddDate(0, 0, int(template.ValidDays))
+		}
+		if err := tx.Create(coupon).Error; err != nil {
+			return fmt.Errorf("领取优惠券失败: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return coupon, nil
+}
+
+// UserCouponView 用户优惠券及其模板
+type UserCouponView struct {
+	db.UserCoupon
+	Template db.CouponTemplate `json:"template"`
+}
+
+// GetUserCoupons 获取用户的优惠券，filter 见 Filter* 常量，为空时返回全部
+func (s *CouponService) GetUserCoupons(userID uint, filter string) ([]UserCouponView, error) {
+	if userID == 0 {
+		return nil, errors.New("用户未登录")
+	}
+	now := time.Now()
+	query := s.DB.Where("user_id = ?", userID)
+	switch filter {
+	case "":
+	case FilterUsable:
+		query = query.Where("status = ? AND valid_from <= ? AND valid_until > ?", StatusUnused, now, now)
+	case FilterUsed:
+		query = query.Where("status = ?", StatusUsed)
+	case FilterExpired:
+		query = query.Where("status = ? AND valid_until <= ?", StatusUnused, now)
+	default:
+		return nil, errors.New("筛选条件无效")
+	}
+	var coupons []db.UserCoupon
+	if err := query.Order("coupon_id DESC").Find(&coupons).Error; err != nil {
+		return nil, fmt.Errorf("查询优惠券失败: %w", err)
+	}
+	return s.withTemplates(coupons)
+}
+
+// withTemplates 为用户优惠券附加模板信息
+func (s *CouponService) withTemplates(coupons []db.UserCoupon) ([]UserCouponView, error) {
+	views := make([]UserCouponView, 0, len(coupons))
+	if len(coupons) == 0 {
+		return views, nil
+	}
+	ids := make([]uint, 0, len(coupons))
+	for _, c := range coupons {
+		ids = append(ids, c.TemplateID)
+	}
+	var templates []db.CouponTemplate
+	if err := s.DB.Where("template_id IN ?", ids).Find(&templates).Error; err != nil {
+		return nil, fmt.Errorf("查询优惠券失败: %w", err)
+	}
+	templateMap := make(map[uint]db.CouponTemplate, len(templates))
+	for _, t := range templates {
+		templateMap[t.TemplateID] = t
+	}
+	for _, c := range coupons {
+		views = append(views, UserCouponView{UserCoupon: c, Template: templateMap[c.TemplateID]})
+	}
+	return views, nil
+}
+
+// Line 参与优惠券计算的订单商品，Subtotal 单位为分
+type Line struct {
+	SellerID uint
+	Category string
+	Subtotal int64
+}
+
+// Discount 计算优惠券对一组商品的优惠金额（单位：分），不满足门槛时返回 0
+// 门槛与优惠都按模板适用范围内的商品金额计算，优惠不超过适用商品金额
+func Discount(template *db.CouponTemplate, lines []Line) int64 {
+	var eligible int64
+	for _, line := range lines {
+		if template.SellerID != 0 && line.SellerID != template.SellerID {
+			continue
+		}
+		if template.Category != "" && line.Category != template.Category {
+			continue
+		}
+		eligible += line.Subtotal
+	}
+	if eligible <= 0 {
+		return 0
+	}
+	if threshold, err := db.PriceToCents(template.Threshold); err != nil || eligible < threshold {
+		return 0
+	}
+
+	var discount int64
+	switch template.Type {
+	case db.CouponFixed, db.CouponThreshold:
+		amount, err := db.PriceToCents(template.Amount)
+		if err != nil {
+			return 0
+		}
+		discount = amount
+	case db.CouponPercent:
+		discount = eligible * int64(100-template.Percent) / 100
+		if maxDiscount, err := db.PriceToCents(template.MaxDiscount); err == nil && maxDiscount > 0 && discount > maxDiscount {
+			discount = maxDiscount
+		}
+	}
+	if discount > eligible {
+		discount = eligible
+	}
+	return discount
+}
+
+// Apply 在下单事务中锁定并校验用户优惠券，返回对这些商品的优惠金额
+// 锁会持续到事务结束，同一张券的并发下单因此串行执行，只有一笔订单能使用成功
+func Apply(tx *gorm.DB, userID, couponID uint, lines []Line) (int64, error) {
+	var coupon db.UserCoupon
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("coupon_id = ? AND user_id = ?", couponID, userID).
+		First(&coupon).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, ErrCouponNotFound
+		}
+		return 0, fmt.Errorf("查询优惠券失败: %w", err)
+	}
+	if coupon.Status != StatusUnused {
+		return 0, errors.New("优惠券已使用")
+	}
+	now := time.Now()
+	if now.Before(coupon.ValidFrom) || !now.Before(coupon.ValidUntil) {
+		return 0, errors.New("优惠券不在有效期内")
+	}
+
+	var template db.CouponTemplate
+	if err := tx.First(&template, coupon.TemplateID).Error; err != nil {
+		return 0, fmt.Errorf("查询优惠券失败: %w", err)
+	}
+	discount := Discount(&template, lines)
+	if discount == 0 {
+		return 0, fmt.Errorf("订单不满足优惠券「%s」的使用条件", template.Name)
+	}
+	return discount, nil
+}
+
+// MarkUsed 在下单事务中把优惠券标记为已使用，状态已变化时返回错误
+func MarkUsed(tx *gorm.DB, userID, couponID, orderID uint) error {
+	result := tx.Model(&db.UserCoupon{}).
+		Where("coupon_id = ? AND user_id = ? AND status = ?", couponID, userID, StatusUnused).
+		Updates(map[string]interface{}{"status": StatusUsed, "order_id": orderID, "used_at": time.Now()})
+	if result.Error != nil {
+		return fmt.Errorf("使用优惠券失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("优惠券已使用")
+	}
+	return nil
+}
+
+// Release 订单取消后退回该订单使用的优惠券，已过期的券退回后仍不可用
+func Release(tx *gorm.DB, orderID uint) error {
+	if err := tx.Model(&db.UserCoupon{}).
+		Where("order_id = ? AND status = ?", orderID, StatusUsed).
+		Updates(map[string]interface{}{"status": StatusUnused, "order_id": 0, "used_at": nil}).Error; err != nil {
+		return fmt.Errorf("退回优惠券失败: %w", err)
+	}
+	return nil
+}
+
+// Suggestion 购物车可用优惠券及其优惠金额
+type Suggestion struct {
+	UserCouponView
+	Discount string `json:"discount"`
+	Best     bool   `json:"best"`
+}
+
+// SuggestForCart 计算用户每张可用优惠券对购物车已勾选商品的优惠，按优惠金额从高到低排列
+// 第一张即最优券；不满足使用条件的券不返回
+func (s *CouponService) SuggestForCart(userID uint) ([]Suggestion, error) {
+	res, err := cart.NewCartService(s.DB).GetCartItems(userID)
+	if err != nil {
+		return nil, err
+	}
+	lines, err := s.cartLines(res.Items)
+	if err != nil {
+		return nil, err
+	}
+	suggestions := []Suggestion{}
+	if len(lines) == 0 {
+		return suggestions, nil
+	}
+
+	coupons, err := s.GetUserCoupons(userID, FilterUsable)
+	if err != nil {
+		return nil, err
+	}
+	discounts := make(map[uint]int64, len(coupons))
+	for _, c := range coupons {
+		discount := Discount(&c.Template, lines)
+		if discount == 0 {
+			continue
+		}
+		discounts[c.CouponID] = discount
+		suggestions = append(suggestions, Suggestion{UserCouponView: c, Discount: db.CentsToPrice(discount)})
+	}
+	// 优惠相同时优先推荐先过期的券
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		di, dj := discounts[suggestions[i].CouponID], discounts[suggestions[j].CouponID]
+		if di != dj {
+			return di > dj
+		}
+		return suggestions[i].ValidUntil.Before(suggestions[j].ValidUntil)
+	})
+	if len(suggestions) > 0 {
+		suggestions[0].Best = true
+	}
+	return suggestions, nil
+}
+
+// cartLines 将购物车中已勾选且可购买的商品转换为优惠券计算的商品行
+func (s *CouponService) cartLines(items []cart.CartItemResponse) ([]Line, error) {
+	var selected []cart.CartItemResponse
+	productIDs := make([]uint, 0, len(items))
+	for _, item := range items {
+		if item.Selected && item.Purchasable {
+			selected = append(selected, item)
+			productIDs = append(productIDs, item.ProductID)
+		}
+	}
+	if len(selected) == 0 {
+		return nil, nil
+	}
+	productMap, err := cache.GetProducts(s.DB, productIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := make([]Line, 0, len(selected))
+	for _, item := range selected {
+		price, err := db.PriceToCents(item.Price)
+		if err != nil {
+			return nil, fmt.Errorf("商品 %d 价格无效: %w", item.ProductID, err)
+		}
+		lines = append(lines, Line{
+			SellerID: item.SellerID,
+			Category: productMap[item.ProductID].Category,
+			Subtotal: price * int64(item.Quantity),
+		})
+	}
+	return lines, nil
+}
+
+func parseTime(value string) (time.Time, error) {
+	return time.ParseInLocation(time.DateTime, value, time.Local)
+}
@@ -0,0 +1,168 @@
+package coupon
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"szu_market/internal/db"
+	"szu_market/internal/dbtest"
+
+	"gorm.io/gorm"
+)
+
+func TestDiscount(t *testing.T) {
+	lines := []Line{
+		{SellerID: 1, Category: "书籍", Subtotal: 6000},
+		{SellerID: 1, Category: "数码", Subtotal: 4000},
+		{SellerID: 2, Category: "书籍", Subtotal: 2000},
+	}
+	tests := []struct {
+		name     string
+		template db.CouponTemplate
+		lines    []Line
+		want     int64
+	}{
+		{"立减无门槛", db.CouponTemplate{Type: db.CouponFixed, Amount: "10.00", Threshold: "0"}, lines, 1000},
+		{"满减恰好满足门槛", db.CouponTemplate{Type: db.CouponThreshold, Amount: "20.00", Threshold: "120.00"}, lines, 2000},
+		{"满减未满门槛", db.CouponTemplate{Type: db.CouponThreshold, Amount: "20.00", Threshold: "120.01"}, lines, 0},
+		{"折扣不封顶", db.CouponTemplate{Type: db.CouponPercent, Percent: 85, MaxDiscount: "0", Threshold: "0"}, lines, 1800},
+		{"折扣超过上限", db.CouponTemplate{Type: db.CouponPercent, Percent: 85, MaxDiscount: "15.00", Threshold: "0"}, lines, 1500},
+		{"店铺券只按该卖家商品计算门槛", db.CouponTemplate{Type: db.CouponThreshold, Amount: "20.00", Threshold: "110.00", SellerID: 1}, lines, 0},
+		{"店铺券", db.CouponTemplate{Type: db.CouponPercent, Percent: 90, MaxDiscount: "0", Threshold: "0", SellerID: 2}, lines, 200},
+		{"品类券", db.CouponTemplate{Type: db.CouponThreshold, Amount: "5.00", Threshold: "80.00", Category: "书籍"}, lines, 500},
+		{"卖家与品类同时限定", db.CouponTemplate{Type: db.CouponPercent, Percent: 50, MaxDiscount: "0", Threshold: "0", SellerID: 1, Category: "数码"}, lines, 2000},
+		{"没有适用商品", db.CouponTemplate{Type: db.CouponFixed, Amount: "10.00", Threshold: "0", Category: "服饰"}, lines, 0},
+		{"优惠不超过适用商品金额", db.CouponTemplate{Type: db.CouponFixed, Amount: "50.00", Threshold: "0", SellerID: 2}, lines, 2000},
+		{"商品金额为 0", db.CouponTemplate{Type: db.CouponFixed, Amount: "10.00", Threshold: "0"}, []Line{{SellerID: 1, Subtotal: 0}}, 0},
+		{"金额无效", db.CouponTemplate{Type: db.CouponFixed, Amount: "abc", Threshold: "0"}, lines, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Discount(&tt.template, tt.lines); got != tt.want {
+				t.Errorf("Discount() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+// seedCoupons 准备优惠券测试数据：模板 1 立减 10 元、每人限领 2 张，用户 9 已领取一张未使用的券 1
+func seedCoupons(t *testing.T) *gorm.DB {
+	t.Helper()
+	database := dbtest.Open(t, &db.CouponTemplate{}, &db.UserCoupon{})
+	now := time.Now()
+	template := &db.CouponTemplate{TemplateID: 1, Name: "立减10", Type: db.CouponFixed, Amount: "10.00",
+		Threshold: "0", MaxDiscount: "0", PerUserLimit: 2, ClaimedCount: 1,
+		ClaimStart: now.Add(-time.Hour), ClaimEnd: now.Add(time.Hour), UseStart: now.Add(-time.Hour), UseEnd: now.Add(time.Hour)}
+	coupon := &db.UserCoupon{CouponID: 1, TemplateID: 1, UserID: 9, Status: StatusUnused,
+		ValidFrom: now.Add(-time.Hour), ValidUntil: now.Add(time.Hour)}
+	for _, v := range []interface{}{template, coupon} {
+		if err := database.Create(v).Error; err != nil {
+			t.Fatalf("创建测试数据失败: %v", err)
+		}
+	}
+	return database
+}
+
+func TestApplyAndMarkUsed(t *testing.T) {
+	database := seedCoupons(t)
+	lines := []Line{{SellerID: 1, Subtotal: 5000}}
+
+	useCoupon := func(orderID uint) (int64, error) {
+		var discount int64
+		err := database.Transaction(func(tx *gorm.DB) error {
+			var err error
+			if discount, err = Apply(tx, 9, 1, lines); err != nil {
+				return err
+			}
+			return MarkUsed(tx, 9, 1, orderID)
+		})
+		return discount, err
+	}
+
+	discount, err := useCoupon(100)
+	if err != nil {
+		t.Fatalf("第一次使用优惠券失败: %v", err)
+	}
+	if discount != 1000 {
+		t.Errorf("discount = %d, want 1000", discount)
+	}
+	if _, err := useCoupon(101); err == nil || !strings.Contains(err.Error(), "已使用") {
+		t.Errorf("第二次使用 err = %v, want 优惠券已使用", err)
+	}
+	if _, err := Apply(database, 8, 1, lines); err != ErrCouponNotFound {
+		t.Errorf("使用他人的优惠券 err = %v, want %v", err, ErrCouponNotFound)
+	}
+
+	var coupon db.UserCoupon
+	if err := database.First(&coupon, 1).Error; err != nil {
+		t.Fatal(err)
+	}
+	if coupon.Status != StatusUsed || coupon.OrderID != 100 {
+		t.Errorf("coupon = {Status: %q, OrderID: %d}, want {used, 100}", coupon.Status, coupon.OrderID)
+	}
+}
+
+func TestApplyConcurrent(t *testing.T) {
+	database := seedCoupons(t)
+	lines := []Line{{SellerID: 1, Subtotal: 5000}}
+
+	const workers = 8
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(orderID uint) {
+			defer wg.Done()
+			errs <- database.Transaction(func(tx *gorm.DB) error {
+				if _, err := Apply(tx, 9, 1, lines); err != nil {
+					return err
+				}
+				return MarkUsed(tx, 9, 1, orderID)
+			})
+		}(uint(100 + i))
+	}
+	wg.Wait()
+	close(errs)
+
+	succeeded := 0
+	for err := range errs {
+		if err == nil {
+			succeeded++
+		}
+	}
+	if succeeded != 1 {
+		t.Errorf("并发使用同一张优惠券成功 %d 次, want 1", succeeded)
+	}
+}
+
+func TestClaimPerUserLimit(t *testing.T) {
+	database := seedCoupons(t)
+	s := NewCouponService(database)
+
+	// 用户 9 已有一张，还能再领一张
+	if _, err := s.Claim(9, 1); err != nil {
+		t.Fatalf("领取第二张失败: %v", err)
+	}
+	if _, err := s.Claim(9, 1); err == nil || !strings.Contains(err.Error(), "每人限领 2 张") {
+		t.Errorf("领取第三张 err = %v, want 每人限领 2 张", err)
+	}
+	// 超出限领的领取不占用发行名额
+	var template db.CouponTemplate
+	if err := database.First(&template, 1).Error; err != nil {
+		t.Fatal(err)
+	}
+	if template.ClaimedCount != 2 {
+		t.Errorf("ClaimedCount = %d, want 2", template.ClaimedCount)
+	}
+
+	// 其他用户不受影响
+	coupon, err := s.Claim(10, 1)
+	if err != nil {
+		t.Fatalf("其他用户领取失败: %v", err)
+	}
+	if coupon.UserID != 10 || coupon.Status != StatusUnused {
+		t.Errorf("coupon = %+v", coupon)
+	}
+}
@@ -105,11 +105,48 @@ func CloseRedis() {
 
 // autoMigrate 自动迁移所有模型
 func autoMigrate(db *gorm.DB) {
+	// 收藏表加唯一索引前先清理重复收藏，保留最早的一条
+	if db.Migrator().HasTable(&Favorite{}) && !db.Migrator().HasIndex(&Favorite{}, "idx_favorite_user_product") {
+		if err := db.Exec("DELETE f1 FROM favorite f1 JOIN favorite f2 " +
+			"ON f1.user_id = f2.user_id AND f1.product_id = f2.product_id AND f1.favorite_id > f2.favorite_id").Error; err != nil {
+			log.Fatal("清理重复收藏失败：", err)
+		}
+	}
+
 	err := db.AutoMigrate(
 		&User{},
+		&SpecialProduct{},
+		&ProductSKU{},
+		&CartItem{},
+		&CartVersion{},
+		&CartReminder{},
+		&OrderProduct{},
+		&Review{},
+		&Favorite{},
+		&FavoriteFolder{},
+		&PriceHistory{},
+		&Notification{},
+		&SensitiveWord{},
+		&ProductQuestion{},
+		&SellerShipping{},
+		&SellerPromotion{},
+		&CouponTemplate{},
+		&UserCoupon{},
+		&FlashSale{},
+		&FlashSaleOrder{},
 	)
 	if err != nil {
 		log.Fatal("数据库迁移失败：", err)
 		fmt.Println(err)
 	}
+
+	// orders 表不参与自动迁移，新增的列单独补齐
+	for _, column := range []string{"CouponID", "CouponDiscount"} {
+		if db.Migrator().HasColumn(&Order{}, column) {
+			continue
+		}
+		if err := db.Migrator().AddColumn(&Order{}, column); err != nil {
+			log.Fatal("数据库迁移失败：", err)
+		}
+	}
 }
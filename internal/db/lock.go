@@ -0,0 +1,40 @@
+package db
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// unlockScript 仅当锁仍由自己持有（值等于令牌）时才删除
+var unlockScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// TryLock 获取 Redis 分布式锁，锁的值为随机令牌，未抢到锁时 ok 为 false
+// 返回的 unlock 只释放自己持有的锁，任务执行超过 ttl 后锁被其他实例获取时不会误删
+func TryLock(ctx context.Context, key string, ttl time.Duration) (unlock func(), ok bool, err error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, false, err
+	}
+	token := hex.EncodeToString(buf)
+
+	ok, err = RDB.SetNX(ctx, key, token, ttl).Result()
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	unlock = func() {
+		if err := unlockScript.Run(context.Background(), RDB, []string{key}, token).Err(); err != nil {
+			log.Printf("WARN: 释放锁 %s 失败: %v", key, err)
+		}
+	}
+	return unlock, true, nil
+}
@@ -1,6 +1,10 @@
 package db
 
-import "time"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 // 用户模型
 type User struct {
@@ -11,44 +15,207 @@ type User struct {
 	Role             int       `gorm:"not null" json:"role"`
 	RegistrationDate time.Time `gorm:"autoCreateTime" json:"registration_date"`
 	Phone            string    `json:"phone"`
+
+	CartReminderOptOut bool `gorm:"not null;default:false" json:"cart_reminder_opt_out"` // 不接收购物车未结算提醒
 }
 
 // 商品模型
 type SpecialProduct struct {
-	ProductID          uint      `gorm:"primaryKey;autoIncrement" json:"product_id"`
-	Category           string    `gorm:"type:varchar(50);not null" json:"category"`
-	ProductName        string    `gorm:"type:varchar(255);not null" json:"product_name"`
-	ProductDescription string    `gorm:"type:text" json:"product_description"`
-	Origin             string    `gorm:"type:varchar(100)" json:"origin"`
-	Price              string    `gorm:"type:decimal(10,2);not null" json:"price"`
-	SalesPeriod        string    `gorm:"type:varchar(50)" json:"sales_period"`
-	UserID             uint      `json:"user_id"`
-	PublishDate        time.Time `gorm:"autoCreateTime" json:"publish_date"`
-	IsActive           bool      `gorm:"default:true" json:"is_active"`
-	IsViolation        bool      `gorm:"default:false" json:"is_violation"`
-	ImageURL           string    `gorm:"type:varchar(255)" json:"image_url"`
-	Sales              uint      `gorm:"type:not null" json:"sales"`
+	ProductID          uint           `gorm:"primaryKey;autoIncrement" json:"product_id"`
+	Category           string         `gorm:"type:varchar(50);not null" json:"category"`
+	ProductName        string         `gorm:"type:varchar(255);not null" json:"product_name"`
+	ProductDescription string         `gorm:"type:text" json:"product_description"`
+	Origin             string         `gorm:"type:varchar(100)" json:"origin"`
+	Price              string         `gorm:"type:decimal(10,2);not null" json:"price"`
+	SalesPeriod        string         `gorm:"type:varchar(50)" json:"sales_period"`
+	UserID             uint           `json:"user_id"`
+	PublishDate        time.Time      `gorm:"autoCreateTime" json:"publish_date"`
+	IsActive           bool           `gorm:"default:true" json:"is_active"`
+	IsViolation        bool           `gorm:"default:false" json:"is_violation"`
+	ImageURL           string         `gorm:"type:varchar(255)" json:"image_url"`
+	Sales              uint           `gorm:"not null;default:0" json:"sales"`
+	AvgRating          float64        `gorm:"type:decimal(3,2);not null;default:0" json:"avg_rating"`
+	ReviewCount        uint           `gorm:"not null;default:0" json:"review_count"`
+	PurchaseLimit      uint           `gorm:"not null;default:0" json:"purchase_limit"`    // 每位买家限购件数，0 表示不限购
+	LimitWindowDays    uint           `gorm:"not null;default:0" json:"limit_window_days"` // 限购统计周期（天），0 表示不限周期
+	DeletedAt          gorm.DeletedAt `gorm:"index" json:"deleted_at"`
+	SKUs               []ProductSKU   `gorm:"foreignKey:ProductID" json:"skus,omitempty"`
+	MinPrice           string         `gorm:"-" json:"min_price"`
+	MaxPrice           string         `gorm:"-" json:"max_price"`
+}
+
+// FillPriceRange 根据已加载的规格计算价格区间，没有规格时区间即商品价格
+func (p *SpecialProduct) FillPriceRange() {
+	p.MinPrice, p.MaxPrice = p.Price, p.Price
+
+	var minCents, maxCents int64
+	found := false
+	for _, sku := range p.SKUs {
+		if !sku.IsActive {
+			continue
+		}
+		cents, err := PriceToCents(sku.Price)
+		if err != nil {
+			continue
+		}
+		if !found || cents < minCents {
+			minCents = cents
+		}
+		if !found || cents > maxCents {
+			maxCents = cents
+		}
+		found = true
+	}
+	if found {
+		p.MinPrice, p.MaxPrice = CentsToPrice(minCents), CentsToPrice(maxCents)
+	}
+}
+
+// 商品规格（SKU）模型
+type ProductSKU struct {
+	SKUID      uint          `gorm:"primaryKey;autoIncrement;column:sku_id" json:"sku_id"`
+	ProductID  uint          `gorm:"not null;index" json:"product_id"`
+	SKUCode    string        `gorm:"type:varchar(64);column:sku_code" json:"sku_code"`
+	Attributes SKUAttributes `gorm:"type:json" json:"attributes"`
+	Price      string        `gorm:"type:decimal(10,2);not null" json:"price"`
+	Stock      int           `gorm:"not null;default:0" json:"stock"`
+	ImageURL   string        `gorm:"type:varchar(255)" json:"image_url"`
+	IsActive   bool          `gorm:"default:true" json:"is_active"`
+	CreatedAt  time.Time     `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt  time.Time     `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+func (ProductSKU) TableName() string {
+	return "product_skus"
 }
 
 // 购物车项目模型
 type CartItem struct {
-	CartID    uint      `gorm:"primaryKey;autoIncrement" json:"cart_id"`
-	UserID    uint      `gorm:"not null" json:"user_id"`
-	ProductID uint      `gorm:"not null" json:"product_id"`
-	Quantity  int       `gorm:"not null" json:"quantity"`
-	AddTime   time.Time `gorm:"autoCreateTime" json:"add_time"`
-	Status    string    `gorm:"type:enum('in_cart','purchased','removed');default:'in_cart'" json:"status"`
+	CartID     uint      `gorm:"primaryKey;autoIncrement" json:"cart_id"`
+	UserID     uint      `gorm:"not null;uniqueIndex:idx_cart_user_product_sku" json:"user_id"`
+	ProductID  uint      `gorm:"not null;uniqueIndex:idx_cart_user_product_sku" json:"product_id"`
+	SKUID      uint      `gorm:"not null;default:0;column:sku_id;uniqueIndex:idx_cart_user_product_sku" json:"sku_id"`
+	Quantity   int       `gorm:"not null" json:"quantity"`
+	AddTime    time.Time `gorm:"autoCreateTime" json:"add_time"`
+	Status     string    `gorm:"type:enum('in_cart','purchased','removed');default:'in_cart'" json:"status"`
+	Selected   bool      `gorm:"not null;default:true" json:"selected"`                     // 是否勾选结算
+	PriceAtAdd string    `gorm:"type:decimal(10,2);not null;default:0" json:"price_at_add"` // 最近一次加入购物车时的单价，0 表示未记录
+}
+
+// 购物车版本，每次修改用户购物车时递增，用于校验 Redis 购物车缓存是否最新
+type CartVersion struct {
+	UserID    uint      `gorm:"primaryKey;autoIncrement:false" json:"user_id"`
+	Version   uint64    `gorm:"not null;default:0" json:"version"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// 购物车未结算提醒记录，用于频率控制与转化统计
+type CartReminder struct {
+	ReminderID  uint       `gorm:"primaryKey;autoIncrement" json:"reminder_id"`
+	UserID      uint       `gorm:"not null;index:idx_cart_reminder_user" json:"user_id"`
+	CartVersion uint64     `gorm:"not null" json:"cart_version"` // 提醒时的购物车版本，同一版本只提醒一次
+	ItemCount   int        `gorm:"not null" json:"item_count"`
+	SentAt      time.Time  `gorm:"not null;index:idx_cart_reminder_user" json:"sent_at"`
+	OrderID     uint       `gorm:"not null;default:0" json:"order_id"` // 提醒后的首个订单，0 表示尚未转化
+	ConvertedAt *time.Time `json:"converted_at"`
+}
+
+// 卖家运费设置，未设置的卖家不收运费
+type SellerShipping struct {
+	SellerID      uint      `gorm:"primaryKey;autoIncrement:false" json:"seller_id"`
+	Fee           string    `gorm:"type:decimal(10,2);not null;default:0" json:"fee"`            // 每单运费
+	FreeThreshold string    `gorm:"type:decimal(10,2);not null;default:0" json:"free_threshold"` // 满额包邮门槛，0 表示不包邮
+	UpdatedAt     time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// 卖家满减活动，同一卖家的商品金额满 Threshold 减 Reduction
+type SellerPromotion struct {
+	PromotionID uint      `gorm:"primaryKey;autoIncrement" json:"promotion_id"`
+	SellerID    uint      `gorm:"not null;index" json:"seller_id"`
+	Title       string    `gorm:"type:varchar(100);not null" json:"title"`
+	Threshold   string    `gorm:"type:decimal(10,2);not null" json:"threshold"`
+	Reduction   string    `gorm:"type:decimal(10,2);not null" json:"reduction"`
+	StartAt     time.Time `gorm:"not null" json:"start_at"`
+	EndAt       time.Time `gorm:"not null" json:"end_at"`
+	CreatedAt   time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// 优惠券类型
+const (
+	CouponFixed     = "fixed"     // 立减
+	CouponThreshold = "threshold" // 满减，需设置门槛
+	CouponPercent   = "percent"   // 折扣
+)
+
+// 优惠券模板，SellerID 与 Category 为空值时不限制适用范围
+type CouponTemplate struct {
+	TemplateID   uint      `gorm:"primaryKey;autoIncrement" json:"template_id"`
+	Name         string    `gorm:"type:varchar(100);not null" json:"name"`
+	Type         string    `gorm:"type:varchar(16);not null" json:"type"`
+	Amount       string    `gorm:"type:decimal(10,2);not null;default:0" json:"amount"`       // 立减、满减的优惠金额
+	Percent      uint      `gorm:"not null;default:0" json:"percent"`                         // 折扣券的实付比例，85 表示 85 折
+	MaxDiscount  string    `gorm:"type:decimal(10,2);not null;default:0" json:"max_discount"` // 折扣券的优惠上限，0 表示不封顶
+	Threshold    string    `gorm:"type:decimal(10,2);not null;default:0" json:"threshold"`    // 使用门槛，按适用商品金额计算，0 表示无门槛
+	SellerID     uint      `gorm:"not null;default:0;index" json:"seller_id"`
+	Category     string    `gorm:"type:varchar(50);not null;default:''" json:"category"`
+	TotalCount   uint      `gorm:"not null;default:0" json:"total_count"` // 发行总量，0 表示不限量
+	ClaimedCount uint      `gorm:"not null;default:0" json:"claimed_count"`
+	PerUserLimit uint      `gorm:"not null;default:1" json:"per_user_limit"`
+	ClaimStart   time.Time `gorm:"not null" json:"claim_start"`
+	ClaimEnd     time.Time `gorm:"not null" json:"claim_end"`
+	ValidDays    uint      `gorm:"not null;default:0" json:"valid_days"` // 领取后有效天数，0 表示使用固定的 UseStart ~ UseEnd
+	UseStart     time.Time `gorm:"not null" json:"use_start"`
+	UseEnd       time.Time `gorm:"not null" json:"use_end"`
+	CreatedAt    time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// 用户领取的优惠券
+type UserCoupon struct {
+	CouponID   uint       `gorm:"primaryKey;autoIncrement" json:"coupon_id"`
+	TemplateID uint       `gorm:"not null;index" json:"template_id"`
+	UserID     uint       `gorm:"not null;index:idx_user_coupon_status" json:"user_id"`
+	Status     string     `gorm:"type:varchar(16);not null;default:'unused';index:idx_user_coupon_status" json:"status"` // unused、used
+	ValidFrom  time.Time  `gorm:"not null" json:"valid_from"`
+	ValidUntil time.Time  `gorm:"not null" json:"valid_until"`
+	OrderID    uint       `gorm:"not null;default:0" json:"order_id"` // 使用该券的订单
+	UsedAt     *time.Time `json:"used_at"`
+	ClaimedAt  time.Time  `gorm:"autoCreateTime" json:"claimed_at"`
+}
+
+// 秒杀活动，活动期间每位用户限购一件，库存预先加载到 Redis 扣减
+type FlashSale struct {
+	FlashSaleID uint      `gorm:"primaryKey;autoIncrement" json:"flash_sale_id"`
+	ProductID   uint      `gorm:"not null;index" json:"product_id"`
+	SKUID       uint      `gorm:"not null;default:0;column:sku_id" json:"sku_id"`
+	Price       string    `gorm:"type:decimal(10,2);not null" json:"price"` // 秒杀价
+	Stock       uint      `gorm:"not null" json:"stock"`                    // 活动库存
+	Sold        uint      `gorm:"not null;default:0" json:"sold"`           // 已成功下单的件数
+	StartAt     time.Time `gorm:"not null" json:"start_at"`
+	EndAt       time.Time `gorm:"not null" json:"end_at"`
+	CreatedAt   time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// 秒杀成功下单的记录，同一活动每位用户只有一条，保证异步下单幂等
+type FlashSaleOrder struct {
+	RecordID    uint      `gorm:"primaryKey;autoIncrement" json:"record_id"`
+	FlashSaleID uint      `gorm:"not null;uniqueIndex:idx_flash_sale_user" json:"flash_sale_id"`
+	UserID      uint      `gorm:"not null;uniqueIndex:idx_flash_sale_user" json:"user_id"`
+	OrderID     uint      `gorm:"not null" json:"order_id"`
+	CreatedAt   time.Time `gorm:"autoCreateTime" json:"created_at"`
 }
 
 // 订单模型
 type Order struct {
-	OrderID       uint           `gorm:"primaryKey;autoIncrement" json:"order_id"`
-	UserID        uint           `gorm:"not null" json:"user_id"`
-	TotalPrice    float64        `gorm:"type:decimal(10,2);not null" json:"total_price"`
-	Status        string         `gorm:"type:enum('待付款','等待发货','已发货','已收货');default:'待付款'" json:"status"`
-	PaymentStatus string         `gorm:"type:enum('未付款','已付款','已取消');default:'未付款'" json:"payment_status"`
-	AddressID     uint           `gorm:"not null" json:"address_id"`
-	OrderProducts []OrderProduct `gorm:"foreignKey:OrderID"`
+	OrderID        uint           `gorm:"primaryKey;autoIncrement" json:"order_id"`
+	UserID         uint           `gorm:"not null" json:"user_id"`
+	TotalPrice     float64        `gorm:"type:decimal(10,2);not null" json:"total_price"`
+	Status         string         `gorm:"type:enum('待付款','等待发货','已发货','已收货');default:'待付款'" json:"status"`
+	PaymentStatus  string         `gorm:"type:enum('未付款','已付款','已取消');default:'未付款'" json:"payment_status"`
+	AddressID      uint           `gorm:"not null" json:"address_id"`
+	CreatedAt      time.Time      `gorm:"autoCreateTime" json:"created_at"`
+	CouponID       uint           `gorm:"not null;default:0" json:"coupon_id"`                          // 使用的用户优惠券，0 表示未使用
+	CouponDiscount string         `gorm:"type:decimal(10,2);not null;default:0" json:"coupon_discount"` // 优惠券抵扣金额
+	OrderProducts  []OrderProduct `gorm:"foreignKey:OrderID"`
 }
 
 type Address struct {
@@ -78,19 +245,97 @@ type UserInfoResponse struct {
 
 // Favorite 收藏模型
 type Favorite struct {
-	FavoriteID   uint      `gorm:"primaryKey" json:"favorite_id"`
-	UserID       uint      `json:"user_id" gorm:"index"`
-	ProductID    uint      `json:"product_id" gorm:"index"`
-	FavoriteTime time.Time `json:"favorite_time"`
+	FavoriteID        uint      `gorm:"primaryKey" json:"favorite_id"`
+	UserID            uint      `json:"user_id" gorm:"uniqueIndex:idx_favorite_user_product"`
+	ProductID         uint      `json:"product_id" gorm:"index;uniqueIndex:idx_favorite_user_product"`
+	FolderID          uint      `gorm:"not null;default:0;index" json:"folder_id"` // 所在收藏夹，0 表示默认收藏夹
+	FavoriteTime      time.Time `json:"favorite_time"`
+	PriceAtFavorite   string    `gorm:"type:decimal(10,2);not null;default:0" json:"price_at_favorite"` // 收藏时的最低价，0 表示未知
+	AlertPrice        string    `gorm:"type:decimal(10,2);not null;default:0" json:"alert_price"`       // 用户设置的提醒价，0 表示未设置
+	LastNotifiedPrice string    `gorm:"type:decimal(10,2);not null;default:0" json:"-"`                 // 上次降价提醒时的价格，避免重复提醒
 }
 
 func (Favorite) TableName() string {
 	return "favorite"
 }
 
+// FavoriteFolder 用户自建的收藏夹，同一用户下名称唯一
+type FavoriteFolder struct {
+	FolderID  uint      `gorm:"primaryKey;autoIncrement" json:"folder_id"`
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_favorite_folder_name" json:"user_id"`
+	Name      string    `gorm:"type:varchar(50);not null;uniqueIndex:idx_favorite_folder_name" json:"name"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// 商品价格变动记录，SKUID 为 0 表示商品本身的价格
+type PriceHistory struct {
+	HistoryID uint      `gorm:"primaryKey;autoIncrement" json:"history_id"`
+	ProductID uint      `gorm:"not null;index:idx_price_history_product" json:"product_id"`
+	SKUID     uint      `gorm:"not null;default:0;column:sku_id" json:"sku_id"`
+	OldPrice  string    `gorm:"type:decimal(10,2);not null" json:"old_price"`
+	NewPrice  string    `gorm:"type:decimal(10,2);not null" json:"new_price"`
+	ChangedAt time.Time `gorm:"autoCreateTime;index:idx_price_history_product" json:"changed_at"`
+}
+
+func (PriceHistory) TableName() string {
+	return "price_history"
+}
+
+// 站内通知
+type Notification struct {
+	NotificationID uint      `gorm:"primaryKey;autoIncrement" json:"notification_id"`
+	UserID         uint      `gorm:"not null;index:idx_notification_user" json:"user_id"`
+	Type           string    `gorm:"type:varchar(32);not null" json:"type"`
+	Title          string    `gorm:"type:varchar(100);not null" json:"title"`
+	Content        string    `gorm:"type:varchar(500)" json:"content"`
+	ProductID      uint      `gorm:"not null;default:0" json:"product_id"`
+	IsRead         bool      `gorm:"not null;default:false;index:idx_notification_user" json:"is_read"`
+	CreatedAt      time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
 type OrderProduct struct {
-	OrderProductID uint `gorm:"primaryKey;autoIncrement;column:order_product_id" json:"order_product_id"`
-	OrderID        uint `gorm:"not null;index" json:"order_id"`
-	ProductID      uint `gorm:"not null;index" json:"product_id"`
-	Num            uint `gorm:"not null;column:num" json:"num"`
+	OrderProductID uint   `gorm:"primaryKey;autoIncrement;column:order_product_id" json:"order_product_id"`
+	OrderID        uint   `gorm:"not null;index" json:"order_id"`
+	ProductID      uint   `gorm:"not null;index" json:"product_id"`
+	SKUID          uint   `gorm:"not null;default:0;column:sku_id" json:"sku_id"`
+	Num            uint   `gorm:"not null;column:num" json:"num"`
+	Price          string `gorm:"type:decimal(10,2);not null;default:0" json:"price"` // 下单时的单价，0 表示历史订单未记录
+}
+
+// 商品评价模型，ParentID 为 0 表示首次评价，否则为对应首次评价的追评
+type Review struct {
+	ReviewID    uint       `gorm:"primaryKey;autoIncrement" json:"review_id"`
+	ProductID   uint       `gorm:"not null;index;uniqueIndex:idx_review_order_product_parent" json:"product_id"`
+	OrderID     uint       `gorm:"not null;uniqueIndex:idx_review_order_product_parent" json:"order_id"`
+	ParentID    uint       `gorm:"not null;default:0;uniqueIndex:idx_review_order_product_parent" json:"parent_id"`
+	UserID      uint       `gorm:"not null;index" json:"user_id"`
+	SKUID       uint       `gorm:"not null;default:0;column:sku_id" json:"sku_id"`
+	Rating      int        `gorm:"type:tinyint;not null;default:0" json:"rating"`
+	Content     string     `gorm:"type:text" json:"content"`
+	Images      StringList `gorm:"type:json" json:"images"`
+	SellerReply string     `gorm:"type:text" json:"seller_reply"`
+	ReplyTime   *time.Time `json:"reply_time"`
+	CreatedAt   time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// 敏感词，Word 保存规范化后的形式，Severity 为 reject（拒绝发布）或 flag（标记违规）
+type SensitiveWord struct {
+	WordID    uint      `gorm:"primaryKey;autoIncrement" json:"word_id"`
+	Word      string    `gorm:"type:varchar(100);not null;uniqueIndex" json:"word"`
+	Severity  string    `gorm:"type:varchar(16);not null;default:'reject'" json:"severity"`
+	Category  string    `gorm:"type:varchar(50)" json:"category"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// 商品问答，买家提问、卖家回答，IsHidden 为卖家隐藏（审核）的问题
+type ProductQuestion struct {
+	QuestionID uint       `gorm:"primaryKey;autoIncrement" json:"question_id"`
+	ProductID  uint       `gorm:"not null;index" json:"product_id"`
+	UserID     uint       `gorm:"not null;index" json:"user_id"`
+	Content    string     `gorm:"type:varchar(500);not null" json:"content"`
+	Answer     string     `gorm:"type:text" json:"answer"`
+	AnswerTime *time.Time `json:"answer_time"`
+	IsHidden   bool       `gorm:"not null;default:false" json:"is_hidden"`
+	CreatedAt  time.Time  `gorm:"autoCreateTime" json:"created_at"`
 }
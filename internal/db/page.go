@@ -0,0 +1,19 @@
+package db
+
+import "strconv"
+
+// ParsePage 解析分页参数，非法值回退为默认值，每页数量不超过 maxSize
+func ParsePage(pageStr, pageSizeStr string, defaultSize, maxSize int) (int, int) {
+	page, err := strconv.Atoi(pageStr)
+	if err != nil || page < 1 {
+		page = 1
+	}
+	pageSize, err := strconv.Atoi(pageSizeStr)
+	if err != nil || pageSize < 1 {
+		pageSize = defaultSize
+	}
+	if pageSize > maxSize {
+		pageSize = maxSize
+	}
+	return page, pageSize
+}
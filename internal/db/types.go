@@ -0,0 +1,160 @@
+package db
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SKUAttributes 规格属性，如 {"尺码":"L","颜色":"黑色"}，以 JSON 形式存储
+type SKUAttributes map[string]string
+
+// Value 实现 driver.Valuer 接口
+func (a SKUAttributes) Value() (driver.Value, error) {
+	if a == nil {
+		return "{}", nil
+	}
+	b, err := json.Marshal(a)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// Scan 实现 sql.Scanner 接口
+func (a *SKUAttributes) Scan(value interface{}) error {
+	if value == nil {
+		*a = SKUAttributes{}
+		return nil
+	}
+	var raw []byte
+	switch v := value.(type) {
+	case []byte:
+		raw = v
+	case string:
+		raw = []byte(v)
+	default:
+		return errors.New("SKUAttributes: 不支持的数据类型")
+	}
+	if len(raw) == 0 {
+		*a = SKUAttributes{}
+		return nil
+	}
+	return json.Unmarshal(raw, a)
+}
+
+// PriceToCents 将 decimal(10,2) 字符串价格转换为以分为单位的整数，避免浮点误差
+func PriceToCents(price string) (int64, error) {
+	price = strings.TrimSpace(price)
+	if price == "" {
+		return 0, errors.New("价格为空")
+	}
+	negative := strings.HasPrefix(price, "-")
+	price = strings.TrimPrefix(price, "-")
+
+	intPart, fracPart, _ := strings.Cut(price, ".")
+	if intPart == "" {
+		intPart = "0"
+	}
+	// ParseInt 接受正负号，整数与小数部分都必须是纯数字
+	if !isDigits(intPart) || (fracPart != "" && !isDigits(fracPart)) {
+		return 0, fmt.Errorf("价格格式无效: %s", price)
+	}
+	if len(fracPart) > 2 {
+		return 0, fmt.Errorf("价格精度超过两位小数: %s", price)
+	}
+	for len(fracPart) < 2 {
+		fracPart += "0"
+	}
+
+	yuan, err := strconv.ParseInt(intPart, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("价格格式无效: %s", price)
+	}
+	fen, err := strconv.ParseInt(fracPart, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("价格格式无效: %s", price)
+	}
+
+	cents := yuan*100 + fen
+	if negative {
+		cents = -cents
+	}
+	return cents, nil
+}
+
+// isDigits 判断字符串非空且只包含 0-9
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseAmount 解析用户输入的非负金额（单位：分）
+// allowZero 为 true 时空值视为 0 且允许 0，否则金额必须大于 0
+func ParseAmount(amount string, allowZero bool) (int64, error) {
+	if strings.TrimSpace(amount) == "" && allowZero {
+		return 0, nil
+	}
+	cents, err := PriceToCents(amount)
+	if err != nil || cents < 0 || (cents == 0 && !allowZero) {
+		return 0, errors.New("金额无效")
+	}
+	return cents, nil
+}
+
+// CentsToPrice 将以分为单位的整数转换为两位小数的价格字符串
+func CentsToPrice(cents int64) string {
+	sign := ""
+	if cents < 0 {
+		sign = "-"
+		cents = -cents
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, cents/100, cents%100)
+}
+
+// StringList 字符串列表，以 JSON 数组形式存储（如评价图片）
+type StringList []string
+
+// Value 实现 driver.Valuer 接口
+func (l StringList) Value() (driver.Value, error) {
+	if l == nil {
+		return "[]", nil
+	}
+	b, err := json.Marshal(l)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// Scan 实现 sql.Scanner 接口
+func (l *StringList) Scan(value interface{}) error {
+	if value == nil {
+		*l = StringList{}
+		return nil
+	}
+	var raw []byte
+	switch v := value.(type) {
+	case []byte:
+		raw = v
+	case string:
+		raw = []byte(v)
+	default:
+		return errors.New("StringList: 不支持的数据类型")
+	}
+	if len(raw) == 0 {
+		*l = StringList{}
+		return nil
+	}
+	return json.Unmarshal(raw, l)
+}
@@ -0,0 +1,50 @@
+package db
+
+import "testing"
+
+func TestPriceToCents(t *testing.T) {
+	tests := []struct {
+		price   string
+		want    int64
+		wantErr bool
+	}{
+		{"12.34", 1234, false},
+		{"12.3", 1230, false},
+		{"12", 1200, false},
+		{"12.", 1200, false},
+		{".5", 50, false},
+		{" 0.01 ", 1, false},
+		{"-3.50", -350, false},
+		{"", 0, true},
+		{"1.234", 0, true},
+		{"abc", 0, true},
+		{"1.-5", 0, true},
+		{"1.+5", 0, true},
+		{"+1", 0, true},
+		{"--1", 0, true},
+		{"1e3", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := PriceToCents(tt.price)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("PriceToCents(%q) = (%d, %v), want (%d, err=%v)", tt.price, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestCentsToPrice(t *testing.T) {
+	tests := []struct {
+		cents int64
+		want  string
+	}{
+		{0, "0.00"},
+		{5, "0.05"},
+		{1234, "12.34"},
+		{-350, "-3.50"},
+	}
+	for _, tt := range tests {
+		if got := CentsToPrice(tt.cents); got != tt.want {
+			t.Errorf("CentsToPrice(%d) = %q, want %q", tt.cents, got, tt.want)
+		}
+	}
+}
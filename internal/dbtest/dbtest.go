@@ -0,0 +1,36 @@
+// Package dbtest 为测试提供基于内存 SQLite 的数据库，不依赖 MySQL
+package dbtest
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+var seq atomic.Int64
+
+// Open 创建独立的内存数据库并迁移给定模型，测试结束后自动关闭
+// 连接数限制为 1，并发事务因此串行执行，与 MySQL 行锁下的行为一致
+func Open(t testing.TB, models ...interface{}) *gorm.DB {
+	t.Helper()
+	dsn := fmt.Sprintf("file:dbtest%d?mode=memory&cache=shared&_pragma=busy_timeout(5000)", seq.Add(1))
+	database, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("打开测试数据库失败: %v", err)
+	}
+	sqlDB, err := database.DB()
+	if err != nil {
+		t.Fatalf("打开测试数据库失败: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = sqlDB.Close() })
+
+	if err := database.AutoMigrate(models...); err != nil {
+		t.Fatalf("迁移测试数据库失败: %v", err)
+	}
+	return database
+}
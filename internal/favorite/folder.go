@@ -0,0 +1,317 @@
+package favorite
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"szu_market/internal/cache"
+	"szu_market/internal/db"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 收藏夹：每个用户有一个不落库的默认收藏夹（folder_id 为 0），另外可自建收藏夹；
+// 同一商品只能收藏一次，位于某一个收藏夹中，删除收藏夹时其中的商品回到默认收藏夹
+
+const (
+	DefaultFolderName = "默认收藏夹"
+	maxFolders        = 50  // 每个用户可创建的收藏夹数量上限
+	maxFolderName     = 50  // 收藏夹名称的最大字数
+	maxBatchSize      = 100 // 批量操作的商品数量上限
+)
+
+var ErrFolderNotFound = errors.New("收藏夹不存在")
+
+// FolderView 收藏夹及其中的收藏数量
+type FolderView struct {
+	FolderID uint   `json:"folder_id"`
+	Name     string `json:"name"`
+	Count    int64  `json:"count"`
+}
+
+// GetFolders 获取用户的收藏夹，默认收藏夹排在最前，其余按创建顺序排列
+func (s *FavoriteService) GetFolders(userID uint) ([]FolderView, error) {
+	var folders []db.FavoriteFolder
+	if err := s.DB.Where("user_id = ?", userID).Order("folder_id").Find(&folders).Error; err != nil {
+		return nil, fmt.Errorf("查询收藏夹失败: %w", err)
+	}
+
+	var counts []struct {
+		FolderID uint
+		Count    int64
+	}
+	if err := s.DB.Model(&db.Favorite{}).
+		Select("folder_id, COUNT(*) AS count").
+		Where("user_id = ?", userID).
+		Group("folder_id").Scan(&counts).Error; err != nil {
+		return nil, fmt.Errorf("统计收藏数量失败: %w", err)
+	}
+	countMap := make(map[uint]int64, len(counts))
+	for _, c := range counts {
+		countMap[c.FolderID] = c.Count
+	}
+
+	views := make([]FolderView, 0, len(folders)+1)
+	views = append(views, FolderView{Name: DefaultFolderName, Count: countMap[0]})
+	for _, f := range folders {
+		views = append(views, FolderView{FolderID: f.FolderID, Name: f.Name, Count: countMap[f.FolderID]})
+	}
+	return views, nil
+}
+
+// CreateFolder 创建收藏夹
+func (s *FavoriteService) CreateFolder(userID uint, name string) (*db.FavoriteFolder, error) {
+	if userID == 0 {
+		return nil, errors.New("用户未登录")
+	}
+	name, err := validateFolderName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	folder := &db.FavoriteFolder{UserID: userID, Name: name}
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		var folders []db.FavoriteFolder
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ?", userID).Find(&folders).Error; err != nil {
+			return fmt.Errorf("查询收藏夹失败: %w", err)
+		}
+		if len(folders) >= maxFolders {
+			return fmt.Errorf("最多只能创建 %d 个收藏夹", maxFolders)
+		}
+		for _, f := range folders {
+			if f.Name == name {
+				return errors.New("收藏夹名称已存在")
+			}
+		}
+		if err := tx.Create(folder).Error; err != nil {
+			return fmt.Errorf("创建收藏夹失败: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return folder, nil
+}
+
+// RenameFolder 重命名收藏夹，默认收藏夹不能重命名
+func (s *FavoriteService) RenameFolder(userID, folderID uint, name string) error {
+	name, err := validateFolderName(name)
+	if err != nil {
+		return err
+	}
+	if folderID == 0 {
+		return errors.New("默认收藏夹不能重命名")
+	}
+	if err := s.checkFolder(s.DB, userID, folderID); err != nil {
+		return err
+	}
+
+	var count int64
+	if err := s.DB.Model(&db.FavoriteFolder{}).
+		Where("user_id = ? AND name = ? AND folder_id <> ?", userID, name, folderID).
+		Count(&count).Error; err != nil {
+		return fmt.Errorf("查询收藏夹失败: %w", err)
+	}
+	if count > 0 {
+		return errors.New("收藏夹名称已存在")
+	}
+	if err := s.DB.Model(&db.FavoriteFolder{}).
+		Where("folder_id = ?", folderID).
+		Update("name", name).Error; err != nil {
+		return fmt.Errorf("重命名收藏夹失败: %w", err)
+	}
+	return nil
+}
+
+// DeleteFolder 删除收藏夹，其中的商品移回默认收藏夹
+func (s *FavoriteService) DeleteFolder(userID, folderID uint) error {
+	if folderID == 0 {
+		return errors.New("默认收藏夹不能删除")
+	}
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("folder_id = ? AND user_id = ?", folderID, userID).Delete(&db.FavoriteFolder{})
+		if result.Error != nil {
+			return fmt.Errorf("删除收藏夹失败: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return ErrFolderNotFound
+		}
+		if err := tx.Model(&db.Favorite{}).
+			Where("user_id = ? AND folder_id = ?", userID, folderID).
+			Update("folder_id", 0).Error; err != nil {
+			return fmt.Errorf("移动收藏失败: %w", err)
+		}
+		return nil
+	})
+}
+
+// BatchInput 批量收藏操作的输入，FolderID 为 0 表示默认收藏夹
+type BatchInput struct {
+	UserID     uint   `json:"user_id"`
+	ProductIDs []uint `json:"product_ids"`
+	FolderID   uint   `json:"folder_id"`
+}
+
+// BatchResult 批量操作的结果
+type BatchResult struct {
+	Affected int64  `json:"affected"`          // 实际新增、移动或删除的收藏数量
+	Invalid  []uint `json:"invalid,omitempty"` // 不存在或已删除、因此未收藏的商品
+}
+
+// AddFavorites 批量收藏商品到指定收藏夹
+// 已收藏的商品保持原样（不会移动到该收藏夹），不存在的商品跳过并在结果中返回
+func (s *FavoriteService) AddFavorites(input *BatchInput) (*BatchResult, error) {
+	productIDs, err := validateBatch(input)
+	if err != nil {
+		return nil, err
+	}
+	productMap, err := cache.GetProducts(s.DB, productIDs)
+	if err != nil {
+		return nil, err
+	}
+	res := &BatchResult{}
+	now := time.Now()
+	var favorites []db.Favorite
+	for _, id := range productIDs {
+		p, ok := productMap[id]
+		if !ok || p.DeletedAt.Valid {
+			res.Invalid = append(res.Invalid, id)
+			continue
+		}
+		favorites = append(favorites, db.Favorite{
+			UserID:          input.UserID,
+			ProductID:       id,
+			FolderID:        input.FolderID,
+			FavoriteTime:    now,
+			PriceAtFavorite: p.MinPrice,
+			AlertPrice:      "0",
+		})
+	}
+	if len(favorites) == 0 {
+		return res, s.checkFolder(s.DB, input.UserID, input.FolderID)
+	}
+
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		// 锁定目标收藏夹，避免收藏过程中收藏夹被删除
+		if err := s.checkFolder(tx.Clauses(clause.Locking{Strength: "UPDATE"}), input.UserID, input.FolderID); err != nil {
+			return err
+		}
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&favorites)
+		if result.Error != nil {
+			return fmt.Errorf("添加收藏失败: %w", result.Error)
+		}
+		res.Affected = result.RowsAffected
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// RemoveFavorites 批量取消收藏，未收藏的商品直接忽略
+func (s *FavoriteService) RemoveFavorites(input *BatchInput) (*BatchResult, error) {
+	productIDs, err := validateBatch(input)
+	if err != nil {
+		return nil, err
+	}
+	result := s.DB.Where("user_id = ? AND product_id IN ?", input.UserID, productIDs).Delete(&db.Favorite{})
+	if result.Error != nil {
+		return nil, fmt.Errorf("取消收藏失败: %w", result.Error)
+	}
+	return &BatchResult{Affected: result.RowsAffected}, nil
+}
+
+// MoveFavorites 将已收藏的商品移动到指定收藏夹，未收藏的商品直接忽略
+func (s *FavoriteService) MoveFavorites(input *BatchInput) (*BatchResult, error) {
+	productIDs, err := validateBatch(input)
+	if err != nil {
+		return nil, err
+	}
+
+	var res BatchResult
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		// 锁定目标收藏夹，避免移动过程中收藏夹被删除
+		if err := s.checkFolder(tx.Clauses(clause.Locking{Strength: "UPDATE"}), input.UserID, input.FolderID); err != nil {
+			return err
+		}
+		result := tx.Model(&db.Favorite{}).
+			Where("user_id = ? AND product_id IN ? AND folder_id <> ?", input.UserID, productIDs, input.FolderID).
+			Update("folder_id", input.FolderID)
+		if result.Error != nil {
+			return fmt.Errorf("移动收藏失败: %w", result.Error)
+		}
+		res.Affected = result.RowsAffected
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// checkFolder 校验收藏夹属于该用户，0 表示默认收藏夹
+func (s *FavoriteService) checkFolder(tx *gorm.DB, userID, folderID uint) error {
+	if userID == 0 {
+		return errors.New("用户未登录")
+	}
+	if folderID == 0 {
+		return nil
+	}
+	var folder db.FavoriteFolder
+	if err := tx.Where("folder_id = ? AND user_id = ?", folderID, userID).
+		Limit(1).Find(&folder).Error; err != nil {
+		return fmt.Errorf("查询收藏夹失败: %w", err)
+	}
+	if folder.FolderID == 0 {
+		return ErrFolderNotFound
+	}
+	return nil
+}
+
+// validateFolderName 去除首尾空白后校验收藏夹名称
+func validateFolderName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", errors.New("收藏夹名称不能为空")
+	}
+	if utf8.RuneCountInString(name) > maxFolderName {
+		return "", fmt.Errorf("收藏夹名称不能超过 %d 个字", maxFolderName)
+	}
+	if name == DefaultFolderName {
+		return "", errors.New("收藏夹名称已存在")
+	}
+	return name, nil
+}
+
+// validateBatch 校验批量操作的输入，返回去重后的商品ID
+func validateBatch(input *BatchInput) ([]uint, error) {
+	if input.UserID == 0 {
+		return nil, errors.New("用户未登录")
+	}
+	if len(input.ProductIDs) == 0 {
+		return nil, errors.New("请选择商品")
+	}
+	if len(input.ProductIDs) > maxBatchSize {
+		return nil, fmt.Errorf("一次最多操作 %d 件商品", maxBatchSize)
+	}
+	seen := make(map[uint]bool, len(input.ProductIDs))
+	productIDs := make([]uint, 0, len(input.ProductIDs))
+	for _, id := range input.ProductIDs {
+		if id == 0 || seen[id] {
+			continue
+		}
+		seen[id] = true
+		productIDs = append(productIDs, id)
+	}
+	if len(productIDs) == 0 {
+		return nil, errors.New("请选择商品")
+	}
+	return productIDs, nil
+}
@@ -1,6 +1,7 @@
 package favorite
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -21,9 +22,10 @@ func NewFavoriteHandler(service *FavoriteService) *FavoriteHandler {
 
 // FavoriteRequest 收藏请求
 type FavoriteRequest struct {
-	UserID    uint   `json:"user_id"`
-	ProductID uint   `json:"product_id"`
-	Action    string `json:"action"` // "add" 或 "remove"
+	UserID     uint   `json:"user_id"`
+	ProductID  uint   `json:"product_id"`
+	Action     string `json:"action"`      // "add" 或 "remove"
+	AlertPrice string `json:"alert_price"` // 降价提醒价，可选
 }
 
 // HandleFavorite 处理收藏请求
@@ -37,7 +39,7 @@ func (h *FavoriteHandler) HandleFavorite(c *gin.Context) {
 
 	switch req.Action {
 	case "add":
-		h.addFavorite(c, req.UserID, req.ProductID)
+		h.addFavorite(c, req.UserID, req.ProductID, req.AlertPrice)
 	case "remove":
 		h.removeFavorite(c, req.UserID, req.ProductID)
 	default:
@@ -47,9 +49,13 @@ func (h *FavoriteHandler) HandleFavorite(c *gin.Context) {
 }
 
 // addFavorite 添加收藏
-func (h *FavoriteHandler) addFavorite(c *gin.Context, userID, productID uint) {
-	if err := h.Service.AddFavorite(userID, productID); err != nil {
+func (h *FavoriteHandler) addFavorite(c *gin.Context, userID, productID uint, alertPrice string) {
+	if err := h.Service.AddFavorite(userID, productID, alertPrice); err != nil {
 		fmt.Println(err)
+		if errors.Is(err, ErrProductNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -67,6 +73,22 @@ func (h *FavoriteHandler) removeFavorite(c *gin.Context, userID, productID uint)
 	c.JSON(http.StatusOK, gin.H{"message": "已取消收藏"})
 }
 
+// SetPriceAlert 设置收藏商品的降价提醒价
+func (h *FavoriteHandler) SetPriceAlert(c *gin.Context) {
+	var req FavoriteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求"})
+		return
+	}
+
+	if err := h.Service.SetPriceAlert(req.UserID, req.ProductID, req.AlertPrice); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "提醒价已更新"})
+}
+
 // GetUserFavorites 获取用户收藏列表
 func (h *FavoriteHandler) GetUserFavorites(c *gin.Context) {
 	userIDStr := c.Query("user_id")
@@ -82,7 +104,19 @@ func (h *FavoriteHandler) GetUserFavorites(c *gin.Context) {
 		return
 	}
 
-	products, err := h.Service.GetUserFavorites(uint(userID))
+	// 不传 folder_id 时返回全部收藏，folder_id=0 表示默认收藏夹
+	var folderID *uint
+	if folder, ok := c.GetQuery("folder_id"); ok {
+		id, err := strconv.ParseUint(folder, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "收藏夹ID无效"})
+			return
+		}
+		fid := uint(id)
+		folderID = &fid
+	}
+
+	products, err := h.Service.GetUserFavorites(uint(userID), folderID)
 	if err != nil {
 		fmt.Println("获取收藏列表失败")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取收藏列表失败"})
@@ -92,6 +126,124 @@ func (h *FavoriteHandler) GetUserFavorites(c *gin.Context) {
 	c.JSON(http.StatusOK, products)
 }
 
+// GetFolders 获取用户的收藏夹列表
+func (h *FavoriteHandler) GetFolders(c *gin.Context) {
+	userID, err := strconv.ParseUint(c.Query("user_id"), 10, 32)
+	if err != nil || userID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "用户ID无效"})
+		return
+	}
+
+	folders, err := h.Service.GetFolders(uint(userID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, folders)
+}
+
+// FolderRequest 创建或重命名收藏夹的请求
+type FolderRequest struct {
+	UserID uint   `json:"user_id"`
+	Name   string `json:"name"`
+}
+
+// CreateFolder 创建收藏夹
+func (h *FavoriteHandler) CreateFolder(c *gin.Context) {
+	var req FolderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求"})
+		return
+	}
+
+	folder, err := h.Service.CreateFolder(req.UserID, req.Name)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, folder)
+}
+
+// RenameFolder 重命名收藏夹
+func (h *FavoriteHandler) RenameFolder(c *gin.Context) {
+	folderID, err := strconv.ParseUint(c.Param("folder_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "收藏夹ID无效"})
+		return
+	}
+	var req FolderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求"})
+		return
+	}
+
+	if err := h.Service.RenameFolder(req.UserID, uint(folderID), req.Name); err != nil {
+		respondFolderError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "收藏夹已重命名"})
+}
+
+// DeleteFolder 删除收藏夹，其中的商品移回默认收藏夹
+func (h *FavoriteHandler) DeleteFolder(c *gin.Context) {
+	folderID, err := strconv.ParseUint(c.Param("folder_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "收藏夹ID无效"})
+		return
+	}
+	userID, err := strconv.ParseUint(c.Query("user_id"), 10, 32)
+	if err != nil || userID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "用户ID无效"})
+		return
+	}
+
+	if err := h.Service.DeleteFolder(uint(userID), uint(folderID)); err != nil {
+		respondFolderError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "收藏夹已删除"})
+}
+
+// BatchAdd 批量收藏商品到指定收藏夹
+func (h *FavoriteHandler) BatchAdd(c *gin.Context) {
+	h.handleBatch(c, h.Service.AddFavorites)
+}
+
+// BatchRemove 批量取消收藏
+func (h *FavoriteHandler) BatchRemove(c *gin.Context) {
+	h.handleBatch(c, h.Service.RemoveFavorites)
+}
+
+// BatchMove 将收藏的商品移动到指定收藏夹
+func (h *FavoriteHandler) BatchMove(c *gin.Context) {
+	h.handleBatch(c, h.Service.MoveFavorites)
+}
+
+// handleBatch 解析批量请求并执行批量操作
+func (h *FavoriteHandler) handleBatch(c *gin.Context, op func(*BatchInput) (*BatchResult, error)) {
+	var input BatchInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求"})
+		return
+	}
+
+	result, err := op(&input)
+	if err != nil {
+		respondFolderError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// respondFolderError 收藏夹不存在时返回 404，其余错误返回 400
+func respondFolderError(c *gin.Context, err error) {
+	if errors.Is(err, ErrFolderNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+}
+
 // RegisterFavoriteRoutes 注册收藏路由
 func RegisterFavoriteRoutes(r *gin.Engine, db *gorm.DB) {
 	favoriteService := NewFavoriteService(db)
@@ -99,4 +251,12 @@ func RegisterFavoriteRoutes(r *gin.Engine, db *gorm.DB) {
 
 	r.POST("/favorite", favoriteHandler.HandleFavorite)
 	r.GET("/favorites", favoriteHandler.GetUserFavorites)
+	r.PUT("/favorites/alert", favoriteHandler.SetPriceAlert)
+	r.POST("/favorites/batch", favoriteHandler.BatchAdd)
+	r.POST("/favorites/batch_remove", favoriteHandler.BatchRemove)
+	r.PUT("/favorites/folder", favoriteHandler.BatchMove)
+	r.GET("/favorites/folders", favoriteHandler.GetFolders)
+	r.POST("/favorites/folders", favoriteHandler.CreateFolder)
+	r.PUT("/favorites/folders/:folder_id", favoriteHandler.RenameFolder)
+	r.DELETE("/favorites/folders/:folder_id", favoriteHandler.DeleteFolder)
 }
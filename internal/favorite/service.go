@@ -2,13 +2,19 @@ package favorite
 
 import (
 	"errors"
+	"fmt"
 	"time"
 
+	"szu_market/internal/cache"
 	"szu_market/internal/db"
+	"szu_market/internal/notify"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+var ErrProductNotFound = errors.New("商品不存在")
+
 // FavoriteService 收藏服务
 type FavoriteService struct {
 	DB *gorm.DB
@@ -19,30 +25,87 @@ func NewFavoriteService(db *gorm.DB) *FavoriteService {
 	return &FavoriteService{DB: db}
 }
 
-// AddFavorite 添加收藏
-func (s *FavoriteService) AddFavorite(userID, productID uint) error {
-	// 检查是否已经收藏
-	var existing db.Favorite
-	result := s.DB.Where("user_id = ? AND product_id = ?", userID, productID).First(&existing)
+// AddFavorite 添加收藏到默认收藏夹，记录收藏时的价格，alertPrice 为空表示不设置提醒价
+// 重复收藏不会报错也不会产生新记录，指定了提醒价时更新提醒价
+func (s *FavoriteService) AddFavorite(userID, productID uint, alertPrice string) error {
+	alert, err := parseAlertPrice(alertPrice)
+	if err != nil {
+		return err
+	}
 
-	if result.Error == nil {
-		return errors.New("已收藏过该商品")
+	product, err := cache.GetProduct(s.DB, productID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrProductNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("查询商品失败: %w", err)
+	}
+	if product.DeletedAt.Valid {
+		return ErrProductNotFound
 	}
 
-	// 创建新收藏
 	favorite := db.Favorite{
-		UserID:       userID,
-		ProductID:    productID,
-		FavoriteTime: time.Now(),
+		UserID:          userID,
+		ProductID:       productID,
+		FavoriteTime:    time.Now(),
+		PriceAtFavorite: product.MinPrice,
+		AlertPrice:      alert,
+	}
+	// (user_id, product_id) 唯一索引保证并发重复收藏只保留一条
+	conflict := clause.OnConflict{DoNothing: true}
+	if alertPrice != "" {
+		conflict = clause.OnConflict{DoUpdates: clause.Assignments(map[string]interface{}{
+			"alert_price":         alert,
+			"last_notified_price": "0",
+		})}
 	}
+	if err := s.DB.Clauses(conflict).Create(&favorite).Error; err != nil {
+		return fmt.Errorf("添加收藏失败: %w", err)
+	}
+
+	return nil
+}
 
-	if err := s.DB.Create(&favorite).Error; err != nil {
-		return errors.New("添加收藏失败")
+// SetPriceAlert 设置收藏商品的降价提醒价，alertPrice 为空或 0 表示取消提醒
+func (s *FavoriteService) SetPriceAlert(userID, productID uint, alertPrice string) error {
+	alert, err := parseAlertPrice(alertPrice)
+	if err != nil {
+		return err
 	}
 
+	// 重新设置提醒价后允许再次提醒
+	result := s.DB.Model(&db.Favorite{}).
+		Where("user_id = ? AND product_id = ?", userID, productID).
+		Updates(map[string]interface{}{"alert_price": alert, "last_notified_price": "0"})
+	if result.Error != nil {
+		return fmt.Errorf("设置提醒价失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		var count int64
+		if err := s.DB.Model(&db.Favorite{}).
+			Where("user_id = ? AND product_id = ?", userID, productID).
+			Count(&count).Error; err != nil {
+			return fmt.Errorf("数据库查询失败: %w", err)
+		}
+		if count == 0 {
+			return errors.New("未找到收藏记录")
+		}
+	}
 	return nil
 }
 
+// parseAlertPrice 校验提醒价，空值视为 0（未设置）
+func parseAlertPrice(alertPrice string) (string, error) {
+	if alertPrice == "" {
+		return "0", nil
+	}
+	cents, err := db.PriceToCents(alertPrice)
+	if err != nil || cents < 0 {
+		return "", errors.New("提醒价无效")
+	}
+	return db.CentsToPrice(cents), nil
+}
+
 // RemoveFavorite 移除收藏
 func (s *FavoriteService) RemoveFavorite(userID, productID uint) error {
 	result := s.DB.Where("user_id = ? AND product_id = ?", userID, productID).Delete(&db.Favorite{})
@@ -58,10 +121,22 @@ func (s *FavoriteService) RemoveFavorite(userID, productID uint) error {
 	return nil
 }
 
-// GetUserFavorites 获取用户收藏列表
-func (s *FavoriteService) GetUserFavorites(userID uint) ([]db.SpecialProduct, error) {
+// FavoriteProduct 收藏列表中的商品，附带所在收藏夹、收藏时价格与提醒价
+type FavoriteProduct struct {
+	db.SpecialProduct
+	FolderID        uint   `json:"folder_id"`
+	PriceAtFavorite string `json:"price_at_favorite"`
+	AlertPrice      string `json:"alert_price"`
+}
+
+// GetUserFavorites 获取用户收藏列表，folderID 为 nil 时返回全部收藏夹中的商品
+func (s *FavoriteService) GetUserFavorites(userID uint, folderID *uint) ([]FavoriteProduct, error) {
+	query := s.DB.Where("user_id = ?", userID)
+	if folderID != nil {
+		query = query.Where("folder_id = ?", *folderID)
+	}
 	var favorites []db.Favorite
-	if err := s.DB.Where("user_id = ?", userID).Find(&favorites).Error; err != nil {
+	if err := query.Find(&favorites).Error; err != nil {
 		return nil, err
 	}
 
@@ -71,11 +146,84 @@ func (s *FavoriteService) GetUserFavorites(userID uint) ([]db.SpecialProduct, er
 		productIDs = append(productIDs, fav.ProductID)
 	}
 
-	// 查询这些商品的信息
-	var products []db.SpecialProduct
-	if err := s.DB.Where("product_id IN ?", productIDs).Find(&products).Error; err != nil {
+	// 通过商品缓存批量获取商品信息，按收藏顺序返回并跳过已删除的商品
+	productMap, err := cache.GetProducts(s.DB, productIDs)
+	if err != nil {
 		return nil, err
 	}
+	products := make([]FavoriteProduct, 0, len(productMap))
+	for _, fav := range favorites {
+		if p, ok := productMap[fav.ProductID]; ok && !p.DeletedAt.Valid {
+			products = append(products, FavoriteProduct{
+				SpecialProduct:  p,
+				FolderID:        fav.FolderID,
+				PriceAtFavorite: fav.PriceAtFavorite,
+				AlertPrice:      fav.AlertPrice,
+			})
+		}
+	}
 
 	return products, nil
 }
+
+// NotifyPriceDrop 商品降价后通知收藏用户：现价低于收藏时价格或低于用户设置的提醒价
+// 同一价格只提醒一次，价格继续下降时会再次提醒
+func (s *FavoriteService) NotifyPriceDrop(productID uint) error {
+	// 直接读库而不走缓存，保证拿到刚更新的价格
+	var product db.SpecialProduct
+	if err := s.DB.Preload("SKUs").First(&product, productID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return fmt.Errorf("查询商品失败: %w", err)
+	}
+	if !product.IsActive || product.IsViolation {
+		return nil
+	}
+	product.FillPriceRange()
+	price := product.MinPrice
+	priceCents, err := db.PriceToCents(price)
+	if err != nil {
+		return fmt.Errorf("商品价格无效: %w", err)
+	}
+
+	var favorites []db.Favorite
+	if err := s.DB.Where("product_id = ?", productID).
+		Where("(price_at_favorite > 0 AND price_at_favorite > ?) OR (alert_price > 0 AND alert_price >= ?)", price, price).
+		Where("last_notified_price = 0 OR last_notified_price > ?", price).
+		Find(&favorites).Error; err != nil {
+		return fmt.Errorf("查询收藏失败: %w", err)
+	}
+	if len(favorites) == 0 {
+		return nil
+	}
+
+	notifications := make([]db.Notification, 0, len(favorites))
+	favoriteIDs := make([]uint, 0, len(favorites))
+	for _, fav := range favorites {
+		content := fmt.Sprintf("您收藏的「%s」降价了：收藏时 ¥%s，现价 ¥%s", product.ProductName, fav.PriceAtFavorite, price)
+		if alertCents, err := db.PriceToCents(fav.AlertPrice); err == nil && alertCents > 0 && priceCents <= alertCents {
+			content = fmt.Sprintf("您收藏的「%s」已降至 ¥%s，低于您设置的提醒价 ¥%s", product.ProductName, price, fav.AlertPrice)
+		}
+		notifications = append(notifications, db.Notification{
+			UserID:    fav.UserID,
+			Type:      notify.TypePriceDrop,
+			Title:     "收藏商品降价提醒",
+			Content:   content,
+			ProductID: productID,
+		})
+		favoriteIDs = append(favoriteIDs, fav.FavoriteID)
+	}
+
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := notify.Send(tx, notifications); err != nil {
+			return err
+		}
+		if err := tx.Model(&db.Favorite{}).
+			Where("favorite_id IN ?", favoriteIDs).
+			Update("last_notified_price", price).Error; err != nil {
+			return fmt.Errorf("更新提醒记录失败: %w", err)
+		}
+		return nil
+	})
+}
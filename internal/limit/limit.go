@@ -0,0 +1,92 @@
+package limit
+
+import (
+	"fmt"
+
+	"szu_market/internal/db"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ExceededError 购买数量超出商品限购时返回的错误
+type ExceededError struct {
+	ProductID   uint   `json:"product_id"`
+	ProductName string `json:"product_name"`
+	Limit       uint   `json:"limit"`       // 每位买家限购件数
+	WindowDays  uint   `json:"window_days"` // 限购统计周期（天），0 表示不限周期
+	Purchased   int64  `json:"purchased"`   // 周期内已下单的件数
+	Pending     int64  `json:"pending"`     // 购物车中已有、尚未下单的件数
+	Requested   int64  `json:"requested"`   // 本次要购买或加购的件数
+}
+
+func (e *ExceededError) Error() string {
+	period := ""
+	if e.WindowDays > 0 {
+		period = fmt.Sprintf("（%d 天内）", e.WindowDays)
+	}
+	msg := fmt.Sprintf("「%s」每人限购 %d 件%s，您已购买 %d 件", e.ProductName, e.Limit, period, e.Purchased)
+	if e.Pending > 0 {
+		msg += fmt.Sprintf("，购物车中已有 %d 件", e.Pending)
+	}
+	return msg + fmt.Sprintf("，本次最多还能购买 %d 件", e.Remaining())
+}
+
+// Remaining 扣除已购买和购物车中的件数后还能购买的件数
+func (e *ExceededError) Remaining() int64 {
+	remaining := int64(e.Limit) - e.Purchased - e.Pending
+	if remaining < 0 {
+		return 0
+	}
+	return remaining
+}
+
+// Lock 锁定买家记录直到事务结束，同一买家的限购校验与下单因此串行执行
+func Lock(tx *gorm.DB, userID uint) error {
+	var user db.User
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select("user_id").Where("user_id = ?", userID).Limit(1).Find(&user).Error; err != nil {
+		return fmt.Errorf("锁定用户失败: %w", err)
+	}
+	return nil
+}
+
+// Purchased 统计买家在限购周期内已下单的件数，已取消的订单不计入
+func Purchased(tx *gorm.DB, userID uint, product *db.SpecialProduct) (int64, error) {
+	query := tx.Table("order_products").
+		Joins("JOIN orders ON orders.order_id = order_products.order_id").
+		Where("orders.user_id = ? AND order_products.product_id = ?", userID, product.ProductID).
+		Where("orders.payment_status <> ?", "已取消")
+	if product.LimitWindowDays > 0 {
+		query = query.Where("orders.created_at >= NOW() - INTERVAL ? DAY", product.LimitWindowDays)
+	}
+	var total int64
+	if err := query.Select("COALESCE(SUM(order_products.num), 0)").Scan(&total).Error; err != nil {
+		return 0, fmt.Errorf("统计已购数量失败: %w", err)
+	}
+	return total, nil
+}
+
+// Check 校验买家在已有 pending 件未下单的基础上再购买 requested 件是否超出限购
+// 商品未设置限购时直接通过
+func Check(tx *gorm.DB, userID uint, product *db.SpecialProduct, pending, requested int64) error {
+	if product.PurchaseLimit == 0 {
+		return nil
+	}
+	purchased, err := Purchased(tx, userID, product)
+	if err != nil {
+		return err
+	}
+	if purchased+pending+requested <= int64(product.PurchaseLimit) {
+		return nil
+	}
+	return &ExceededError{
+		ProductID:   product.ProductID,
+		ProductName: product.ProductName,
+		Limit:       product.PurchaseLimit,
+		WindowDays:  product.LimitWindowDays,
+		Purchased:   purchased,
+		Pending:     pending,
+		Requested:   requested,
+	}
+}
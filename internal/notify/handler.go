@@ -0,0 +1,101 @@
+package notify
+
+import (
+	"net/http"
+	"strconv"
+
+	"szu_market/internal/db"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// NotifyHandler 通知处理程序
+type NotifyHandler struct {
+	Service *NotifyService
+}
+
+// NewNotifyHandler 创建新的通知处理程序
+func NewNotifyHandler(service *NotifyService) *NotifyHandler {
+	return &NotifyHandler{Service: service}
+}
+
+// markReadRequest 标记已读请求
+type markReadRequest struct {
+	UserID uint `json:"user_id"`
+}
+
+// GetNotifications 分页获取当前用户的通知
+func (h *NotifyHandler) GetNotifications(c *gin.Context) {
+	userID, err := strconv.ParseUint(c.Query("user_id"), 10, 32)
+	if err != nil || userID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "用户ID无效"})
+		return
+	}
+	unreadOnly := c.Query("unread_only") == "true" || c.Query("unread_only") == "1"
+
+	page, pageSize := db.ParsePage(c.Query("page"), c.Query("page_size"), defaultPageSize, maxPageSize)
+	notifications, err := h.Service.GetNotifications(uint(userID), unreadOnly, page, pageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    notifications,
+	})
+}
+
+// MarkRead 将单条通知标记为已读
+func (h *NotifyHandler) MarkRead(c *gin.Context) {
+	notificationID, err := strconv.ParseUint(c.Param("notification_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "通知ID无效"})
+		return
+	}
+
+	var req markReadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "请求数据无效"})
+		return
+	}
+
+	if err := h.Service.MarkRead(req.UserID, uint(notificationID)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "已标记为已读"})
+}
+
+// MarkAllRead 将所有通知标记为已读
+func (h *NotifyHandler) MarkAllRead(c *gin.Context) {
+	var req markReadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "请求数据无效"})
+		return
+	}
+
+	if err := h.Service.MarkAllRead(req.UserID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "已全部标记为已读"})
+}
+
+// RegisterNotifyRoutes 注册通知路由
+func RegisterNotifyRoutes(r *gin.Engine, db *gorm.DB) {
+	notifyService := NewNotifyService(db)
+	notifyHandler := NewNotifyHandler(notifyService)
+
+	r.GET("/notifications", notifyHandler.GetNotifications)
+	r.PUT("/notifications/read_all", notifyHandler.MarkAllRead)
+	r.PUT("/notifications/:notification_id/read", notifyHandler.MarkRead)
+}
@@ -0,0 +1,117 @@
+package notify
+
+import (
+	"errors"
+	"fmt"
+
+	"szu_market/internal/db"
+
+	"gorm.io/gorm"
+)
+
+// 通知类型
+const (
+	TypePriceDrop        = "price_drop"        // 收藏商品降价
+	TypeNewQuestion      = "new_question"      // 商品收到新提问（通知卖家）
+	TypeQuestionAnswered = "question_answered" // 提问已被卖家回答（通知提问者）
+	TypeCartReminder     = "cart_reminder"     // 购物车商品长时间未结算
+)
+
+// NotifyService 定义站内通知服务
+type NotifyService struct {
+	DB *gorm.DB
+}
+
+// NewNotifyService 创建新的通知服务实例
+func NewNotifyService(db *gorm.DB) *NotifyService {
+	return &NotifyService{DB: db}
+}
+
+// NotificationPage 通知分页结果
+type NotificationPage struct {
+	Total       int64             `json:"total"`
+	UnreadCount int64             `json:"unread_count"`
+	Page        int               `json:"page"`
+	PageSize    int               `json:"page_size"`
+	Items       []db.Notification `json:"items"`
+}
+
+// Send 批量写入通知，tx 可以是事务，便于与业务数据一起提交
+func Send(tx *gorm.DB, notifications []db.Notification) error {
+	if len(notifications) == 0 {
+		return nil
+	}
+	if err := tx.CreateInBatches(notifications, 200).Error; err != nil {
+		return fmt.Errorf("写入通知失败: %w", err)
+	}
+	return nil
+}
+
+// GetNotifications 分页获取用户通知，unreadOnly 为 true 时只返回未读通知
+func (s *NotifyService) GetNotifications(userID uint, unreadOnly bool, page, pageSize int) (*NotificationPage, error) {
+	if userID == 0 {
+		return nil, errors.New("用户未登录")
+	}
+	res := &NotificationPage{Page: page, PageSize: pageSize, Items: []db.Notification{}}
+
+	query := func() *gorm.DB {
+		q := s.DB.Model(&db.Notification{}).Where("user_id = ?", userID)
+		if unreadOnly {
+			q = q.Where("is_read = ?", false)
+		}
+		return q
+	}
+	if err := query().Count(&res.Total).Error; err != nil {
+		return nil, fmt.Errorf("查询失败: %w", err)
+	}
+	if err := s.DB.Model(&db.Notification{}).
+		Where("user_id = ? AND is_read = ?", userID, false).
+		Count(&res.UnreadCount).Error; err != nil {
+		return nil, fmt.Errorf("查询失败: %w", err)
+	}
+	if res.Total == 0 {
+		return res, nil
+	}
+
+	if err := query().Order("created_at DESC, notification_id DESC").
+		Offset((page - 1) * pageSize).Limit(pageSize).
+		Find(&res.Items).Error; err != nil {
+		return nil, fmt.Errorf("查询失败: %w", err)
+	}
+	return res, nil
+}
+
+// MarkRead 将单条通知标记为已读
+func (s *NotifyService) MarkRead(userID, notificationID uint) error {
+	if userID == 0 {
+		return errors.New("用户未登录")
+	}
+	var count int64
+	if err := s.DB.Model(&db.Notification{}).
+		Where("notification_id = ? AND user_id = ?", notificationID, userID).
+		Count(&count).Error; err != nil {
+		return fmt.Errorf("数据库查询失败: %w", err)
+	}
+	if count == 0 {
+		return errors.New("通知不存在")
+	}
+	if err := s.DB.Model(&db.Notification{}).
+		Where("notification_id = ?", notificationID).
+		Update("is_read", true).Error; err != nil {
+		return fmt.Errorf("标记已读失败: %w", err)
+	}
+	return nil
+}
+
+// MarkAllRead 将用户所有未读通知标记为已读
+func (s *NotifyService) MarkAllRead(userID uint) error {
+	if userID == 0 {
+		return errors.New("用户未登录")
+	}
+	if err := s.DB.Model(&db.Notification{}).
+		Where("user_id = ? AND is_read = ?", userID, false).
+		Update("is_read", true).Error; err != nil {
+		return fmt.Errorf("标记已读失败: %w", err)
+	}
+	return nil
+}
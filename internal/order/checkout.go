@@ -0,0 +1,129 @@
+package order
+
+import (
+	"errors"
+	"fmt"
+
+	"szu_market/internal/cart"
+	"szu_market/internal/db"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CheckoutInput 购物车结算输入参数，只结算 Items 中指定的购物车项
+// Items 为空时结算购物车中所有已勾选且可购买的商品
+type CheckoutInput struct {
+	UserID     uint           `json:"user_id"`
+	TotalPrice float64        `json:"totalPrice"`
+	AddressID  uint           `json:"address_id"`
+	Items      []cart.ItemKey `json:"items"`
+	CouponID   uint           `json:"coupon_id"`
+}
+
+// Checkout 结算购物车中选中的商品：创建订单并将对应购物车项标记为已购买
+// 购买数量以数据库中的购物车数量为准，未选中的购物车项不受影响
+func (s *OrderService) Checkout(input *CheckoutInput) (*OrderResponse, error) {
+	if input.UserID == 0 {
+		return nil, errors.New("用户未登录")
+	}
+	seen := make(map[cart.ItemKey]bool, len(input.Items))
+	for _, item := range input.Items {
+		if seen[item] {
+			return nil, errors.New("结算商品重复")
+		}
+		seen[item] = true
+	}
+
+	orderInput := &CreateOrderInput{
+		UserID:     input.UserID,
+		TotalPrice: input.TotalPrice,
+		AddressID:  input.AddressID,
+		CouponID:   input.CouponID,
+	}
+	if orderInput.TotalPrice < 0 {
+		return nil, errors.New("无效的订单总价")
+	}
+	s.fillDefaultAddress(orderInput)
+
+	var newOrder *db.Order
+	var snap *cart.Snapshot
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		// 锁定要结算的购物车项，防止并发结算或修改数量
+		query := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ? AND status = ?", input.UserID, "in_cart")
+		if len(input.Items) > 0 {
+			pairs := make([][]interface{}, 0, len(input.Items))
+			for _, item := range input.Items {
+				pairs = append(pairs, []interface{}{item.ProductID, item.SKUID})
+			}
+			query = query.Where("(product_id, sku_id) IN ?", pairs)
+		} else {
+			query = query.Where("selected = ?", true)
+		}
+		var items []db.CartItem
+		if err := query.Order("cart_id").Find(&items).Error; err != nil {
+			return fmt.Errorf("查询购物车失败: %w", err)
+		}
+		if len(input.Items) == 0 {
+			// 与购物车汇总一致，已勾选但已下架、失效或库存不足的商品不参与结算
+			var err error
+			if items, err = cart.PurchasableItems(tx, items); err != nil {
+				return err
+			}
+		}
+		if len(items) == 0 {
+			return errors.New("请选择要结算的商品")
+		}
+		if len(input.Items) > 0 && len(items) != len(input.Items) {
+			return errors.New("购物车中部分商品不存在，请刷新后重试")
+		}
+
+		cartIDs := make([]uint, 0, len(items))
+		hasSKU := false
+		for _, item := range items {
+			if item.Quantity <= 0 {
+				return errors.New("购物车商品数量无效")
+			}
+			cartIDs = append(cartIDs, item.CartID)
+			orderInput.ProductIDs = append(orderInput.ProductIDs, item.ProductID)
+			orderInput.ProductQuantities = append(orderInput.ProductQuantities, uint(item.Quantity))
+			orderInput.SKUIDs = append(orderInput.SKUIDs, item.SKUID)
+			if item.SKUID != 0 {
+				hasSKU = true
+			}
+		}
+		if !hasSKU {
+			orderInput.SKUIDs = nil
+		}
+
+		var err error
+		newOrder, err = placeOrder(tx, orderInput)
+		if err != nil {
+			return err
+		}
+
+		result := tx.Model(&db.CartItem{}).
+			Where("cart_id IN ? AND status = ?", cartIDs, "in_cart").
+			Update("status", "purchased")
+		if result.Error != nil {
+			return fmt.Errorf("更新购物车状态失败: %w", result.Error)
+		}
+		if result.RowsAffected != int64(len(cartIDs)) {
+			return errors.New("购物车已变化，请刷新后重试")
+		}
+		snap, err = cart.Commit(tx, input.UserID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	snap.Apply()
+	s.afterOrderPlaced(newOrder, orderInput)
+	return &OrderResponse{
+		OrderID:    newOrder.OrderID,
+		TotalPrice: newOrder.TotalPrice,
+		AddressID:  newOrder.AddressID,
+	}, nil
+}
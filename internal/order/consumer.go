@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"szu_market/internal/cache"
 	"szu_market/internal/db"
 	"time"
 
@@ -15,12 +16,13 @@ import (
 
 // ConsumerService 定义消费者服务
 type ConsumerService struct {
-	DB *gorm.DB
+	DB       *gorm.DB
+	producer *KafkaProducer // 秒杀下单成功后发送支付与通知消息
 }
 
 // NewConsumerService 创建新的消费者服务
-func NewConsumerService(db *gorm.DB) *ConsumerService {
-	return &ConsumerService{DB: db}
+func NewConsumerService(db *gorm.DB, p *KafkaProducer) *ConsumerService {
+	return &ConsumerService{DB: db, producer: p}
 }
 
 const maxWorker = 50    // 最大并发处理数，按需调
@@ -31,7 +33,9 @@ func (c *ConsumerService) StartConsumers() {
 		go c.consumePaymentMessages(i)
 		go c.consumeSalesMessages(i)
 		go c.consumeNoticeMessages(i)
+		go c.consumeFlashSaleMessages(i)
 	}
+	go c.sweepFlashSales()
 }
 
 // 消费支付消息（增加并发处理）
@@ -117,6 +121,43 @@ func (c *ConsumerService) consumeSalesMessages(id int) {
 	}
 }
 
+// consumeFlashSaleMessages 处理秒杀下单消息
+// 处理完成后才提交位移，进程在处理前退出时消息会重新投递；
+// 同一 reader 内按顺序处理，避免后面的消息先提交导致前面未处理的消息被跳过，并发由多个消费者提供
+func (c *ConsumerService) consumeFlashSaleMessages(id int) {
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:  []string{"kafka:9092"},
+		Topic:    flashSaleTopic,
+		GroupID:  "flash-sale-group",
+		MinBytes: 10e3,
+		MaxBytes: 10e6,
+	})
+	defer reader.Close()
+
+	ctx := context.Background()
+	for {
+		msg, err := reader.FetchMessage(ctx)
+		if err != nil {
+			log.Printf("秒杀消息消费错误: %v", err)
+			continue
+		}
+
+		var data flashSaleMessage
+		if err := json.Unmarshal(msg.Value, &data); err != nil {
+			log.Printf("秒杀消息解析失败: %v", err)
+		} else if err := c.processFlashSale(&data); err != nil {
+			log.Printf("秒杀下单失败: 活动ID %d, 用户ID %d, 错误: %v", data.FlashSaleID, data.UserID, err)
+		} else {
+			log.Printf("秒杀下单完成: 活动ID %d, 用户ID %d", data.FlashSaleID, data.UserID)
+		}
+
+		// 下单失败时已退回库存或由清理任务处理，不再重复投递
+		if err := reader.CommitMessages(ctx, msg); err != nil {
+			log.Printf("秒杀消息位移提交失败: %v", err)
+		}
+	}
+}
+
 // consumeNoticeMessages 并发处理通知消息
 func (c *ConsumerService) consumeNoticeMessages(id int) {
 	reader := kafka.NewReader(kafka.ReaderConfig{
@@ -165,8 +206,8 @@ func (c *ConsumerService) processPayment(orderID uint) error {
 		return err
 	}
 
-	// 幂等性检查：避免重复处理
-	if order.PaymentStatus == "已付款" {
+	// 幂等性检查：避免重复处理，已取消的订单不再支付
+	if order.PaymentStatus != "未付款" {
 		return nil
 	}
 
@@ -177,11 +218,10 @@ func (c *ConsumerService) processPayment(orderID uint) error {
 		return fmt.Errorf("模拟支付失败: %w", err)
 	}
 	fmt.Println("交易号:" + paymentID)
-	// 更新订单状态
-	order.PaymentStatus = "已付款"
-	order.Status = "等待发货"
-
-	return c.DB.Save(&order).Error
+	// 更新订单状态，只更新仍未付款的订单，避免覆盖支付期间发生的取消
+	return c.DB.Model(&db.Order{}).
+		Where("order_id = ? AND payment_status = ?", orderID, "未付款").
+		Updates(map[string]interface{}{"payment_status": "已付款", "status": "等待发货"}).Error
 }
 
 // 模拟支付街廓
@@ -201,10 +241,14 @@ func (c *ConsumerService) simulatePayment(orderID uint, amount float64) (string,
 
 // increaseSales 增加产品销量
 func (c *ConsumerService) increaseSales(productID, quantity uint) error {
-	return c.DB.Model(&db.SpecialProduct{}).
+	if err := c.DB.Model(&db.SpecialProduct{}).
 		Where("product_id = ?", productID).
 		UpdateColumn("sales", gorm.Expr("sales + ?", quantity)).
-		Error
+		Error; err != nil {
+		return err
+	}
+	cache.InvalidateProducts(productID)
+	return nil
 }
 
 // sendNotification 发送通知
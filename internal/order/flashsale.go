@@ -0,0 +1,624 @@
+package order
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"szu_market/internal/cache"
+	"szu_market/internal/db"
+	"szu_market/internal/limit"
+
+	"github.com/go-redis/redis/v8"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 秒杀流程：
+//   - 创建活动时把活动库存加载到 Redis；
+//   - 抢购请求用 Lua 脚本原子地校验每人一件、扣减 Redis 库存并记录排队状态，随后把下单消息写入 Kafka，立即返回排队中；
+//   - 消费者在事务中扣减数据库中的活动库存并创建订单，flash_sale_orders 的唯一索引保证重复消息不会重复下单；
+//   - 下单失败时退回 Redis 库存与抢购资格，结果写入 Redis，客户端轮询结果接口获取订单号；
+//   - 排队超时仍未处理的抢购（如消息丢失）由定时任务退回库存与资格；
+//   - 秒杀订单取消后退回活动库存与抢购资格，用户可重新抢购。
+
+const (
+	flashSaleTopic  = "flashSaleQueue"
+	flashSaleKeyTTL = 24 * time.Hour // 活动结束后 Redis 数据的保留时长
+
+	flashQueueTimeout  = 10 * time.Minute // 排队超过该时长仍未下单的抢购视为消息丢失
+	flashSweepInterval = time.Minute      // 清理排队超时抢购的间隔
+	flashSweepLockTTL  = time.Minute
+
+	flashBusyReason = "系统繁忙，请重试" // 内部错误对用户展示的失败原因
+)
+
+// 秒杀结果状态
+const (
+	FlashQueued  = "queued"  // 排队下单中
+	FlashSuccess = "success" // 下单成功
+	FlashFailed  = "failed"  // 下单失败，可重新抢购
+	FlashNone    = "none"    // 未参与
+)
+
+var (
+	ErrFlashSaleNotFound = errors.New("秒杀活动不存在")
+	ErrFlashSoldOut      = errors.New("已售罄")
+	ErrFlashRepeated     = errors.New("每人限购一件，请勿重复抢购")
+
+	errFlashProductOff = errors.New("商品已下架")
+)
+
+// reserveScript 抢购资格与库存的原子扣减
+// KEYS[1] 库存，KEYS[2] 已抢购用户集合，KEYS[3] 结果哈希；ARGV[1] 用户ID，ARGV[2] 排队结果（含排队时间）
+// 返回 1 成功，0 售罄，-1 重复抢购，-2 库存未加载
+var reserveScript = redis.NewScript(`
+if redis.call('SISMEMBER', KEYS[2], ARGV[1]) == 1 then return -1 end
+local stock = redis.call('GET', KEYS[1])
+if not stock then return -2 end
+if tonumber(stock) <= 0 then return 0 end
+redis.call('DECR', KEYS[1])
+redis.call('SADD', KEYS[2], ARGV[1])
+redis.call('HSET', KEYS[3], ARGV[1], ARGV[2])
+return 1
+`)
+
+// releaseScript 下单失败或订单取消时退回库存与抢购资格，并写入结果
+// 库存未加载时不退回，下次加载会按数据库中的剩余库存重新计算
+// KEYS 同 reserveScript；ARGV[1] 用户ID，ARGV[2] 结果
+var releaseScript = redis.NewScript(`
+if redis.call('SREM', KEYS[2], ARGV[1]) == 1 and redis.call('EXISTS', KEYS[1]) == 1 then
+  redis.call('INCR', KEYS[1])
+end
+redis.call('HSET', KEYS[3], ARGV[1], ARGV[2])
+return 1
+`)
+
+// sweepScript 结果仍是 ARGV[2] 时才退回库存与资格，避免覆盖消费者刚写入的结果
+// KEYS 同 reserveScript；ARGV[1] 用户ID，ARGV[2] 读到的排队结果，ARGV[3] 新结果
+var sweepScript = redis.NewScript(`
+if redis.call('HGET', KEYS[3], ARGV[1]) ~= ARGV[2] then return 0 end
+if redis.call('SREM', KEYS[2], ARGV[1]) == 1 and redis.call('EXISTS', KEYS[1]) == 1 then
+  redis.call('INCR', KEYS[1])
+end
+redis.call('HSET', KEYS[3], ARGV[1], ARGV[3])
+return 1
+`)
+
+func flashSaleKeys(flashSaleID uint) []string {
+	prefix := fmt.Sprintf("flashsale:%d:", flashSaleID)
+	return []string{prefix + "stock", prefix + "users", prefix + "results"}
+}
+
+// FlashSaleService 定义秒杀服务
+type FlashSaleService struct {
+	DB       *gorm.DB
+	producer *KafkaProducer
+}
+
+// NewFlashSaleService 创建新的秒杀服务实例
+func NewFlashSaleService(db *gorm.DB, p *KafkaProducer) *FlashSaleService {
+	return &FlashSaleService{DB: db, producer: p}
+}
+
+// FlashSaleInput 创建秒杀活动的输入，时间格式为 2006-01-02 15:04:05
+type FlashSaleInput struct {
+	ProductID uint   `json:"product_id"`
+	SKUID     uint   `json:"sku_id"`
+	Price     string `json:"price"`
+	Stock     uint   `json:"stock"`
+	StartAt   string `json:"start_at"`
+	EndAt     string `json:"end_at"`
+}
+
+// CreateFlashSale 创建秒杀活动并把库存加载到 Redis
+func (s *FlashSaleService) CreateFlashSale(input *FlashSaleInput) (*db.FlashSale, error) {
+	if input.Stock == 0 {
+		return nil, errors.New("活动库存必须大于 0")
+	}
+	if cents, err := db.PriceToCents(input.Price); err != nil || cents <= 0 {
+		return nil, errors.New("秒杀价无效")
+	}
+	startAt, err := time.ParseInLocation(time.DateTime, input.StartAt, time.Local)
+	if err != nil {
+		return nil, errors.New("开始时间格式无效")
+	}
+	endAt, err := time.ParseInLocation(time.DateTime, input.EndAt, time.Local)
+	if err != nil {
+		return nil, errors.New("结束时间格式无效")
+	}
+	if !endAt.After(startAt) || !endAt.After(time.Now()) {
+		return nil, errors.New("结束时间无效")
+	}
+
+	var product db.SpecialProduct
+	if err := s.DB.Preload("SKUs", "is_active = ?", true).First(&product, input.ProductID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("商品不存在")
+		}
+		return nil, fmt.Errorf("查询商品失败: %w", err)
+	}
+	if err := checkFlashProduct(&product); err != nil {
+		return nil, err
+	}
+	// 只加载上架的规格，规格全部下架的商品按无规格商品参与秒杀
+	if input.SKUID == 0 && len(product.SKUs) > 0 {
+		return nil, errors.New("请选择参与秒杀的规格")
+	}
+	if input.SKUID != 0 {
+		var sku *db.ProductSKU
+		for i := range product.SKUs {
+			if product.SKUs[i].SKUID == input.SKUID {
+				sku = &product.SKUs[i]
+			}
+		}
+		if sku == nil || !sku.IsActive {
+			return nil, errors.New("规格不存在或已下架")
+		}
+		if int(input.Stock) > sku.Stock {
+			return nil, fmt.Errorf("活动库存不能超过规格库存 %d", sku.Stock)
+		}
+	}
+
+	sale := &db.FlashSale{
+		ProductID: input.ProductID,
+		SKUID:     input.SKUID,
+		Price:     input.Price,
+		Stock:     input.Stock,
+		StartAt:   startAt,
+		EndAt:     endAt,
+	}
+	if err := s.DB.Create(sale).Error; err != nil {
+		return nil, fmt.Errorf("创建秒杀活动失败: %w", err)
+	}
+	if err := s.loadStock(sale); err != nil {
+		// 抢购时发现库存未加载会重新加载
+		log.Printf("WARN: 加载秒杀库存失败 flash_sale:%d - %v", sale.FlashSaleID, err)
+	}
+	return sale, nil
+}
+
+// loadStock 将活动剩余库存和已成功抢购的用户加载到 Redis，已加载时不覆盖
+func (s *FlashSaleService) loadStock(sale *db.FlashSale) error {
+	ctx := context.Background()
+	keys := flashSaleKeys(sale.FlashSaleID)
+	expireAt := sale.EndAt.Add(flashSaleKeyTTL)
+
+	var userIDs []uint
+	if err := s.DB.Model(&db.FlashSaleOrder{}).
+		Where("flash_sale_id = ?", sale.FlashSaleID).
+		Pluck("user_id", &userIDs).Error; err != nil {
+		return fmt.Errorf("查询秒杀记录失败: %w", err)
+	}
+	ok, err := db.RDB.SetNX(ctx, keys[0], sale.Stock-sale.Sold, time.Until(expireAt)).Result()
+	if err != nil {
+		return err
+	}
+	if !ok || len(userIDs) == 0 {
+		return nil
+	}
+	members := make([]interface{}, 0, len(userIDs))
+	for _, id := range userIDs {
+		members = append(members, id)
+	}
+	pipe := db.RDB.TxPipeline()
+	pipe.SAdd(ctx, keys[1], members...)
+	pipe.ExpireAt(ctx, keys[1], expireAt)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// GetFlashSales 获取未结束的秒杀活动，按开始时间排列
+func (s *FlashSaleService) GetFlashSales() ([]db.FlashSale, error) {
+	sales := []db.FlashSale{}
+	if err := s.DB.Where("end_at > ?", time.Now()).
+		Order("start_at, flash_sale_id").Find(&sales).Error; err != nil {
+		return nil, fmt.Errorf("查询秒杀活动失败: %w", err)
+	}
+	return sales, nil
+}
+
+// flashSaleMessage 秒杀下单消息
+type flashSaleMessage struct {
+	FlashSaleID uint `json:"flash_sale_id"`
+	UserID      uint `json:"user_id"`
+	AddressID   uint `json:"address_id"`
+}
+
+// JoinInput 参与秒杀的输入，未指定收货地址时使用默认地址
+type JoinInput struct {
+	UserID    uint `json:"user_id"`
+	AddressID uint `json:"address_id"`
+}
+
+// Join 参与秒杀：在 Redis 中扣减库存后把下单请求放入队列，返回时订单尚未创建
+func (s *FlashSaleService) Join(flashSaleID uint, input *JoinInput) error {
+	if input.UserID == 0 {
+		return errors.New("用户未登录")
+	}
+	var sale db.FlashSale
+	if err := s.DB.Where("flash_sale_id = ?", flashSaleID).Limit(1).Find(&sale).Error; err != nil {
+		return fmt.Errorf("查询秒杀活动失败: %w", err)
+	}
+	if sale.FlashSaleID == 0 {
+		return ErrFlashSaleNotFound
+	}
+	now := time.Now()
+	if now.Before(sale.StartAt) {
+		return errors.New("秒杀尚未开始")
+	}
+	if !now.Before(sale.EndAt) {
+		return errors.New("秒杀已结束")
+	}
+	product, err := cache.GetProduct(s.DB, sale.ProductID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	if err != nil || product.DeletedAt.Valid {
+		return errFlashProductOff
+	}
+	if err := checkFlashProduct(product); err != nil {
+		return err
+	}
+
+	if input.AddressID == 0 {
+		var address db.Address
+		if err := s.DB.Where("user_id = ? AND is_default = ?", input.UserID, true).
+			Limit(1).Find(&address).Error; err != nil {
+			return fmt.Errorf("查询默认地址失败: %w", err)
+		}
+		if address.AddressID == 0 {
+			return errors.New("请先设置收货地址")
+		}
+		input.AddressID = address.AddressID
+	} else {
+		var count int64
+		if err := s.DB.Model(&db.Address{}).
+			Where("address_id = ? AND user_id = ?", input.AddressID, input.UserID).
+			Count(&count).Error; err != nil {
+			return fmt.Errorf("查询收货地址失败: %w", err)
+		}
+		if count == 0 {
+			return errors.New("收货地址不存在")
+		}
+	}
+
+	ctx := context.Background()
+	keys := flashSaleKeys(flashSaleID)
+	queued := fmt.Sprintf("%s:%d", FlashQueued, now.Unix())
+	n, err := reserveScript.Run(ctx, db.RDB, keys, input.UserID, queued).Int()
+	if err == nil && n == -2 {
+		if err := s.loadStock(&sale); err != nil {
+			return fmt.Errorf("加载秒杀库存失败: %w", err)
+		}
+		n, err = reserveScript.Run(ctx, db.RDB, keys, input.UserID, queued).Int()
+	}
+	if err != nil {
+		return fmt.Errorf("抢购失败: %w", err)
+	}
+	switch n {
+	case 0:
+		return ErrFlashSoldOut
+	case -1:
+		return ErrFlashRepeated
+	case 1:
+	default:
+		return errors.New("抢购失败，请重试")
+	}
+
+	expireAt := sale.EndAt.Add(flashSaleKeyTTL)
+	db.RDB.ExpireAt(ctx, keys[1], expireAt)
+	db.RDB.ExpireAt(ctx, keys[2], expireAt)
+
+	msg := flashSaleMessage{FlashSaleID: flashSaleID, UserID: input.UserID, AddressID: input.AddressID}
+	if err := s.producer.SendMessage(flashSaleTopic, strconv.FormatUint(uint64(input.UserID), 10), msg); err != nil {
+		releaseFlashSale(flashSaleID, input.UserID, FlashFailed+":"+flashBusyReason)
+		return fmt.Errorf("抢购排队失败: %w", err)
+	}
+	return nil
+}
+
+// checkFlashProduct 校验秒杀商品仍在售，已删除的商品由调用方判断
+func checkFlashProduct(product *db.SpecialProduct) error {
+	if !product.IsActive || product.IsViolation {
+		return errFlashProductOff
+	}
+	return nil
+}
+
+// releaseFlashSale 退回 Redis 中的库存与抢购资格并记录结果
+func releaseFlashSale(flashSaleID, userID uint, result string) {
+	if err := releaseScript.Run(context.Background(), db.RDB, flashSaleKeys(flashSaleID), userID, result).Err(); err != nil {
+		log.Printf("WARN: 退回秒杀库存失败 flash_sale:%d user:%d - %v", flashSaleID, userID, err)
+	}
+}
+
+// releaseFlashSaleOrder 在取消订单的事务中退回秒杀活动库存并删除下单记录
+// 订单不是秒杀订单时 record 保持为空；Redis 中的库存与资格由调用方在提交后退回
+func releaseFlashSaleOrder(tx *gorm.DB, orderID uint, record *db.FlashSaleOrder, sale *db.FlashSale) error {
+	if err := tx.Where("order_id = ?", orderID).Limit(1).Find(record).Error; err != nil {
+		return fmt.Errorf("查询秒杀记录失败: %w", err)
+	}
+	if record.RecordID == 0 {
+		return nil
+	}
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(sale, record.FlashSaleID).Error; err != nil {
+		return fmt.Errorf("查询秒杀活动失败: %w", err)
+	}
+	if err := tx.Model(&db.FlashSale{}).
+		Where("flash_sale_id = ? AND sold > 0", record.FlashSaleID).
+		UpdateColumn("sold", gorm.Expr("sold - 1")).Error; err != nil {
+		return fmt.Errorf("退回活动库存失败: %w", err)
+	}
+	if err := tx.Delete(record).Error; err != nil {
+		return fmt.Errorf("删除秒杀记录失败: %w", err)
+	}
+	return nil
+}
+
+// FlashSaleResult 秒杀下单结果
+type FlashSaleResult struct {
+	Status  string `json:"status"` // 见 Flash* 常量
+	OrderID uint   `json:"order_id,omitempty"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+// GetResult 查询用户的秒杀结果，Redis 中没有记录时以数据库中的下单记录为准
+func (s *FlashSaleService) GetResult(flashSaleID, userID uint) (*FlashSaleResult, error) {
+	value, err := db.RDB.HGet(context.Background(), flashSaleKeys(flashSaleID)[2], strconv.FormatUint(uint64(userID), 10)).Result()
+	if err == nil {
+		return parseFlashResult(value), nil
+	}
+	if !errors.Is(err, redis.Nil) {
+		log.Printf("WARN: 读取秒杀结果失败 flash_sale:%d user:%d - %v", flashSaleID, userID, err)
+	}
+
+	var record db.FlashSaleOrder
+	if err := s.DB.Where("flash_sale_id = ? AND user_id = ?", flashSaleID, userID).
+		Limit(1).Find(&record).Error; err != nil {
+		return nil, fmt.Errorf("查询秒杀结果失败: %w", err)
+	}
+	if record.RecordID == 0 {
+		return &FlashSaleResult{Status: FlashNone}, nil
+	}
+	return &FlashSaleResult{Status: FlashSuccess, OrderID: record.OrderID}, nil
+}
+
+// parseFlashResult 解析结果哈希中的值：queued:<排队时间>、success:<order_id>、failed:<原因>
+func parseFlashResult(value string) *FlashSaleResult {
+	status, detail, _ := strings.Cut(value, ":")
+	res := &FlashSaleResult{Status: status}
+	switch status {
+	case FlashSuccess:
+		id, _ := strconv.ParseUint(detail, 10, 32)
+		res.OrderID = uint(id)
+	case FlashFailed:
+		res.Reason = detail
+	}
+	return res
+}
+
+// processFlashSale 消费秒杀下单消息：扣减活动库存、创建订单并记录结果
+// 已有下单记录时直接返回，重复消息不会重复下单
+func (c *ConsumerService) processFlashSale(msg *flashSaleMessage) error {
+	var existing db.FlashSaleOrder
+	if err := c.DB.Where("flash_sale_id = ? AND user_id = ?", msg.FlashSaleID, msg.UserID).
+		Limit(1).Find(&existing).Error; err != nil {
+		return fmt.Errorf("查询秒杀记录失败: %w", err)
+	}
+	if existing.RecordID != 0 {
+		// 下单后结果未写入 Redis 时消息可能重新投递，补写结果
+		writeFlashSuccess(msg.FlashSaleID, msg.UserID, existing.OrderID)
+		return nil
+	}
+	// 排队超时的抢购已被清理任务退回，不再下单
+	value, err := db.RDB.HGet(context.Background(), flashSaleKeys(msg.FlashSaleID)[2],
+		strconv.FormatUint(uint64(msg.UserID), 10)).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return fmt.Errorf("读取秒杀结果失败: %w", err)
+	}
+	if parseFlashResult(value).Status != FlashQueued {
+		log.Printf("秒杀排队已失效，跳过下单: 活动ID %d, 用户ID %d, 结果 %q", msg.FlashSaleID, msg.UserID, value)
+		return nil
+	}
+
+	var sale db.FlashSale
+	var newOrder db.Order
+	err = c.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&sale, msg.FlashSaleID).Error; err != nil {
+			return fmt.Errorf("查询秒杀活动失败: %w", err)
+		}
+		// 排队期间商品可能被下架或删除
+		var product db.SpecialProduct
+		if err := tx.First(&product, sale.ProductID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errFlashProductOff
+			}
+			return fmt.Errorf("查询商品失败: %w", err)
+		}
+		if err := checkFlashProduct(&product); err != nil {
+			return err
+		}
+		// 秒杀订单同样计入商品限购，锁定买家后再统计已购数量
+		if product.PurchaseLimit > 0 {
+			if err := limit.Lock(tx, msg.UserID); err != nil {
+				return err
+			}
+			if err := limit.Check(tx, msg.UserID, &product, 0, 1); err != nil {
+				return err
+			}
+		}
+		result := tx.Model(&db.FlashSale{}).
+			Where("flash_sale_id = ? AND sold < stock", msg.FlashSaleID).
+			UpdateColumn("sold", gorm.Expr("sold + 1"))
+		if result.Error != nil {
+			return fmt.Errorf("扣减活动库存失败: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return ErrFlashSoldOut
+		}
+
+		price, err := db.PriceToCents(sale.Price)
+		if err != nil {
+			return fmt.Errorf("秒杀价无效: %w", err)
+		}
+		newOrder = db.Order{
+			UserID:         msg.UserID,
+			TotalPrice:     float64(price) / 100,
+			Status:         "待付款",
+			PaymentStatus:  "未付款",
+			AddressID:      msg.AddressID,
+			CouponDiscount: "0",
+		}
+		if err := tx.Create(&newOrder).Error; err != nil {
+			return fmt.Errorf("创建订单失败: %w", err)
+		}
+		if sale.SKUID != 0 {
+			if err := deductSKUStock(tx, sale.ProductID, sale.SKUID, 1); err != nil {
+				return err
+			}
+		}
+		if err := tx.Create(&db.OrderProduct{
+			OrderID:   newOrder.OrderID,
+			ProductID: sale.ProductID,
+			SKUID:     sale.SKUID,
+			Num:       1,
+			Price:     sale.Price,
+		}).Error; err != nil {
+			return fmt.Errorf("插入订单产品失败: %w", err)
+		}
+		// 唯一索引保证同一用户在同一活动中只有一条下单记录
+		return tx.Create(&db.FlashSaleOrder{
+			FlashSaleID: msg.FlashSaleID,
+			UserID:      msg.UserID,
+			OrderID:     newOrder.OrderID,
+		}).Error
+	})
+	if err != nil {
+		// 重复消息并发处理时，另一条消息已成功下单
+		var record db.FlashSaleOrder
+		if c.DB.Where("flash_sale_id = ? AND user_id = ?", msg.FlashSaleID, msg.UserID).
+			Limit(1).Find(&record).Error == nil && record.RecordID != 0 {
+			return nil
+		}
+		releaseFlashSale(msg.FlashSaleID, msg.UserID, FlashFailed+":"+flashFailReason(err))
+		return err
+	}
+
+	writeFlashSuccess(msg.FlashSaleID, msg.UserID, newOrder.OrderID)
+	if sale.SKUID != 0 {
+		cache.InvalidateProducts(sale.ProductID)
+	}
+	if err := c.increaseSales(sale.ProductID, 1); err != nil {
+		log.Printf("销量更新失败: 产品ID %d, 错误: %v", sale.ProductID, err)
+	}
+
+	// 与普通下单一样进入支付与通知流程
+	orders := NewOrderService(c.DB, c.producer)
+	if err := orders.sendPaymentMessage(newOrder.OrderID); err != nil {
+		log.Printf("支付消息发送失败: %v", err)
+	}
+	if err := orders.sendNoticeMessage(newOrder.OrderID); err != nil {
+		log.Printf("通知消息发送失败: %v", err)
+	}
+	return nil
+}
+
+// writeFlashSuccess 写入下单成功的结果，结果哈希的过期时间在抢购时已设置
+func writeFlashSuccess(flashSaleID, userID, orderID uint) {
+	key := flashSaleKeys(flashSaleID)[2]
+	if err := db.RDB.HSet(context.Background(), key, userID, fmt.Sprintf("%s:%d", FlashSuccess, orderID)).Err(); err != nil {
+		log.Printf("WARN: 写入秒杀结果失败 flash_sale:%d user:%d - %v", flashSaleID, userID, err)
+	}
+}
+
+// flashFailReason 返回展示给用户的失败原因，内部错误统一提示系统繁忙
+func flashFailReason(err error) string {
+	var exceeded *limit.ExceededError
+	switch {
+	case errors.Is(err, ErrFlashSoldOut), errors.Is(err, errFlashProductOff):
+		return err.Error()
+	case errors.As(err, &exceeded):
+		return exceeded.Error()
+	}
+	return flashBusyReason
+}
+
+// sweepFlashSales 定时退回排队超时的抢购，避免消息丢失后用户一直排队、库存一直被占用
+func (c *ConsumerService) sweepFlashSales() {
+	ticker := time.NewTicker(flashSweepInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		c.sweepWithLock()
+	}
+}
+
+// sweepWithLock 获取分布式锁后清理，未抢到锁说明其他实例正在清理
+func (c *ConsumerService) sweepWithLock() {
+	unlock, ok, err := db.TryLock(context.Background(), "flashsale:sweep:lock", flashSweepLockTTL)
+	if err != nil {
+		log.Printf("WARN: 获取秒杀清理锁失败: %v", err)
+		return
+	}
+	if !ok {
+		return
+	}
+	defer unlock()
+
+	if err := c.SweepStaleQueued(); err != nil {
+		log.Printf("秒杀排队清理失败: %v", err)
+	}
+}
+
+// SweepStaleQueued 检查仍保留 Redis 数据的活动，排队超时的抢购已有下单记录时补写成功结果，否则退回库存与资格
+func (c *ConsumerService) SweepStaleQueued() error {
+	ctx := context.Background()
+	now := time.Now()
+	var sales []db.FlashSale
+	if err := c.DB.Where("start_at <= ? AND end_at > ?", now, now.Add(-flashSaleKeyTTL)).
+		Find(&sales).Error; err != nil {
+		return fmt.Errorf("查询秒杀活动失败: %w", err)
+	}
+
+	for _, sale := range sales {
+		keys := flashSaleKeys(sale.FlashSaleID)
+		results, err := db.RDB.HGetAll(ctx, keys[2]).Result()
+		if err != nil {
+			return fmt.Errorf("读取秒杀结果失败: %w", err)
+		}
+		for field, value := range results {
+			status, queuedAt, _ := strings.Cut(value, ":")
+			if status != FlashQueued {
+				continue
+			}
+			ts, err := strconv.ParseInt(queuedAt, 10, 64)
+			if err == nil && now.Sub(time.Unix(ts, 0)) < flashQueueTimeout {
+				continue
+			}
+			userID, err := strconv.ParseUint(field, 10, 32)
+			if err != nil {
+				continue
+			}
+
+			var record db.FlashSaleOrder
+			if err := c.DB.Where("flash_sale_id = ? AND user_id = ?", sale.FlashSaleID, userID).
+				Limit(1).Find(&record).Error; err != nil {
+				return fmt.Errorf("查询秒杀记录失败: %w", err)
+			}
+			if record.RecordID != 0 {
+				writeFlashSuccess(sale.FlashSaleID, uint(userID), record.OrderID)
+				continue
+			}
+			if err := sweepScript.Run(ctx, db.RDB, keys, userID, value, FlashFailed+":"+flashBusyReason).Err(); err != nil {
+				return fmt.Errorf("退回秒杀库存失败: %w", err)
+			}
+			log.Printf("秒杀排队超时已退回: 活动ID %d, 用户ID %d", sale.FlashSaleID, userID)
+		}
+	}
+	return nil
+}
@@ -1,10 +1,13 @@
 package order
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
 
+	"szu_market/internal/limit"
+
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
@@ -109,7 +112,28 @@ func (h *OrderHandler) CreateOrder(c *gin.Context) {
 	// 调用服务层创建订单
 	response, err := h.Service.CreateOrder(&input)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+		respondOrderError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":  true,
+		"message":  "订单创建成功",
+		"response": response,
+	})
+}
+
+// Checkout 购物车结算处理
+func (h *OrderHandler) Checkout(c *gin.Context) {
+	var input CheckoutInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "无效参数"})
+		return
+	}
+
+	response, err := h.Service.Checkout(&input)
+	if err != nil {
+		respondOrderError(c, err)
 		return
 	}
 
@@ -120,6 +144,39 @@ func (h *OrderHandler) CreateOrder(c *gin.Context) {
 	})
 }
 
+// respondOrderError 输出下单失败的响应
+func respondOrderError(c *gin.Context, err error) {
+	var priceErr *PriceChangedError
+	if errors.As(err, &priceErr) {
+		// 价格变化时返回最新价格明细，由前端提示用户确认后重新下单
+		c.JSON(http.StatusConflict, gin.H{
+			"success":         false,
+			"code":            "PRICE_CHANGED",
+			"message":         priceErr.Error(),
+			"expected_total":  priceErr.ExpectedTotal,
+			"actual_total":    priceErr.ActualTotal,
+			"subtotal":        priceErr.Subtotal,
+			"discount":        priceErr.Discount,
+			"coupon_discount": priceErr.CouponDiscount,
+			"shipping_fee":    priceErr.ShippingFee,
+			"items":           priceErr.Items,
+		})
+		return
+	}
+	var limitErr *limit.ExceededError
+	if errors.As(err, &limitErr) {
+		c.JSON(http.StatusConflict, gin.H{
+			"success":   false,
+			"code":      "PURCHASE_LIMIT",
+			"message":   limitErr.Error(),
+			"limit":     limitErr,
+			"remaining": limitErr.Remaining(),
+		})
+		return
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+}
+
 // CancelOrder 取消订单处理
 func (h *OrderHandler) CancelOrder(c *gin.Context) {
 	// 获取订单ID
@@ -191,6 +248,90 @@ func (h *OrderHandler) GetOrders(c *gin.Context) {
 	})
 }
 
+// FlashSaleHandler 秒杀处理程序
+type FlashSaleHandler struct {
+	Service *FlashSaleService
+}
+
+// NewFlashSaleHandler 创建新的秒杀处理程序
+func NewFlashSaleHandler(service *FlashSaleService) *FlashSaleHandler {
+	return &FlashSaleHandler{Service: service}
+}
+
+// CreateFlashSale 创建秒杀活动
+func (h *FlashSaleHandler) CreateFlashSale(c *gin.Context) {
+	var input FlashSaleInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "请求数据无效"})
+		return
+	}
+
+	sale, err := h.Service.CreateFlashSale(&input)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"success": true, "data": sale})
+}
+
+// GetFlashSales 获取进行中和即将开始的秒杀活动
+func (h *FlashSaleHandler) GetFlashSales(c *gin.Context) {
+	sales, err := h.Service.GetFlashSales()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": sales})
+}
+
+// Join 参与秒杀，抢购成功后订单在队列中异步创建，需轮询结果接口
+func (h *FlashSaleHandler) Join(c *gin.Context) {
+	flashSaleID, err := strconv.ParseUint(c.Param("flash_sale_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "活动ID无效"})
+		return
+	}
+	var input JoinInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "请求数据无效"})
+		return
+	}
+
+	if err := h.Service.Join(uint(flashSaleID), &input); err != nil {
+		switch {
+		case errors.Is(err, ErrFlashSaleNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "message": err.Error()})
+		case errors.Is(err, ErrFlashSoldOut), errors.Is(err, ErrFlashRepeated):
+			c.JSON(http.StatusConflict, gin.H{"success": false, "message": err.Error()})
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+		}
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"success": true, "message": "抢购成功，订单创建中", "status": FlashQueued})
+}
+
+// GetFlashSaleResult 查询秒杀下单结果
+func (h *FlashSaleHandler) GetFlashSaleResult(c *gin.Context) {
+	flashSaleID, err := strconv.ParseUint(c.Param("flash_sale_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "活动ID无效"})
+		return
+	}
+	userID, err := strconv.ParseUint(c.Query("user_id"), 10, 32)
+	if err != nil || userID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "用户ID无效"})
+		return
+	}
+
+	result, err := h.Service.GetResult(uint(flashSaleID), uint(userID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": result})
+}
+
 // RegisterOrderRoutes 注册订单路由
 func RegisterOrderRoutes(r *gin.Engine, db *gorm.DB, p *KafkaProducer) {
 	// 创建服务和处理程序
@@ -198,12 +339,18 @@ func RegisterOrderRoutes(r *gin.Engine, db *gorm.DB, p *KafkaProducer) {
 	orderHandler := NewOrderHandler(orderService)
 	addressService := NewAddressService(db)
 	addressHandler := NewAddressHandler(addressService)
+	flashSaleHandler := NewFlashSaleHandler(NewFlashSaleService(db, p))
 	// 注册订单路由
 	r.POST("/orders", orderHandler.CreateOrder)
+	r.POST("/cart/checkout", orderHandler.Checkout)
 	r.DELETE("/orders/:order_id", orderHandler.CancelOrder)
 	r.POST("/orders/:order_id/pay", orderHandler.PayOrder)
 	r.POST("/addresses", addressHandler.CreateAddress)
 	r.GET("/addresses", addressHandler.GetAddressItem)
 	r.GET("/orders", orderHandler.GetOrders)
 	r.DELETE("/addresses/:addressId", addressHandler.RemoveAddressItem)
+	r.POST("/admin/flash_sales", flashSaleHandler.CreateFlashSale)
+	r.GET("/flash_sales", flashSaleHandler.GetFlashSales)
+	r.POST("/flash_sales/:flash_sale_id/orders", flashSaleHandler.Join)
+	r.GET("/flash_sales/:flash_sale_id/result", flashSaleHandler.GetFlashSaleResult)
 }
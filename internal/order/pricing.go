@@ -0,0 +1,188 @@
+package order
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"szu_market/internal/cart"
+	"szu_market/internal/coupon"
+	"szu_market/internal/db"
+
+	"gorm.io/gorm"
+)
+
+// PricedItem 服务端按当前价格计算的订单商品
+type PricedItem struct {
+	ProductID uint   `json:"product_id"`
+	SKUID     uint   `json:"sku_id"`
+	Quantity  uint   `json:"quantity"`
+	UnitPrice string `json:"unit_price"`
+	Subtotal  string `json:"subtotal"`
+
+	sellerID uint
+	category string
+	cents    int64
+}
+
+// OrderPricing 服务端计算的订单金额，金额单位为分
+type OrderPricing struct {
+	Items          []PricedItem
+	Subtotal       int64 // 商品总额
+	Discount       int64 // 优惠金额，含卖家满减与优惠券
+	CouponDiscount int64 // 其中优惠券抵扣的金额
+	Shipping       int64 // 运费
+	Total          int64 // 应付金额
+}
+
+// PriceChangedError 客户端提交的订单总价与服务端按当前价格计算的结果不一致
+type PriceChangedError struct {
+	ExpectedTotal  string       `json:"expected_total"` // 客户端提交的总价
+	ActualTotal    string       `json:"actual_total"`   // 服务端计算的应付金额
+	Subtotal       string       `json:"subtotal"`
+	Discount       string       `json:"discount"`
+	CouponDiscount string       `json:"coupon_discount"`
+	ShippingFee    string       `json:"shipping_fee"`
+	Items          []PricedItem `json:"items"`
+}
+
+func (e *PriceChangedError) Error() string {
+	return fmt.Sprintf("商品价格已变化，当前应付 ¥%s", e.ActualTotal)
+}
+
+// priceOrder 按当前商品与规格价格计算订单金额，同时校验商品仍可购买
+// 返回的 Items 与 input.ProductIDs 一一对应
+func priceOrder(tx *gorm.DB, input *CreateOrderInput) (*OrderPricing, error) {
+	var products []db.SpecialProduct
+	if err := tx.Where("product_id IN ?", input.ProductIDs).Find(&products).Error; err != nil {
+		return nil, fmt.Errorf("查询商品失败: %w", err)
+	}
+	productMap := make(map[uint]db.SpecialProduct, len(products))
+	for _, p := range products {
+		productMap[p.ProductID] = p
+	}
+
+	// 有上架规格的商品必须选择规格，不能按商品基础价下单并跳过规格库存
+	var skuProductIDs []uint
+	if err := tx.Model(&db.ProductSKU{}).
+		Where("product_id IN ? AND is_active = ?", input.ProductIDs, true).
+		Distinct().Pluck("product_id", &skuProductIDs).Error; err != nil {
+		return nil, fmt.Errorf("查询规格失败: %w", err)
+	}
+	hasSKU := make(map[uint]bool, len(skuProductIDs))
+	for _, id := range skuProductIDs {
+		hasSKU[id] = true
+	}
+
+	skuMap := make(map[uint]db.ProductSKU)
+	if len(input.SKUIDs) > 0 {
+		var skus []db.ProductSKU
+		if err := tx.Where("sku_id IN ?", input.SKUIDs).Find(&skus).Error; err != nil {
+			return nil, fmt.Errorf("查询规格失败: %w", err)
+		}
+		for _, sku := range skus {
+			skuMap[sku.SKUID] = sku
+		}
+	}
+
+	pricing := &OrderPricing{Items: make([]PricedItem, 0, len(input.ProductIDs))}
+	sellerSubtotals := make(map[uint]int64)
+	for i, productID := range input.ProductIDs {
+		quantity := input.ProductQuantities[i]
+		if quantity == 0 {
+			return nil, errors.New("商品数量必须大于 0")
+		}
+
+		// 已删除的商品不会被查出
+		product, ok := productMap[productID]
+		if !ok {
+			return nil, fmt.Errorf("商品 %d 不存在或已删除", productID)
+		}
+		if !product.IsActive || product.IsViolation {
+			return nil, fmt.Errorf("商品「%s」已下架", product.ProductName)
+		}
+
+		price := product.Price
+		var skuID uint
+		if len(input.SKUIDs) > 0 {
+			skuID = input.SKUIDs[i]
+		}
+		if skuID != 0 {
+			sku, ok := skuMap[skuID]
+			if !ok || sku.ProductID != productID {
+				return nil, fmt.Errorf("商品「%s」的规格不存在", product.ProductName)
+			}
+			if !sku.IsActive {
+				return nil, fmt.Errorf("商品「%s」的规格已下架", product.ProductName)
+			}
+			price = sku.Price
+		} else if hasSKU[productID] {
+			return nil, fmt.Errorf("商品「%s」请选择规格", product.ProductName)
+		}
+
+		unitCents, err := db.PriceToCents(price)
+		if err != nil {
+			return nil, fmt.Errorf("商品「%s」价格无效", product.ProductName)
+		}
+		subtotal := unitCents * int64(quantity)
+		pricing.Subtotal += subtotal
+		sellerSubtotals[product.UserID] += subtotal
+		pricing.Items = append(pricing.Items, PricedItem{
+			ProductID: productID,
+			SKUID:     skuID,
+			Quantity:  quantity,
+			UnitPrice: db.CentsToPrice(unitCents),
+			Subtotal:  db.CentsToPrice(subtotal),
+			sellerID:  product.UserID,
+			category:  product.Category,
+			cents:     subtotal,
+		})
+	}
+
+	// 卖家满减与运费与购物车分组展示使用相同规则
+	charges, err := cart.SellerCharges(tx, sellerSubtotals)
+	if err != nil {
+		return nil, err
+	}
+	for _, charge := range charges {
+		pricing.Discount += charge.Discount
+		pricing.Shipping += charge.ShippingFee
+	}
+
+	// 优惠券按商品金额计算，与卖家满减叠加
+	if input.CouponID != 0 {
+		lines := make([]coupon.Line, 0, len(pricing.Items))
+		for _, item := range pricing.Items {
+			lines = append(lines, coupon.Line{SellerID: item.sellerID, Category: item.category, Subtotal: item.cents})
+		}
+		pricing.CouponDiscount, err = coupon.Apply(tx, input.UserID, input.CouponID, lines)
+		if err != nil {
+			return nil, err
+		}
+		pricing.Discount += pricing.CouponDiscount
+	}
+
+	pricing.Total = pricing.Subtotal - pricing.Discount
+	if pricing.Total < 0 {
+		pricing.Total = 0
+	}
+	pricing.Total += pricing.Shipping
+	return pricing, nil
+}
+
+// checkExpectedTotal 校验客户端看到的总价与服务端计算结果一致
+func checkExpectedTotal(expected float64, pricing *OrderPricing) error {
+	expectedCents := int64(math.Round(expected * 100))
+	if expectedCents == pricing.Total {
+		return nil
+	}
+	return &PriceChangedError{
+		ExpectedTotal:  db.CentsToPrice(expectedCents),
+		ActualTotal:    db.CentsToPrice(pricing.Total),
+		Subtotal:       db.CentsToPrice(pricing.Subtotal),
+		Discount:       db.CentsToPrice(pricing.Discount),
+		CouponDiscount: db.CentsToPrice(pricing.CouponDiscount),
+		ShippingFee:    db.CentsToPrice(pricing.Shipping),
+		Items:          pricing.Items,
+	}
+}
@@ -0,0 +1,166 @@
+package order
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"szu_market/internal/db"
+	"szu_market/internal/dbtest"
+
+	"gorm.io/gorm"
+)
+
+// seedPricing 准备计价测试数据：
+//   - 卖家 1：商品 1 单价 30，有两个规格（规格 1 单价 40 上架，规格 2 下架）；商品 2 单价 50；
+//     运费 8，满 100 包邮；满 60 减 5、满 80 减 10 两个满减活动
+//   - 卖家 2：商品 3 单价 20，无运费设置；商品 4 已下架
+func seedPricing(t *testing.T) *gorm.DB {
+	t.Helper()
+	database := dbtest.Open(t, &db.SpecialProduct{}, &db.ProductSKU{}, &db.SellerShipping{},
+		&db.SellerPromotion{}, &db.CouponTemplate{}, &db.UserCoupon{})
+	now := time.Now()
+	mustCreate(t, database,
+		&db.SpecialProduct{ProductID: 1, ProductName: "A", Category: "书籍", Price: "30.00", UserID: 1, IsActive: true},
+		&db.SpecialProduct{ProductID: 2, ProductName: "B", Category: "书籍", Price: "50.00", UserID: 1, IsActive: true},
+		&db.SpecialProduct{ProductID: 3, ProductName: "C", Category: "数码", Price: "20.00", UserID: 2, IsActive: true},
+		&db.SpecialProduct{ProductID: 4, ProductName: "D", Category: "数码", Price: "20.00", UserID: 2, IsActive: true},
+		&db.ProductSKU{SKUID: 1, ProductID: 1, Price: "40.00", Stock: 10, IsActive: true},
+		&db.ProductSKU{SKUID: 2, ProductID: 1, Price: "35.00", Stock: 10, IsActive: true},
+		&db.SellerShipping{SellerID: 1, Fee: "8.00", FreeThreshold: "100.00"},
+		&db.SellerPromotion{SellerID: 1, Title: "满60减5", Threshold: "60.00", Reduction: "5.00", StartAt: now.Add(-time.Hour), EndAt: now.Add(time.Hour)},
+		&db.SellerPromotion{SellerID: 1, Title: "满80减10", Threshold: "80.00", Reduction: "10.00", StartAt: now.Add(-time.Hour), EndAt: now.Add(time.Hour)},
+		&db.CouponTemplate{TemplateID: 1, Name: "立减100", Type: db.CouponFixed, Amount: "100.00", Threshold: "0",
+			MaxDiscount: "0", ClaimStart: now, ClaimEnd: now.Add(time.Hour), UseStart: now, UseEnd: now.Add(time.Hour)},
+		&db.UserCoupon{CouponID: 1, TemplateID: 1, UserID: 9, Status: "unused", ValidFrom: now.Add(-time.Hour), ValidUntil: now.Add(time.Hour)},
+	)
+	// 零值字段不会写入，单独更新下架状态
+	if err := database.Model(&db.ProductSKU{}).Where("sku_id = ?", 2).Update("is_active", false).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := database.Model(&db.SpecialProduct{}).Where("product_id = ?", 4).Update("is_active", false).Error; err != nil {
+		t.Fatal(err)
+	}
+	return database
+}
+
+func mustCreate(t *testing.T, database *gorm.DB, values ...interface{}) {
+	t.Helper()
+	for _, v := range values {
+		if err := database.Create(v).Error; err != nil {
+			t.Fatalf("创建测试数据失败: %v", err)
+		}
+	}
+}
+
+func TestPriceOrder(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    CreateOrderInput
+		subtotal int64
+		discount int64
+		shipping int64
+		total    int64
+		wantErr  string
+	}{
+		{
+			name:     "无规格商品按商品价格计价，未设置运费的卖家不收运费",
+			input:    CreateOrderInput{ProductIDs: []uint{3}, ProductQuantities: []uint{2}},
+			subtotal: 4000, total: 4000,
+		},
+		{
+			name:     "规格按规格价格计价",
+			input:    CreateOrderInput{ProductIDs: []uint{1}, ProductQuantities: []uint{1}, SKUIDs: []uint{1}},
+			subtotal: 4000, shipping: 800, total: 4800,
+		},
+		{
+			name:     "满减取优惠最大的活动，满额包邮",
+			input:    CreateOrderInput{ProductIDs: []uint{1, 2}, ProductQuantities: []uint{1, 2}, SKUIDs: []uint{1, 0}},
+			subtotal: 14000, discount: 1000, total: 13000,
+		},
+		{
+			name:     "满减与运费按卖家分别计算，合计金额不参与门槛",
+			input:    CreateOrderInput{ProductIDs: []uint{2, 3}, ProductQuantities: []uint{1, 1}},
+			subtotal: 7000, shipping: 800, total: 7800,
+		},
+		{
+			name:    "有规格的商品必须选择规格",
+			input:   CreateOrderInput{ProductIDs: []uint{1}, ProductQuantities: []uint{1}},
+			wantErr: "请选择规格",
+		},
+		{
+			name:    "下架的规格不能购买",
+			input:   CreateOrderInput{ProductIDs: []uint{1}, ProductQuantities: []uint{1}, SKUIDs: []uint{2}},
+			wantErr: "规格已下架",
+		},
+		{
+			name:    "规格必须属于该商品",
+			input:   CreateOrderInput{ProductIDs: []uint{2}, ProductQuantities: []uint{1}, SKUIDs: []uint{1}},
+			wantErr: "规格不存在",
+		},
+		{
+			name:    "下架的商品不能购买",
+			input:   CreateOrderInput{ProductIDs: []uint{4}, ProductQuantities: []uint{1}},
+			wantErr: "已下架",
+		},
+		{
+			name:    "不存在的商品",
+			input:   CreateOrderInput{ProductIDs: []uint{99}, ProductQuantities: []uint{1}},
+			wantErr: "不存在",
+		},
+		{
+			name:    "数量必须大于 0",
+			input:   CreateOrderInput{ProductIDs: []uint{3}, ProductQuantities: []uint{0}},
+			wantErr: "数量",
+		},
+		{
+			name:     "优惠券超过商品金额时应付为 0，运费照收",
+			input:    CreateOrderInput{UserID: 9, ProductIDs: []uint{2}, ProductQuantities: []uint{1}, CouponID: 1},
+			subtotal: 5000, discount: 5000, shipping: 800, total: 800,
+		},
+	}
+
+	database := seedPricing(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 在事务中计价后回滚，各用例互不影响
+			tx := database.Begin()
+			defer tx.Rollback()
+
+			pricing, err := priceOrder(tx, &tt.input)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("priceOrder: %v", err)
+			}
+			if pricing.Subtotal != tt.subtotal || pricing.Discount != tt.discount ||
+				pricing.Shipping != tt.shipping || pricing.Total != tt.total {
+				t.Errorf("pricing = subtotal %d, discount %d, shipping %d, total %d; want %d, %d, %d, %d",
+					pricing.Subtotal, pricing.Discount, pricing.Shipping, pricing.Total,
+					tt.subtotal, tt.discount, tt.shipping, tt.total)
+			}
+			if len(pricing.Items) != len(tt.input.ProductIDs) {
+				t.Errorf("len(Items) = %d, want %d", len(pricing.Items), len(tt.input.ProductIDs))
+			}
+		})
+	}
+}
+
+func TestCheckExpectedTotal(t *testing.T) {
+	pricing := &OrderPricing{Subtotal: 1999, Total: 1999}
+	if err := checkExpectedTotal(19.99, pricing); err != nil {
+		t.Errorf("checkExpectedTotal(19.99) = %v, want nil", err)
+	}
+	err := checkExpectedTotal(19.98, pricing)
+	changed, ok := err.(*PriceChangedError)
+	if !ok {
+		t.Fatalf("checkExpectedTotal(19.98) = %v, want *PriceChangedError", err)
+	}
+	if changed.ActualTotal != "19.99" || changed.ExpectedTotal != "19.98" {
+		t.Errorf("PriceChangedError = %+v", changed)
+	}
+}
@@ -10,10 +10,15 @@ import (
 	"sync"
 	"time"
 
+	"szu_market/internal/cache"
+	"szu_market/internal/cart"
+	"szu_market/internal/coupon"
 	"szu_market/internal/db"
+	"szu_market/internal/limit"
 
 	"github.com/segmentio/kafka-go"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"gorm.io/gorm/logger"
 )
 
@@ -42,14 +47,19 @@ type CreateOrderInput struct {
 	AddressID         uint    `json:"address_id"`
 	ProductIDs        []uint  `json:"product_ids"`        // 一个产品ID的切片
 	ProductQuantities []uint  `json:"product_quantities"` // 对应的数量的切片
+	SKUIDs            []uint  `json:"sku_ids"`            // 对应的规格ID的切片（可选，0 表示无规格）
+	CouponID          uint    `json:"coupon_id"`          // 使用的用户优惠券（可选）
 }
 
 type OrderProductResponse struct {
-	ProductID   uint    `json:"product_id"`
-	ProductName string  `json:"product_name"`
-	Price       float32 `json:"product_price"`
-	ImageURL    string  `json:"image_url"`
-	Quantity    uint    `json:"quantity"`
+	ProductID     uint             `json:"product_id"`
+	SKUID         uint             `json:"sku_id"`
+	SKUAttributes db.SKUAttributes `json:"sku_attributes,omitempty"`
+	ProductName   string           `json:"product_name"`
+	Price         float32          `json:"product_price"`
+	ImageURL      string           `json:"image_url"`
+	Quantity      uint             `json:"quantity"`
+	IsDeleted     bool             `json:"is_deleted"` // 商品已被卖家删除，仅保留历史信息
 }
 
 // OrderResponse 创建订单响应
@@ -78,55 +88,174 @@ type AddressInput struct {
 // CreateOrder 创建新订单
 func (s *OrderService) CreateOrder(input *CreateOrderInput) (*OrderResponse, error) {
 	// 验证输入
-	if input.UserID == 0 {
-		return nil, errors.New("用户未登录")
-	}
-	if input.TotalPrice <= 0 {
-		return nil, errors.New("无效的订单总价")
+	if err := validateOrderInput(input); err != nil {
+		return nil, err
 	}
 	fmt.Println(input.ProductIDs)
 	fmt.Println(input.ProductQuantities)
+	s.fillDefaultAddress(input)
+
+	// 订单、订单商品与规格库存在同一事务中处理
+	var newOrder *db.Order
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		newOrder, err = placeOrder(tx, input)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.afterOrderPlaced(newOrder, input)
+	// 返回创建的订单响应
+	return &OrderResponse{
+		OrderID:    newOrder.OrderID,
+		TotalPrice: newOrder.TotalPrice,
+		AddressID:  newOrder.AddressID,
+	}, nil
+}
+
+// validateOrderInput 校验下单参数
+func validateOrderInput(input *CreateOrderInput) error {
+	if input.UserID == 0 {
+		return errors.New("用户未登录")
+	}
+	if input.TotalPrice < 0 {
+		return errors.New("无效的订单总价")
+	}
+	if len(input.ProductIDs) == 0 || len(input.ProductIDs) != len(input.ProductQuantities) {
+		return errors.New("商品与数量不匹配")
+	}
+	if len(input.SKUIDs) != 0 && len(input.SKUIDs) != len(input.ProductIDs) {
+		return errors.New("商品与规格不匹配")
+	}
+	return nil
+}
+
+// fillDefaultAddress 未指定收货地址时使用用户的默认地址
+func (s *OrderService) fillDefaultAddress(input *CreateOrderInput) {
+	if input.AddressID != 0 {
+		return
+	}
 	var address db.Address
-	if input.AddressID == 0 {
-		if err := s.DB.Where("user_id = ? AND is_default = ?", input.UserID, true).Find(&address).Error; err != nil {
-			fmt.Println("Error:", err)
-			fmt.Println("没有找到默认地址")
-		} else {
-			input.AddressID = address.AddressID
-		}
+	if err := s.DB.Where("user_id = ? AND is_default = ?", input.UserID, true).Find(&address).Error; err != nil {
+		fmt.Println("Error:", err)
+		fmt.Println("没有找到默认地址")
+	} else {
+		input.AddressID = address.AddressID
 	}
-	// 创建订单
-	newOrder := db.Order{
-		UserID:        input.UserID,
-		TotalPrice:    input.TotalPrice,
-		Status:        "待付款",
-		PaymentStatus: "未付款",
-		AddressID:     input.AddressID,
+}
+
+// placeOrder 在事务中按当前价格计价并创建订单、订单商品，扣减规格库存
+func placeOrder(tx *gorm.DB, input *CreateOrderInput) (*db.Order, error) {
+	pricing, err := priceOrder(tx, input)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkExpectedTotal(input.TotalPrice, pricing); err != nil {
+		return nil, err
+	}
+	if err := checkPurchaseLimits(tx, input.UserID, pricing.Items); err != nil {
+		return nil, err
 	}
 
-	// 执行创建订单
-	if err := s.DB.Create(&newOrder).Error; err != nil {
+	// 创建订单，总价使用服务端计算结果
+	newOrder := db.Order{
+		UserID:         input.UserID,
+		TotalPrice:     float64(pricing.Total) / 100,
+		Status:         "待付款",
+		PaymentStatus:  "未付款",
+		AddressID:      input.AddressID,
+		CouponID:       input.CouponID,
+		CouponDiscount: db.CentsToPrice(pricing.CouponDiscount),
+	}
+	if err := tx.Create(&newOrder).Error; err != nil {
 		return nil, fmt.Errorf("创建订单失败: %w", err)
 	}
+	if input.CouponID != 0 {
+		if err := coupon.MarkUsed(tx, input.UserID, input.CouponID, newOrder.OrderID); err != nil {
+			return nil, err
+		}
+	}
+
 	// 插入 order_products 表
-	for i, productID := range input.ProductIDs {
+	for _, item := range pricing.Items {
+		if item.SKUID != 0 {
+			if err := deductSKUStock(tx, item.ProductID, item.SKUID, item.Quantity); err != nil {
+				return nil, err
+			}
+		}
+
 		orderProduct := db.OrderProduct{
-			OrderID:   newOrder.OrderID,           // 订单 ID
-			ProductID: productID,                  // 产品 ID
-			Num:       input.ProductQuantities[i], // 产品数量
+			OrderID:   newOrder.OrderID, // 订单 ID
+			ProductID: item.ProductID,   // 产品 ID
+			SKUID:     item.SKUID,       // 规格 ID
+			Num:       item.Quantity,    // 产品数量
+			Price:     item.UnitPrice,   // 下单时的单价
 		}
 
-		if err := s.DB.Create(&orderProduct).Error; err != nil {
+		if err := tx.Create(&orderProduct).Error; err != nil {
 			return nil, fmt.Errorf("插入订单产品失败: %w", err)
 		}
 	}
-	go s.sendAsyncMessages(newOrder.OrderID, input.ProductIDs, input.ProductQuantities)
-	// 返回创建的订单响应
-	return &OrderResponse{
-		OrderID:    newOrder.OrderID,
-		TotalPrice: newOrder.TotalPrice,
-		AddressID:  newOrder.AddressID,
-	}, nil
+	return &newOrder, nil
+}
+
+// checkPurchaseLimits 按商品汇总订单中各规格的数量，校验是否超出限购
+func checkPurchaseLimits(tx *gorm.DB, userID uint, items []PricedItem) error {
+	quantities := make(map[uint]int64, len(items))
+	productIDs := make([]uint, 0, len(items))
+	for _, item := range items {
+		if _, ok := quantities[item.ProductID]; !ok {
+			productIDs = append(productIDs, item.ProductID)
+		}
+		quantities[item.ProductID] += int64(item.Quantity)
+	}
+
+	var limited []db.SpecialProduct
+	if err := tx.Where("product_id IN ? AND purchase_limit > 0", productIDs).
+		Order("product_id").Find(&limited).Error; err != nil {
+		return fmt.Errorf("查询商品失败: %w", err)
+	}
+	if len(limited) == 0 {
+		return nil
+	}
+	// 锁定买家后再统计已购数量，防止同一买家并发下单绕过限购
+	if err := limit.Lock(tx, userID); err != nil {
+		return err
+	}
+	for i := range limited {
+		if err := limit.Check(tx, userID, &limited[i], 0, quantities[limited[i].ProductID]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// afterOrderPlaced 订单提交后清除受影响的商品缓存并发送异步消息
+func (s *OrderService) afterOrderPlaced(order *db.Order, input *CreateOrderInput) {
+	// 规格库存已变化，清除相关商品缓存
+	if len(input.SKUIDs) > 0 {
+		cache.InvalidateProducts(input.ProductIDs...)
+	}
+	// 记录购物车提醒的转化，失败不影响下单
+	if err := cart.RecordReminderConversion(s.DB, input.UserID, order.OrderID); err != nil {
+		log.Printf("WARN: %v", err)
+	}
+	go s.sendAsyncMessages(order.OrderID, input.ProductIDs, input.ProductQuantities)
+}
+
+// deductSKUStock 扣减规格库存，库存不足时返回错误
+func deductSKUStock(tx *gorm.DB, productID, skuID, quantity uint) error {
+	result := tx.Model(&db.ProductSKU{}).
+		Where("sku_id = ? AND product_id = ? AND is_active = ? AND stock >= ?", skuID, productID, true, quantity).
+		UpdateColumn("stock", gorm.Expr("stock - ?", quantity))
+	if result.Error != nil {
+		return fmt.Errorf("扣减库存失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("规格 %d 库存不足或已下架", skuID)
+	}
+	return nil
 }
 
 // -------------  生产者 ------------------
@@ -243,22 +372,64 @@ func (s *OrderService) sendNoticeMessage(orderID uint) error {
 	return s.producer.SendMessage("noticeQueue", strconv.FormatUint(uint64(orderID), 10), msg)
 }
 
-// CancelOrder 取消订单
+// CancelOrder 取消订单，退回规格库存与优惠券；秒杀订单同时退回活动库存与抢购资格
 func (s *OrderService) CancelOrder(orderID uint) error {
-	// 查找订单
-	var order db.Order
-	if err := s.DB.First(&order, orderID).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return errors.New("订单不存在")
+	var skuProductIDs []uint
+	var flashRecord db.FlashSaleOrder
+	var flashSale db.FlashSale
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		// 锁定订单，避免并发取消重复退回库存
+		var order db.Order
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, orderID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("订单不存在")
+			}
+			return fmt.Errorf("查询订单失败: %w", err)
+		}
+		// 订单状态的枚举中没有"已取消"，取消只记录在支付状态上
+		if order.PaymentStatus == "已取消" {
+			return errors.New("订单已取消")
+		}
+		if order.Status != "待付款" || order.PaymentStatus != "未付款" {
+			return errors.New("只能取消待付款的订单")
 		}
-		return fmt.Errorf("查询订单失败: %w", err)
-	}
 
-	order.Status = "已取消"
-	if err := s.DB.Save(&order).Error; err != nil {
-		return fmt.Errorf("取消失败: %w", err)
-	}
+		if err := tx.Model(&order).Update("payment_status", "已取消").Error; err != nil {
+			return fmt.Errorf("取消失败: %w", err)
+		}
+
+		var items []db.OrderProduct
+		if err := tx.Where("order_id = ? AND sku_id <> 0", orderID).Find(&items).Error; err != nil {
+			return fmt.Errorf("查询订单商品失败: %w", err)
+		}
+		for _, item := range items {
+			if err := tx.Model(&db.ProductSKU{}).
+				Where("sku_id = ?", item.SKUID).
+				UpdateColumn("stock", gorm.Expr("stock + ?", item.Num)).Error; err != nil {
+				return fmt.Errorf("退回库存失败: %w", err)
+			}
+			skuProductIDs = append(skuProductIDs, item.ProductID)
+		}
 
+		if err := releaseFlashSaleOrder(tx, orderID, &flashRecord, &flashSale); err != nil {
+			return err
+		}
+
+		if order.CouponID != 0 {
+			return coupon.Release(tx, order.OrderID)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(skuProductIDs) > 0 {
+		cache.InvalidateProducts(skuProductIDs...)
+	}
+	// 活动结束后不再退回 Redis 中的库存，用户也无法再抢购
+	if flashRecord.RecordID != 0 && time.Now().Before(flashSale.EndAt) {
+		releaseFlashSale(flashRecord.FlashSaleID, flashRecord.UserID, FlashFailed+":订单已取消")
+	}
 	return nil
 }
 
@@ -352,24 +523,30 @@ func (s *AddressService) RemoveAddressItem(input *RemoveAddressItemInput) error
 // 加载订单
 func (s *OrderService) GetOrders(user_id uint) ([]OrderResponse, error) {
 	type rawResult struct {
-		OrderID     uint
-		Price       float32
-		CreatedAt   time.Time
-		Status      string
-		TotalPrice  float64
-		ProductID   uint
-		ProductName string
-		ImageURL    string
-		Quantity    uint
-		AddressId   uint
+		OrderID       uint
+		Price         float32
+		CreatedAt     time.Time
+		Status        string
+		TotalPrice    float64
+		ProductID     uint
+		SKUID         uint `gorm:"column:sku_id"`
+		SKUAttributes db.SKUAttributes
+		ProductName   string
+		ImageURL      string
+		Quantity      uint
+		AddressId     uint
+		IsDeleted     bool
 	}
 
 	var raws []rawResult
 
 	if err := s.DB.Table("orders AS o").
-		Select("o.order_id,o.created_at,o.status,o.total_price,o.status,op.product_id,op.num as quantity,sp.product_name,sp.image_url,sp.price,o.address_id").
+		Select("o.order_id,o.created_at,o.status,o.total_price,o.status,op.product_id,op.sku_id,op.num as quantity,sp.product_name,"+
+			"COALESCE(NULLIF(ps.image_url,''),sp.image_url) AS image_url,COALESCE(NULLIF(op.price,0),ps.price,sp.price) AS price,ps.attributes AS sku_attributes,o.address_id,"+
+			"(sp.product_id IS NULL OR sp.deleted_at IS NOT NULL) AS is_deleted").
 		Joins("JOIN order_products op ON op.order_id = o.order_id").
-		Joins("JOIN special_products sp ON sp.product_id = op.product_id").
+		Joins("LEFT JOIN special_products sp ON sp.product_id = op.product_id").
+		Joins("LEFT JOIN product_skus ps ON ps.sku_id = op.sku_id").
 		Where("o.user_id = ?", user_id).
 		Order("o.created_at DESC").
 		Scan(&raws).Error; err != nil {
@@ -393,11 +570,14 @@ func (s *OrderService) GetOrders(user_id uint) ([]OrderResponse, error) {
 			orderMap[row.OrderID] = order
 		}
 		order.Products = append(order.Products, OrderProductResponse{
-			ProductID:   row.ProductID,
-			ProductName: row.ProductName,
-			Price:       row.Price,
-			ImageURL:    row.ImageURL,
-			Quantity:    row.Quantity,
+			ProductID:     row.ProductID,
+			SKUID:         row.SKUID,
+			SKUAttributes: row.SKUAttributes,
+			ProductName:   row.ProductName,
+			Price:         row.Price,
+			ImageURL:      row.ImageURL,
+			Quantity:      row.Quantity,
+			IsDeleted:     row.IsDeleted,
 		})
 	}
 	var res []OrderResponse
@@ -0,0 +1,488 @@
+package product
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"szu_market/internal/db"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/xuri/excelize/v2"
+)
+
+const (
+	maxImportRows      = 5000           // 单次导入的最大行数
+	syncImportRowLimit = 200            // 超过该行数转为后台任务
+	importJobTTL       = 24 * time.Hour // 导入任务状态保留时间
+	importProgressStep = 50             // 后台任务每处理多少行更新一次进度
+)
+
+// 导入导出使用的列，product_id 为空表示新增，否则更新自己的已有商品
+// 规格（SKU）结构较复杂，不参与导入导出，需在商品编辑页单独维护
+var bulkColumns = []string{
+	"product_id", "category", "name", "description", "origin",
+	"price", "sales_period", "image_url", "is_active",
+	"purchase_limit", "limit_window_days",
+}
+
+// 表头别名，方便直接使用中文表头的表格
+var bulkColumnAliases = map[string]string{
+	"商品id": "product_id",
+	"分类":   "category",
+	"商品名称": "name",
+	"名称":   "name",
+	"描述":   "description",
+	"商品描述": "description",
+	"产地":   "origin",
+	"价格":   "price",
+	"销售期":  "sales_period",
+	"图片":   "image_url",
+	"是否上架": "is_active",
+	"限购数量": "purchase_limit",
+	"限购周期": "limit_window_days",
+}
+
+// ImportRowResult 单行导入结果
+type ImportRowResult struct {
+	Row       int    `json:"row"` // 表格中的行号（含表头，从 1 开始）
+	ProductID uint   `json:"product_id,omitempty"`
+	Action    string `json:"action"` // create 或 update
+	Success   bool   `json:"success"`
+	Error     string `json:"error,omitempty"`
+}
+
+// ImportReport 导入报告
+type ImportReport struct {
+	DryRun    bool              `json:"dry_run"`
+	Total     int               `json:"total"`
+	Succeeded int               `json:"succeeded"`
+	Failed    int               `json:"failed"`
+	Rows      []ImportRowResult `json:"rows"`
+}
+
+// ImportJob 后台导入任务状态
+type ImportJob struct {
+	JobID     string        `json:"job_id"`
+	UserID    uint          `json:"user_id"`
+	Status    string        `json:"status"` // pending、running、done、failed
+	Processed int           `json:"processed"`
+	Total     int           `json:"total"`
+	Error     string        `json:"error,omitempty"`
+	Report    *ImportReport `json:"report,omitempty"`
+	CreatedAt time.Time     `json:"created_at"`
+	UpdatedAt time.Time     `json:"updated_at"`
+}
+
+// bulkRow 解析后的数据行
+type bulkRow struct {
+	line   int
+	values map[string]string
+}
+
+func importJobKey(jobID string) string {
+	return "product:import:" + jobID
+}
+
+// parseImportFile 按扩展名解析 CSV 或 XLSX 文件，第一行为表头
+func parseImportFile(filename string, r io.Reader) ([]bulkRow, error) {
+	var records [][]string
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".csv":
+		reader := csv.NewReader(r)
+		reader.FieldsPerRecord = -1
+		reader.TrimLeadingSpace = true
+		rows, err := reader.ReadAll()
+		if err != nil {
+			return nil, fmt.Errorf("CSV 解析失败: %w", err)
+		}
+		records = rows
+	case ".xlsx":
+		f, err := excelize.OpenReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("XLSX 解析失败: %w", err)
+		}
+		defer f.Close()
+		rows, err := f.GetRows(f.GetSheetName(0))
+		if err != nil {
+			return nil, fmt.Errorf("XLSX 解析失败: %w", err)
+		}
+		records = rows
+	default:
+		return nil, errors.New("仅支持 CSV 或 XLSX 文件")
+	}
+
+	if len(records) == 0 {
+		return nil, errors.New("文件为空")
+	}
+
+	// 解析表头
+	header := make([]string, len(records[0]))
+	hasName := false
+	for i, h := range records[0] {
+		col := strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")))
+		if alias, ok := bulkColumnAliases[col]; ok {
+			col = alias
+		}
+		header[i] = col
+		if col == "name" {
+			hasName = true
+		}
+	}
+	if !hasName {
+		return nil, errors.New("表头缺少 name 列")
+	}
+
+	var rows []bulkRow
+	for i, record := range records[1:] {
+		values := make(map[string]string, len(header))
+		empty := true
+		for j, v := range record {
+			if j >= len(header) || header[j] == "" {
+				continue
+			}
+			v = strings.TrimSpace(v)
+			if v != "" {
+				empty = false
+			}
+			values[header[j]] = v
+		}
+		if empty {
+			continue // 跳过空行
+		}
+		rows = append(rows, bulkRow{line: i + 2, values: values})
+	}
+
+	if len(rows) == 0 {
+		return nil, errors.New("文件中没有数据行")
+	}
+	if len(rows) > maxImportRows {
+		return nil, fmt.Errorf("单次最多导入 %d 行", maxImportRows)
+	}
+	return rows, nil
+}
+
+// ImportProducts 导入商品：行数较少时同步返回报告，否则创建后台任务并返回任务
+func (s *ProductService) ImportProducts(userID uint, rows []bulkRow, dryRun bool) (*ImportReport, *ImportJob, error) {
+	if userID == 0 {
+		return nil, nil, errors.New("用户 ID 未提供")
+	}
+
+	if len(rows) <= syncImportRowLimit {
+		return s.runImport(userID, rows, dryRun, nil), nil, nil
+	}
+
+	job := &ImportJob{
+		JobID:     newJobID(),
+		UserID:    userID,
+		Status:    "pending",
+		Total:     len(rows),
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	if err := saveImportJob(job); err != nil {
+		return nil, nil, err
+	}
+	snapshot := *job
+
+	go func() {
+		// 导入过程中的 panic 不能拖垮整个服务，任务标记为失败
+		defer func() {
+			if r := recover(); r != nil {
+				log.Printf("导入任务异常 job:%s - %v", job.JobID, r)
+				job.Status = "failed"
+				job.Error = "导入过程中发生内部错误"
+				if err := saveImportJob(job); err != nil {
+					log.Printf("WARN: 保存导入任务状态失败 job:%s - %v", job.JobID, err)
+				}
+			}
+		}()
+
+		job.Status = "running"
+		if err := saveImportJob(job); err != nil {
+			log.Printf("WARN: 保存导入任务状态失败 job:%s - %v", job.JobID, err)
+		}
+
+		report := s.runImport(userID, rows, dryRun, func(processed int) {
+			job.Processed = processed
+			_ = saveImportJob(job)
+		})
+
+		job.Status = "done"
+		job.Processed = len(rows)
+		job.Report = report
+		if err := saveImportJob(job); err != nil {
+			// 完整报告保存失败（如报告过大），改为只保存失败状态，避免任务一直停留在 running
+			log.Printf("WARN: 保存导入任务结果失败 job:%s - %v", job.JobID, err)
+			job.Status = "failed"
+			job.Error = "保存导入结果失败，请重新导入"
+			job.Report = nil
+			if err := saveImportJob(job); err != nil {
+				log.Printf("WARN: 保存导入任务状态失败 job:%s - %v", job.JobID, err)
+			}
+		}
+	}()
+
+	return nil, &snapshot, nil
+}
+
+// runImport 逐行校验并导入，单行失败不影响其他行
+func (s *ProductService) runImport(userID uint, rows []bulkRow, dryRun bool, progress func(int)) *ImportReport {
+	report := &ImportReport{DryRun: dryRun, Total: len(rows), Rows: make([]ImportRowResult, 0, len(rows))}
+
+	for i, row := range rows {
+		result := s.importRow(userID, row, dryRun)
+		if result.Success {
+			report.Succeeded++
+		} else {
+			report.Failed++
+		}
+		report.Rows = append(report.Rows, result)
+
+		if progress != nil && (i+1)%importProgressStep == 0 {
+			progress(i + 1)
+		}
+	}
+	return report
+}
+
+// importRow 导入单行，新增走 AddProduct、更新走 UpdateProduct 的校验规则
+func (s *ProductService) importRow(userID uint, row bulkRow, dryRun bool) ImportRowResult {
+	result := ImportRowResult{Row: row.line, Action: "create"}
+	fail := func(err error) ImportRowResult {
+		result.Error = err.Error()
+		return result
+	}
+
+	var isActive *bool
+	if v := row.values["is_active"]; v != "" {
+		b, err := parseBool(v)
+		if err != nil {
+			return fail(errors.New("is_active 取值无效"))
+		}
+		isActive = &b
+	}
+	purchaseLimit, err := parseUintCell(row.values, "purchase_limit")
+	if err != nil {
+		return fail(err)
+	}
+	limitWindowDays, err := parseUintCell(row.values, "limit_window_days")
+	if err != nil {
+		return fail(err)
+	}
+
+	// 更新已有商品
+	if idStr := row.values["product_id"]; idStr != "" {
+		result.Action = "update"
+		id, err := strconv.ParseUint(idStr, 10, 32)
+		if err != nil || id == 0 {
+			return fail(errors.New("product_id 无效"))
+		}
+		result.ProductID = uint(id)
+
+		input := &UpdateProductInput{
+			UserID:          userID,
+			IsActive:        isActive,
+			PurchaseLimit:   purchaseLimit,
+			LimitWindowDays: limitWindowDays,
+		}
+		for col, field := range map[string]**string{
+			"category":     &input.Category,
+			"name":         &input.Name,
+			"description":  &input.Description,
+			"origin":       &input.Origin,
+			"price":        &input.Price,
+			"sales_period": &input.SalesPeriod,
+			"image_url":    &input.ImageURL,
+		} {
+			// 空单元格表示不修改该字段
+			if v := row.values[col]; v != "" {
+				*field = &v
+			}
+		}
+
+		if dryRun {
+			if err := s.checkOwner(userID, result.ProductID); err != nil {
+				return fail(err)
+			}
+			if _, err := buildProductUpdates(input); err != nil {
+				return fail(err)
+			}
+			if _, err := s.screenContent(updateProductTexts(input)...); err != nil {
+				return fail(err)
+			}
+		} else if _, err := s.UpdateProduct(result.ProductID, input); err != nil {
+			return fail(err)
+		}
+		result.Success = true
+		return result
+	}
+
+	// 新增商品，未填写是否上架时默认上架
+	if isActive == nil {
+		active := true
+		isActive = &active
+	}
+	input := &AddProductInput{
+		Category:    row.values["category"],
+		Name:        row.values["name"],
+		Description: row.values["description"],
+		Origin:      row.values["origin"],
+		Price:       row.values["price"],
+		SalesPeriod: row.values["sales_period"],
+		UserID:      userID,
+		ImageURL:    row.values["image_url"],
+		IsActive:    *isActive,
+	}
+	if purchaseLimit != nil {
+		input.PurchaseLimit = *purchaseLimit
+	}
+	if limitWindowDays != nil {
+		input.LimitWindowDays = *limitWindowDays
+	}
+	if dryRun {
+		if _, err := validateAddProductInput(input); err != nil {
+			return fail(err)
+		}
+		if _, err := s.screenContent(addProductTexts(input)...); err != nil {
+			return fail(err)
+		}
+	} else {
+		product, err := s.AddProduct(input)
+		if err != nil {
+			return fail(err)
+		}
+		result.ProductID = product.ProductID
+	}
+	result.Success = true
+	return result
+}
+
+// GetImportJob 查询后台导入任务，仅任务创建者可查看
+func (s *ProductService) GetImportJob(userID uint, jobID string) (*ImportJob, error) {
+	data, err := db.RDB.Get(context.Background(), importJobKey(jobID)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, errors.New("导入任务不存在或已过期")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询导入任务失败: %w", err)
+	}
+
+	var job ImportJob
+	if err := json.Unmarshal(data, &job); err != nil {
+		return nil, fmt.Errorf("导入任务数据损坏: %w", err)
+	}
+	if job.UserID != userID {
+		return nil, errors.New("导入任务不存在或已过期")
+	}
+	return &job, nil
+}
+
+// ExportProducts 导出卖家的商品，格式与导入一致，可修改后重新导入；规格不导出
+func (s *ProductService) ExportProducts(userID uint, format string) ([]byte, string, error) {
+	var products []db.SpecialProduct
+	if err := s.DB.Where("user_id = ?", userID).Order("product_id").Find(&products).Error; err != nil {
+		return nil, "", fmt.Errorf("查询失败: %w", err)
+	}
+
+	records := [][]string{bulkColumns}
+	for _, p := range products {
+		records = append(records, []string{
+			strconv.FormatUint(uint64(p.ProductID), 10),
+			p.Category,
+			p.ProductName,
+			p.ProductDescription,
+			p.Origin,
+			p.Price,
+			p.SalesPeriod,
+			p.ImageURL,
+			strconv.FormatBool(p.IsActive),
+			strconv.FormatUint(uint64(p.PurchaseLimit), 10),
+			strconv.FormatUint(uint64(p.LimitWindowDays), 10),
+		})
+	}
+
+	var buf bytes.Buffer
+	switch format {
+	case "", "csv":
+		buf.WriteString("\ufeff") // 写入 BOM，Excel 打开中文不乱码
+		w := csv.NewWriter(&buf)
+		if err := w.WriteAll(records); err != nil {
+			return nil, "", fmt.Errorf("导出失败: %w", err)
+		}
+		return buf.Bytes(), "text/csv; charset=utf-8", nil
+	case "xlsx":
+		f := excelize.NewFile()
+		defer f.Close()
+		sheet := f.GetSheetName(0)
+		for i, record := range records {
+			cell, _ := excelize.CoordinatesToCellName(1, i+1)
+			row := make([]interface{}, len(record))
+			for j, v := range record {
+				row[j] = v
+			}
+			if err := f.SetSheetRow(sheet, cell, &row); err != nil {
+				return nil, "", fmt.Errorf("导出失败: %w", err)
+			}
+		}
+		if err := f.Write(&buf); err != nil {
+			return nil, "", fmt.Errorf("导出失败: %w", err)
+		}
+		return buf.Bytes(), "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", nil
+	default:
+		return nil, "", errors.New("仅支持 csv 或 xlsx 格式")
+	}
+}
+
+func saveImportJob(job *ImportJob) error {
+	job.UpdatedAt = time.Now()
+	data, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("导入任务序列化失败: %w", err)
+	}
+	if err := db.RDB.Set(context.Background(), importJobKey(job.JobID), data, importJobTTL).Err(); err != nil {
+		return fmt.Errorf("保存导入任务失败: %w", err)
+	}
+	return nil
+}
+
+func newJobID() string {
+	b := make([]byte, 12)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// parseUintCell 解析非负整数列，空单元格返回 nil 表示未填写
+func parseUintCell(values map[string]string, col string) (*uint, error) {
+	v := values[col]
+	if v == "" {
+		return nil, nil
+	}
+	n, err := strconv.ParseUint(v, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("%s 取值无效", col)
+	}
+	u := uint(n)
+	return &u, nil
+}
+
+func parseBool(v string) (bool, error) {
+	switch strings.ToLower(v) {
+	case "1", "true", "yes", "y", "是", "上架":
+		return true, nil
+	case "0", "false", "no", "n", "否", "下架":
+		return false, nil
+	}
+	return false, errors.New("无效的布尔值")
+}
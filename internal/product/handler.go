@@ -1,7 +1,9 @@
 package product
 
 import (
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
 	"sort"
 	"strconv"
@@ -9,6 +11,7 @@ import (
 	"time"
 
 	"szu_market/internal/db"
+	"szu_market/internal/trending"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -16,12 +19,13 @@ import (
 
 // ProductHandler 商品处理程序
 type ProductHandler struct {
-	Service *ProductService
+	Service  *ProductService
+	Trending *trending.TrendingService
 }
 
 // NewProductHandler 创建新的商品处理程序
-func NewProductHandler(service *ProductService) *ProductHandler {
-	return &ProductHandler{Service: service}
+func NewProductHandler(service *ProductService, trendingService *trending.TrendingService) *ProductHandler {
+	return &ProductHandler{Service: service, Trending: trendingService}
 }
 
 // GetShouyeProducts 获取首页商品
@@ -79,7 +83,16 @@ func (h *ProductHandler) GetOwnProducts(c *gin.Context) {
 		return
 	}
 
-	products, err := h.Service.GetUserProducts(uint(userID))
+	products, err := h.Service.GetUserProducts(uint(userID), &OwnProductFilter{
+		Status:   c.Query("status"),
+		Category: c.Query("category"),
+		Keyword:  c.Query("keyword"),
+		Sort:     c.Query("sort"),
+	})
+	if errors.Is(err, ErrInvalidStatus) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		fmt.Println(err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -96,11 +109,17 @@ func (h *ProductHandler) GetOwnProducts(c *gin.Context) {
 			"description":  p.ProductDescription,
 			"origin":       p.Origin,
 			"price":        p.Price,
+			"min_price":    p.MinPrice,
+			"max_price":    p.MaxPrice,
+			"skus":         p.SKUs,
 			"sales_period": p.SalesPeriod,
 			"image_url":    p.ImageURL,
 			"is_active":    p.IsActive,
 			"publish_date": p.PublishDate.Format(time.RFC3339),
 			"is_violation": p.IsViolation,
+			"avg_rating":   p.AvgRating,
+			"review_count": p.ReviewCount,
+			"is_deleted":   p.DeletedAt.Valid,
 		})
 	}
 
@@ -113,6 +132,184 @@ func (h *ProductHandler) GetOwnProducts(c *gin.Context) {
 	c.JSON(http.StatusOK, productList)
 }
 
+// GetProductDetail 获取商品详情
+func (h *ProductHandler) GetProductDetail(c *gin.Context) {
+	productID, err := strconv.ParseUint(c.Param("product_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "商品 ID 无效"})
+		return
+	}
+
+	// 未登录时 user_id 可为空
+	var viewerID uint64
+	if userIDStr := c.Query("user_id"); userIDStr != "" {
+		viewerID, err = strconv.ParseUint(userIDStr, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "用户 ID 无效"})
+			return
+		}
+	}
+
+	detail, err := h.Service.GetProductDetail(uint(productID), uint(viewerID))
+	if errors.Is(err, ErrProductNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 记录浏览，失败不影响详情返回
+	// 已下架商品的详情仍可查看，但不计入浏览
+	if err := h.Trending.RecordView(uint(productID), trending.Viewer(c, uint(viewerID)), c.ClientIP()); err != nil && !errors.Is(err, trending.ErrProductNotFound) {
+		log.Printf("WARN: 记录浏览失败 product:%d - %v", productID, err)
+	}
+
+	c.JSON(http.StatusOK, detail)
+}
+
+// UpdateProduct 更新商品信息
+func (h *ProductHandler) UpdateProduct(c *gin.Context) {
+	productID, err := strconv.ParseUint(c.Param("product_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "商品 ID 无效"})
+		return
+	}
+
+	var input UpdateProductInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求数据无效"})
+		return
+	}
+
+	product, err := h.Service.UpdateProduct(uint(productID), &input)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "商品更新成功",
+		"product": product,
+	})
+}
+
+// ImportProducts 批量导入商品（CSV/XLSX），dry_run=true 时只校验不写入
+func (h *ProductHandler) ImportProducts(c *gin.Context) {
+	userID, err := strconv.ParseUint(c.PostForm("user_id"), 10, 32)
+	if err != nil || userID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "用户 ID 无效"})
+		return
+	}
+	dryRun, _ := strconv.ParseBool(c.DefaultPostForm("dry_run", "false"))
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "未上传文件"})
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "文件读取失败"})
+		return
+	}
+	defer file.Close()
+
+	rows, err := parseImportFile(fileHeader.Filename, file)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	report, job, err := h.Service.ImportProducts(uint(userID), rows, dryRun)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 大文件转为后台任务，前端通过 job_id 轮询进度
+	if job != nil {
+		c.JSON(http.StatusAccepted, gin.H{
+			"success": true,
+			"message": "导入任务已提交，正在后台处理",
+			"job":     job,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": report.Failed == 0,
+		"report":  report,
+	})
+}
+
+// GetImportJob 查询后台导入任务状态
+func (h *ProductHandler) GetImportJob(c *gin.Context) {
+	userID, err := strconv.ParseUint(c.Query("user_id"), 10, 32)
+	if err != nil || userID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "用户 ID 无效"})
+		return
+	}
+
+	job, err := h.Service.GetImportJob(uint(userID), c.Param("job_id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+// ExportProducts 导出卖家的商品（CSV/XLSX）
+func (h *ProductHandler) ExportProducts(c *gin.Context) {
+	userID, err := strconv.ParseUint(c.Query("user_id"), 10, 32)
+	if err != nil || userID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "用户 ID 无效"})
+		return
+	}
+	format := strings.ToLower(c.DefaultQuery("format", "csv"))
+
+	data, contentType, err := h.Service.ExportProducts(uint(userID), format)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	filename := fmt.Sprintf("products_%d_%s.%s", userID, time.Now().Format("20060102"), format)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Data(http.StatusOK, contentType, data)
+}
+
+// ModerateProduct 管理员标记或取消商品违规
+func (h *ProductHandler) ModerateProduct(c *gin.Context) {
+	productID, err := strconv.ParseUint(c.Param("product_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "商品 ID 无效"})
+		return
+	}
+
+	var input struct {
+		IsViolation bool `json:"is_violation"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求数据无效"})
+		return
+	}
+
+	err = h.Service.ModerateProduct(uint(productID), input.IsViolation)
+	if errors.Is(err, ErrProductNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "审核状态已更新"})
+}
+
 // RemoveProduct 删除商品
 func (h *ProductHandler) RemoveProduct(c *gin.Context) {
 	// 解析商品ID
@@ -168,7 +365,7 @@ func (h *ProductHandler) SearchProducts(c *gin.Context) {
 	whereClause := strings.Join(conditions, " OR ")
 
 	var products []db.SpecialProduct
-	err := h.Service.DB.Where(whereClause, args...).Find(&products).Error
+	err := h.Service.DB.Preload("SKUs").Where(whereClause, args...).Find(&products).Error
 
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -177,6 +374,7 @@ func (h *ProductHandler) SearchProducts(c *gin.Context) {
 		})
 		return
 	}
+	fillPriceRanges(products)
 
 	// 相关性排序 - 优先匹配名称和完整短语
 	sort.Slice(products, func(i, j int) bool {
@@ -204,6 +402,114 @@ func (h *ProductHandler) SearchProducts(c *gin.Context) {
 	c.JSON(http.StatusOK, products)
 }
 
+// GetProductSKUs 获取商品规格列表
+func (h *ProductHandler) GetProductSKUs(c *gin.Context) {
+	productID, err := strconv.ParseUint(c.Param("product_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "商品 ID 无效"})
+		return
+	}
+
+	skus, err := h.Service.GetProductSKUs(uint(productID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if skus == nil {
+		skus = []db.ProductSKU{}
+	}
+	c.JSON(http.StatusOK, skus)
+}
+
+// GetPriceHistory 获取商品价格变动记录，可通过 sku_id 只看某个规格
+func (h *ProductHandler) GetPriceHistory(c *gin.Context) {
+	productID, err := strconv.ParseUint(c.Param("product_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "商品 ID 无效"})
+		return
+	}
+
+	var skuID *uint
+	if skuIDStr := c.Query("sku_id"); skuIDStr != "" {
+		id, err := strconv.ParseUint(skuIDStr, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "规格 ID 无效"})
+			return
+		}
+		v := uint(id)
+		skuID = &v
+	}
+
+	history, err := h.Service.GetPriceHistory(uint(productID), skuID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, history)
+}
+
+// AddProductSKU 为商品添加规格
+func (h *ProductHandler) AddProductSKU(c *gin.Context) {
+	productID, err := strconv.ParseUint(c.Param("product_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "商品 ID 无效"})
+		return
+	}
+
+	var input struct {
+		UserID uint `json:"user_id"`
+		SKUInput
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求数据无效"})
+		return
+	}
+
+	sku, err := h.Service.AddProductSKU(input.UserID, uint(productID), &input.SKUInput)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"message": "规格添加成功",
+		"sku":     sku,
+	})
+}
+
+// UpdateProductSKU 更新商品规格
+func (h *ProductHandler) UpdateProductSKU(c *gin.Context) {
+	productID, err := strconv.ParseUint(c.Param("product_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "商品 ID 无效"})
+		return
+	}
+	skuID, err := strconv.ParseUint(c.Param("sku_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "规格 ID 无效"})
+		return
+	}
+
+	var input UpdateSKUInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求数据无效"})
+		return
+	}
+
+	sku, err := h.Service.UpdateProductSKU(uint(productID), uint(skuID), &input)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "规格更新成功",
+		"sku":     sku,
+	})
+}
+
 // 计算匹配词数量
 func countMatchingTerms(text string, terms []string) int {
 	count := 0
@@ -220,13 +526,23 @@ func countMatchingTerms(text string, terms []string) int {
 func RegisterProductRoutes(r *gin.Engine, db *gorm.DB) {
 	// 创建商品服务和处理程序
 	productService := NewProductService(db)
-	productHandler := NewProductHandler(productService)
+	productHandler := NewProductHandler(productService, trending.NewTrendingService(db))
 
 	// 注册商品路由
 	r.GET("/shouye", productHandler.GetShouyeProducts)
 	r.GET("/searchs", productHandler.SearchProducts)
 	r.GET("/admin/products", productHandler.GetAdminProducts)
+	r.PUT("/admin/products/:product_id/violation", productHandler.ModerateProduct)
 	r.POST("/addProduct", productHandler.AddProduct)
 	r.GET("/ownProducts", productHandler.GetOwnProducts)
 	r.DELETE("/removeProduct/:product_id", productHandler.RemoveProduct)
+	r.GET("/products/:product_id", productHandler.GetProductDetail)
+	r.PUT("/products/:product_id", productHandler.UpdateProduct)
+	r.POST("/products/import", productHandler.ImportProducts)
+	r.GET("/products/import/:job_id", productHandler.GetImportJob)
+	r.GET("/products/export", productHandler.ExportProducts)
+	r.GET("/products/:product_id/skus", productHandler.GetProductSKUs)
+	r.POST("/products/:product_id/skus", productHandler.AddProductSKU)
+	r.PUT("/products/:product_id/skus/:sku_id", productHandler.UpdateProductSKU)
+	r.GET("/products/:product_id/price_history", productHandler.GetPriceHistory)
 }
@@ -3,14 +3,25 @@ package product
 import (
 	"errors"
 	"fmt"
+	"log"
 	"path"
 	"time"
 
+	"szu_market/internal/cache"
 	"szu_market/internal/db"
+	"szu_market/internal/favorite"
+	"szu_market/internal/seller"
+	"szu_market/internal/sensitive"
 
 	"gorm.io/gorm"
 )
 
+const (
+	maxPriceHistory    = 200 // 单次返回的价格历史最大条数
+	detailQuestions    = 3   // 商品详情中展示的问答条数
+	maxLimitWindowDays = 365 // 限购统计周期上限（天）
+)
+
 // ProductService 定义商品服务接口
 type ProductService struct {
 	DB *gorm.DB
@@ -21,48 +32,108 @@ func NewProductService(db *gorm.DB) *ProductService {
 	return &ProductService{DB: db}
 }
 
-// GetActiveProducts 获取所有激活的商品
+// GetActiveProducts 获取所有激活的商品（首页），结果经 Redis 列表缓存
 func (s *ProductService) GetActiveProducts() ([]db.SpecialProduct, error) {
-	var products []db.SpecialProduct
-	if err := s.DB.Where("is_active = ?", true).Find(&products).Error; err != nil {
-		return nil, fmt.Errorf("查询失败: %w", err)
-	}
-	return products, nil
+	return cache.GetList("shouye", func() ([]db.SpecialProduct, error) {
+		var products []db.SpecialProduct
+		if err := s.DB.Preload("SKUs").Where("is_active = ?", true).Find(&products).Error; err != nil {
+			return nil, fmt.Errorf("查询失败: %w", err)
+		}
+		fillPriceRanges(products)
+		return products, nil
+	})
 }
 
 // GetAdminProducts 获取管理员可见的商品
 func (s *ProductService) GetAdminProducts() ([]db.SpecialProduct, error) {
 	var products []db.SpecialProduct
-	if err := s.DB.Find(&products).Error; err != nil {
+	if err := s.DB.Preload("SKUs").Find(&products).Error; err != nil {
 		return nil, fmt.Errorf("查询失败: %w", err)
 	}
+	fillPriceRanges(products)
 	return products, nil
 }
 
+// fillPriceRanges 为商品列表填充价格区间
+func fillPriceRanges(products []db.SpecialProduct) {
+	for i := range products {
+		products[i].FillPriceRange()
+	}
+}
+
 // AddProductInput 添加商品的输入参数
 type AddProductInput struct {
-	Category    string `json:"category"`
-	Name        string `json:"name"`
-	Description string `json:"description"`
-	Origin      string `json:"origin"`
-	Price       string `json:"price"`
-	SalesPeriod string `json:"sales_period"`
-	UserID      uint   `json:"user_id"`
-	ImageURL    string `json:"image_url"`
-	IsActive    bool   `json:"is_active"`
-	IsViolation bool   `json:"is_violation"`
+	Category    string     `json:"category"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Origin      string     `json:"origin"`
+	Price       string     `json:"price"`
+	SalesPeriod string     `json:"sales_period"`
+	UserID      uint       `json:"user_id"`
+	ImageURL    string     `json:"image_url"`
+	IsActive    bool       `json:"is_active"`
+	IsViolation bool       `json:"is_violation"`
+	SKUs        []SKUInput `json:"skus"`
+
+	PurchaseLimit   uint `json:"purchase_limit"`    // 每位买家限购件数，0 表示不限购
+	LimitWindowDays uint `json:"limit_window_days"` // 限购统计周期（天），0 表示不限周期
 }
 
-// AddProduct 添加新商品
-func (s *ProductService) AddProduct(input *AddProductInput) (*db.SpecialProduct, error) {
+// SKUInput 商品规格的输入参数
+type SKUInput struct {
+	SKUCode    string            `json:"sku_code"`
+	Attributes map[string]string `json:"attributes"`
+	Price      string            `json:"price"`
+	Stock      int               `json:"stock"`
+	ImageURL   string            `json:"image_url"`
+	IsActive   *bool             `json:"is_active"`
+}
+
+// validateAddProductInput 校验新增商品的输入并构建规格，批量导入的试运行也复用此校验
+func validateAddProductInput(input *AddProductInput) ([]db.ProductSKU, error) {
+	// 有规格时商品价格可省略，默认取最低规格价
+	skus, minCents, err := buildSKUs(input.SKUs)
+	if err != nil {
+		return nil, err
+	}
+	if input.Price == "" && len(skus) > 0 {
+		input.Price = db.CentsToPrice(minCents)
+	}
+
 	// 验证必填字段
 	if input.Category == "" || input.Name == "" || input.Price == "" || input.UserID == 0 || input.ImageURL == "" {
 		return nil, errors.New("缺少必需的字段")
 	}
+	if cents, err := db.PriceToCents(input.Price); err != nil || cents <= 0 {
+		return nil, errors.New("商品价格无效")
+	}
+	if err := validatePurchaseLimit(input.LimitWindowDays); err != nil {
+		return nil, err
+	}
+	return skus, nil
+}
+
+// validatePurchaseLimit 校验限购统计周期，周期只在设置了限购数量时生效
+func validatePurchaseLimit(windowDays uint) error {
+	if windowDays > maxLimitWindowDays {
+		return fmt.Errorf("限购周期不能超过 %d 天", maxLimitWindowDays)
+	}
+	return nil
+}
+
+// AddProduct 添加新商品
+func (s *ProductService) AddProduct(input *AddProductInput) (*db.SpecialProduct, error) {
+	skus, err := validateAddProductInput(input)
+	if err != nil {
+		return nil, err
+	}
+	flagged, err := s.screenContent(addProductTexts(input)...)
+	if err != nil {
+		return nil, err
+	}
 
 	// 处理图片路径
-	temp := path.Base(input.ImageURL)
-	finalImagePath := path.Join("goods_pic", temp)
+	finalImagePath := normalizeImagePath(input.ImageURL)
 
 	// 创建商品对象
 	newProduct := db.SpecialProduct{
@@ -75,27 +146,643 @@ func (s *ProductService) AddProduct(input *AddProductInput) (*db.SpecialProduct,
 		UserID:             input.UserID,
 		ImageURL:           finalImagePath,
 		IsActive:           input.IsActive,
-		IsViolation:        input.IsViolation,
+		IsViolation:        input.IsViolation || flagged,
 		PublishDate:        time.Now(),
+		PurchaseLimit:      input.PurchaseLimit,
+		LimitWindowDays:    input.LimitWindowDays,
 	}
 
-	// 保存到数据库
-	if err := s.DB.Create(&newProduct).Error; err != nil {
+	// 商品与规格在同一事务中保存
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&newProduct).Error; err != nil {
+			return err
+		}
+		for i := range skus {
+			skus[i].ProductID = newProduct.ProductID
+		}
+		if len(skus) > 0 {
+			if err := tx.Create(&skus).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
 		return nil, fmt.Errorf("商品添加失败: %w", err)
 	}
 
+	cache.Invalidate(newProduct.ProductID)
+
+	newProduct.SKUs = skus
+	newProduct.FillPriceRange()
 	return &newProduct, nil
 }
 
-// GetUserProducts 获取用户自己的商品
-func (s *ProductService) GetUserProducts(userID uint) ([]db.SpecialProduct, error) {
+// addProductTexts 新增商品时需要检查敏感内容的文本
+func addProductTexts(input *AddProductInput) []string {
+	texts := []string{input.Category, input.Name, input.Description, input.Origin, input.SalesPeriod}
+	for _, sku := range input.SKUs {
+		texts = append(texts, attributeTexts(sku.Attributes)...)
+	}
+	return texts
+}
+
+// updateProductTexts 更新商品时需要检查敏感内容的文本（仅检查本次修改的字段）
+func updateProductTexts(input *UpdateProductInput) []string {
+	var texts []string
+	for _, field := range []*string{input.Category, input.Name, input.Description, input.Origin, input.SalesPeriod} {
+		if field != nil {
+			texts = append(texts, *field)
+		}
+	}
+	return texts
+}
+
+// attributeTexts 规格属性的名称和取值
+func attributeTexts(attributes map[string]string) []string {
+	texts := make([]string, 0, len(attributes)*2)
+	for k, v := range attributes {
+		texts = append(texts, k, v)
+	}
+	return texts
+}
+
+// screenContent 用敏感词库检查商品文本：命中 reject 级别时返回错误拒绝发布，
+// 命中 flag 级别时返回 true，由调用方把商品标记为违规等待人工审核
+func (s *ProductService) screenContent(texts ...string) (bool, error) {
+	result, err := sensitive.Check(s.DB, texts...)
+	if err != nil {
+		return false, err
+	}
+	if result.Rejected() {
+		return false, fmt.Errorf("商品信息包含违禁内容：%s", result.Words())
+	}
+	return result.Flagged(), nil
+}
+
+// flagViolation 商品内容命中需要审核的敏感词，标记为违规
+func (s *ProductService) flagViolation(productID uint) error {
+	if err := s.DB.Model(&db.SpecialProduct{}).Where("product_id = ?", productID).
+		Update("is_violation", true).Error; err != nil {
+		return fmt.Errorf("标记违规失败: %w", err)
+	}
+	return nil
+}
+
+// normalizeImagePath 统一图片存储路径
+func normalizeImagePath(imageURL string) string {
+	if imageURL == "" {
+		return ""
+	}
+	return path.Join("goods_pic", path.Base(imageURL))
+}
+
+// buildSKUs 校验规格输入并构建规格模型，同时返回最低规格价（分）
+func buildSKUs(inputs []SKUInput) ([]db.ProductSKU, int64, error) {
+	var skus []db.ProductSKU
+	var minCents int64
+	for i, in := range inputs {
+		sku, cents, err := buildSKU(&in)
+		if err != nil {
+			return nil, 0, fmt.Errorf("第 %d 个规格无效: %w", i+1, err)
+		}
+		if i == 0 || cents < minCents {
+			minCents = cents
+		}
+		skus = append(skus, *sku)
+	}
+	return skus, minCents, nil
+}
+
+// buildSKU 校验单个规格输入
+func buildSKU(in *SKUInput) (*db.ProductSKU, int64, error) {
+	if len(in.Attributes) == 0 {
+		return nil, 0, errors.New("规格属性不能为空")
+	}
+	cents, err := db.PriceToCents(in.Price)
+	if err != nil || cents <= 0 {
+		return nil, 0, errors.New("规格价格无效")
+	}
+	if in.Stock < 0 {
+		return nil, 0, errors.New("规格库存不能为负数")
+	}
+	isActive := true
+	if in.IsActive != nil {
+		isActive = *in.IsActive
+	}
+	return &db.ProductSKU{
+		SKUCode:    in.SKUCode,
+		Attributes: db.SKUAttributes(in.Attributes),
+		Price:      db.CentsToPrice(cents),
+		Stock:      in.Stock,
+		ImageURL:   normalizeImagePath(in.ImageURL),
+		IsActive:   isActive,
+	}, cents, nil
+}
+
+// GetProductSKUs 获取商品的所有规格
+func (s *ProductService) GetProductSKUs(productID uint) ([]db.ProductSKU, error) {
+	var skus []db.ProductSKU
+	if err := s.DB.Where("product_id = ?", productID).Order("sku_id").Find(&skus).Error; err != nil {
+		return nil, fmt.Errorf("查询失败: %w", err)
+	}
+	return skus, nil
+}
+
+// AddProductSKU 为已有商品添加规格，仅商品发布者可操作
+func (s *ProductService) AddProductSKU(userID, productID uint, input *SKUInput) (*db.ProductSKU, error) {
+	if err := s.checkOwner(userID, productID); err != nil {
+		return nil, err
+	}
+
+	sku, _, err := buildSKU(input)
+	if err != nil {
+		return nil, err
+	}
+	sku.ProductID = productID
+	flagged, err := s.screenContent(attributeTexts(input.Attributes)...)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.DB.Create(sku).Error; err != nil {
+		return nil, fmt.Errorf("规格添加失败: %w", err)
+	}
+	if flagged {
+		if err := s.flagViolation(productID); err != nil {
+			return nil, err
+		}
+	}
+	cache.Invalidate(productID)
+	return sku, nil
+}
+
+// UpdateSKUInput 更新规格的输入参数，为空的字段不修改
+type UpdateSKUInput struct {
+	UserID     uint              `json:"user_id"`
+	Attributes map[string]string `json:"attributes"`
+	Price      *string           `json:"price"`
+	Stock      *int              `json:"stock"`
+	ImageURL   *string           `json:"image_url"`
+	IsActive   *bool             `json:"is_active"`
+}
+
+// UpdateProductSKU 更新商品规格，仅商品发布者可操作
+func (s *ProductService) UpdateProductSKU(productID, skuID uint, input *UpdateSKUInput) (*db.ProductSKU, error) {
+	if err := s.checkOwner(input.UserID, productID); err != nil {
+		return nil, err
+	}
+
+	var sku db.ProductSKU
+	if err := s.DB.Where("sku_id = ? AND product_id = ?", skuID, productID).First(&sku).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("规格不存在")
+		}
+		return nil, fmt.Errorf("数据库查询失败: %w", err)
+	}
+
+	updates := map[string]interface{}{}
+	var flagged bool
+	if len(input.Attributes) > 0 {
+		var err error
+		if flagged, err = s.screenContent(attributeTexts(input.Attributes)...); err != nil {
+			return nil, err
+		}
+		updates["attributes"] = db.SKUAttributes(input.Attributes)
+	}
+	if input.Price != nil {
+		cents, err := db.PriceToCents(*input.Price)
+		if err != nil || cents <= 0 {
+			return nil, errors.New("规格价格无效")
+		}
+		updates["price"] = db.CentsToPrice(cents)
+	}
+	if input.Stock != nil {
+		if *input.Stock < 0 {
+			return nil, errors.New("规格库存不能为负数")
+		}
+		updates["stock"] = *input.Stock
+	}
+	if input.ImageURL != nil {
+		updates["image_url"] = normalizeImagePath(*input.ImageURL)
+	}
+	if input.IsActive != nil {
+		updates["is_active"] = *input.IsActive
+	}
+	if len(updates) == 0 {
+		return &sku, nil
+	}
+
+	var dropped bool
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&sku).Updates(updates).Error; err != nil {
+			return fmt.Errorf("规格更新失败: %w", err)
+		}
+		if flagged {
+			if err := tx.Model(&db.SpecialProduct{}).Where("product_id = ?", productID).
+				Update("is_violation", true).Error; err != nil {
+				return fmt.Errorf("标记违规失败: %w", err)
+			}
+		}
+		if newPrice, ok := updates["price"].(string); ok {
+			var err error
+			dropped, err = recordPriceChange(tx, productID, sku.SKUID, sku.Price, newPrice)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	cache.Invalidate(productID)
+	if dropped {
+		go s.notifyPriceDrop(productID)
+	}
+	if err := s.DB.First(&sku, sku.SKUID).Error; err != nil {
+		return nil, fmt.Errorf("数据库查询失败: %w", err)
+	}
+	return &sku, nil
+}
+
+// checkOwner 校验商品是否属于该用户
+func (s *ProductService) checkOwner(userID, productID uint) error {
+	if userID == 0 || productID == 0 {
+		return errors.New("无效的用户或商品ID")
+	}
+	var count int64
+	if err := s.DB.Model(&db.SpecialProduct{}).
+		Where("product_id = ? AND user_id = ?", productID, userID).
+		Count(&count).Error; err != nil {
+		return fmt.Errorf("数据库查询失败: %w", err)
+	}
+	if count == 0 {
+		return errors.New("商品未找到或没有权限操作该商品")
+	}
+	return nil
+}
+
+// ErrInvalidStatus 商品状态筛选值无效
+var ErrInvalidStatus = errors.New("无效的商品状态")
+
+// OwnProductFilter 卖家查看自己商品时的筛选条件，零值表示不筛选
+type OwnProductFilter struct {
+	Status   string // all（默认，不含已删除）、active、inactive、violation、deleted
+	Category string
+	Keyword  string // 匹配商品名称或描述
+	Sort     string // newest（默认）、sales、price_asc、price_desc、rating
+}
+
+// GetUserProducts 获取用户自己的商品（店铺的卖家视图，包含下架、违规和已删除的商品）
+func (s *ProductService) GetUserProducts(userID uint, filter *OwnProductFilter) ([]db.SpecialProduct, error) {
+	query := s.DB.Preload("SKUs").Where("user_id = ?", userID)
+	switch filter.Status {
+	case "", "all":
+	case "active":
+		query = query.Where("is_active = ? AND is_violation = ?", true, false)
+	case "inactive":
+		query = query.Where("is_active = ? AND is_violation = ?", false, false)
+	case "violation":
+		query = query.Where("is_violation = ?", true)
+	case "deleted":
+		query = query.Unscoped().Where("deleted_at IS NOT NULL")
+	default:
+		return nil, ErrInvalidStatus
+	}
+	if filter.Category != "" {
+		query = query.Where("category = ?", filter.Category)
+	}
+	if filter.Keyword != "" {
+		like := "%" + filter.Keyword + "%"
+		query = query.Where("product_name LIKE ? OR product_description LIKE ?", like, like)
+	}
+
 	var products []db.SpecialProduct
-	if err := s.DB.Where("user_id = ?", userID).Find(&products).Error; err != nil {
+	if err := query.Order(ownProductsOrder(filter.Sort)).Find(&products).Error; err != nil {
 		return nil, fmt.Errorf("查询失败: %w", err)
 	}
+	fillPriceRanges(products)
 	return products, nil
 }
 
+// ownProductsOrder 卖家商品列表排序方式，未知值按最新发布排序
+func ownProductsOrder(sort string) string {
+	switch sort {
+	case "sales":
+		return "sales DESC, product_id DESC"
+	case "price_asc":
+		return "price ASC, product_id DESC"
+	case "price_desc":
+		return "price DESC, product_id DESC"
+	case "rating":
+		return "avg_rating DESC, product_id DESC"
+	default:
+		return "publish_date DESC, product_id DESC"
+	}
+}
+
+// UpdateProductInput 更新商品的输入参数，为空的字段不修改
+type UpdateProductInput struct {
+	UserID      uint    `json:"user_id"`
+	Category    *string `json:"category"`
+	Name        *string `json:"name"`
+	Description *string `json:"description"`
+	Origin      *string `json:"origin"`
+	Price       *string `json:"price"`
+	SalesPeriod *string `json:"sales_period"`
+	ImageURL    *string `json:"image_url"`
+	IsActive    *bool   `json:"is_active"`
+
+	PurchaseLimit   *uint `json:"purchase_limit"`
+	LimitWindowDays *uint `json:"limit_window_days"`
+}
+
+// buildProductUpdates 校验更新输入并生成需要更新的列
+func buildProductUpdates(input *UpdateProductInput) (map[string]interface{}, error) {
+	updates := map[string]interface{}{}
+	if input.Category != nil {
+		if *input.Category == "" {
+			return nil, errors.New("商品分类不能为空")
+		}
+		updates["category"] = *input.Category
+	}
+	if input.Name != nil {
+		if *input.Name == "" {
+			return nil, errors.New("商品名称不能为空")
+		}
+		updates["product_name"] = *input.Name
+	}
+	if input.Description != nil {
+		updates["product_description"] = *input.Description
+	}
+	if input.Origin != nil {
+		updates["origin"] = *input.Origin
+	}
+	if input.Price != nil {
+		cents, err := db.PriceToCents(*input.Price)
+		if err != nil || cents <= 0 {
+			return nil, errors.New("商品价格无效")
+		}
+		updates["price"] = db.CentsToPrice(cents)
+	}
+	if input.SalesPeriod != nil {
+		updates["sales_period"] = *input.SalesPeriod
+	}
+	if input.ImageURL != nil {
+		if *input.ImageURL == "" {
+			return nil, errors.New("商品图片不能为空")
+		}
+		updates["image_url"] = normalizeImagePath(*input.ImageURL)
+	}
+	if input.IsActive != nil {
+		updates["is_active"] = *input.IsActive
+	}
+	if input.PurchaseLimit != nil {
+		updates["purchase_limit"] = *input.PurchaseLimit
+	}
+	if input.LimitWindowDays != nil {
+		if err := validatePurchaseLimit(*input.LimitWindowDays); err != nil {
+			return nil, err
+		}
+		updates["limit_window_days"] = *input.LimitWindowDays
+	}
+	return updates, nil
+}
+
+// UpdateProduct 更新商品信息，仅商品发布者可操作
+func (s *ProductService) UpdateProduct(productID uint, input *UpdateProductInput) (*db.SpecialProduct, error) {
+	if err := s.checkOwner(input.UserID, productID); err != nil {
+		return nil, err
+	}
+
+	updates, err := buildProductUpdates(input)
+	if err != nil {
+		return nil, err
+	}
+	flagged, err := s.screenContent(updateProductTexts(input)...)
+	if err != nil {
+		return nil, err
+	}
+	if flagged {
+		updates["is_violation"] = true
+	}
+
+	var product db.SpecialProduct
+	if len(updates) > 0 {
+		var dropped bool
+		err := s.DB.Transaction(func(tx *gorm.DB) error {
+			var current db.SpecialProduct
+			if err := tx.Select("product_id", "price").First(&current, productID).Error; err != nil {
+				return fmt.Errorf("数据库查询失败: %w", err)
+			}
+			if err := tx.Model(&db.SpecialProduct{}).Where("product_id = ?", productID).Updates(updates).Error; err != nil {
+				return fmt.Errorf("商品更新失败: %w", err)
+			}
+			if newPrice, ok := updates["price"].(string); ok {
+				var err error
+				dropped, err = recordPriceChange(tx, productID, 0, current.Price, newPrice)
+				return err
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		cache.Invalidate(productID)
+		if dropped {
+			go s.notifyPriceDrop(productID)
+		}
+	}
+	if err := s.DB.Preload("SKUs").First(&product, productID).Error; err != nil {
+		return nil, fmt.Errorf("数据库查询失败: %w", err)
+	}
+	product.FillPriceRange()
+	return &product, nil
+}
+
+// recordPriceChange 价格有变化时写入价格历史，返回是否降价
+func recordPriceChange(tx *gorm.DB, productID, skuID uint, oldPrice, newPrice string) (bool, error) {
+	oldCents, err := db.PriceToCents(oldPrice)
+	if err != nil {
+		return false, fmt.Errorf("原价格无效: %w", err)
+	}
+	newCents, err := db.PriceToCents(newPrice)
+	if err != nil {
+		return false, fmt.Errorf("新价格无效: %w", err)
+	}
+	if oldCents == newCents {
+		return false, nil
+	}
+
+	history := db.PriceHistory{
+		ProductID: productID,
+		SKUID:     skuID,
+		OldPrice:  db.CentsToPrice(oldCents),
+		NewPrice:  db.CentsToPrice(newCents),
+	}
+	if err := tx.Create(&history).Error; err != nil {
+		return false, fmt.Errorf("记录价格变动失败: %w", err)
+	}
+	return newCents < oldCents, nil
+}
+
+// notifyPriceDrop 降价后通知收藏用户（后台执行，失败只记录日志）
+func (s *ProductService) notifyPriceDrop(productID uint) {
+	if err := favorite.NewFavoriteService(s.DB).NotifyPriceDrop(productID); err != nil {
+		log.Printf("WARN: 降价提醒发送失败: 商品ID %d, 错误: %v", productID, err)
+	}
+}
+
+// GetPriceHistory 获取商品价格变动记录（按时间倒序），skuID 为 nil 时返回商品及所有规格的记录
+func (s *ProductService) GetPriceHistory(productID uint, skuID *uint) ([]db.PriceHistory, error) {
+	query := s.DB.Where("product_id = ?", productID)
+	if skuID != nil {
+		query = query.Where("sku_id = ?", *skuID)
+	}
+
+	history := []db.PriceHistory{}
+	if err := query.Order("changed_at DESC, history_id DESC").Limit(maxPriceHistory).Find(&history).Error; err != nil {
+		return nil, fmt.Errorf("查询价格历史失败: %w", err)
+	}
+	return history, nil
+}
+
+// ErrProductNotFound 商品不存在、已删除或对当前用户不可见
+var ErrProductNotFound = errors.New("商品不存在")
+
+// RatingSummary 评分汇总，Distribution 为 1~5 星各自的评价数
+type RatingSummary struct {
+	AvgRating    float64       `json:"avg_rating"`
+	ReviewCount  uint          `json:"review_count"`
+	Distribution map[int]int64 `json:"distribution"`
+}
+
+// ProductDetail 商品详情响应结构
+type ProductDetail struct {
+	Product       db.SpecialProduct     `json:"product"`
+	Images        []string              `json:"images"`
+	Seller        *seller.SellerProfile `json:"seller"`
+	FavoriteCount int64                 `json:"favorite_count"`
+	Sales         uint                  `json:"sales"`
+	Rating        RatingSummary         `json:"rating"`
+	IsFavorited   bool                  `json:"is_favorited"`
+	InCart        bool                  `json:"in_cart"`
+	QuestionCount int64                 `json:"question_count"`
+	Questions     []db.ProductQuestion  `json:"questions"`
+}
+
+// GetProductDetail 获取商品详情，viewerID 为 0 表示未登录
+// 已删除、下架或违规的商品对卖家以外的用户返回 ErrProductNotFound
+func (s *ProductService) GetProductDetail(productID, viewerID uint) (*ProductDetail, error) {
+	cached, err := cache.GetProduct(s.DB, productID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrProductNotFound
+		}
+		return nil, err
+	}
+	product := *cached
+	if product.DeletedAt.Valid {
+		return nil, ErrProductNotFound
+	}
+	if (!product.IsActive || product.IsViolation) && product.UserID != viewerID {
+		return nil, ErrProductNotFound
+	}
+
+	detail := &ProductDetail{
+		Product:   product,
+		Images:    productImages(&product),
+		Sales:     product.Sales,
+		Questions: []db.ProductQuestion{},
+		Rating: RatingSummary{
+			AvgRating:    product.AvgRating,
+			ReviewCount:  product.ReviewCount,
+			Distribution: map[int]int64{1: 0, 2: 0, 3: 0, 4: 0, 5: 0},
+		},
+	}
+
+	profile, err := seller.NewSellerService(s.DB).GetProfile(product.UserID)
+	if errors.Is(err, seller.ErrSellerNotFound) {
+		profile, err = &seller.SellerProfile{UserID: product.UserID}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	detail.Seller = profile
+
+	if err := s.DB.Model(&db.Favorite{}).Where("product_id = ?", productID).
+		Count(&detail.FavoriteCount).Error; err != nil {
+		return nil, fmt.Errorf("统计收藏失败: %w", err)
+	}
+
+	var distribution []struct {
+		Rating int
+		Count  int64
+	}
+	if err := s.DB.Model(&db.Review{}).
+		Select("rating, COUNT(*) AS count").
+		Where("product_id = ? AND parent_id = 0", productID).
+		Group("rating").
+		Scan(&distribution).Error; err != nil {
+		return nil, fmt.Errorf("统计评分失败: %w", err)
+	}
+	for _, d := range distribution {
+		detail.Rating.Distribution[d.Rating] = d.Count
+	}
+
+	// 已回答且公开的问答，完整列表通过问答接口分页获取
+	answered := func() *gorm.DB {
+		return s.DB.Model(&db.ProductQuestion{}).
+			Where("product_id = ? AND is_hidden = ? AND answer_time IS NOT NULL", productID, false)
+	}
+	if err := answered().Count(&detail.QuestionCount).Error; err != nil {
+		return nil, fmt.Errorf("统计问答失败: %w", err)
+	}
+	if detail.QuestionCount > 0 {
+		if err := answered().Order("answer_time DESC").Limit(detailQuestions).
+			Find(&detail.Questions).Error; err != nil {
+			return nil, fmt.Errorf("查询问答失败: %w", err)
+		}
+	}
+
+	if viewerID != 0 {
+		var count int64
+		if err := s.DB.Model(&db.Favorite{}).
+			Where("user_id = ? AND product_id = ?", viewerID, productID).
+			Count(&count).Error; err != nil {
+			return nil, fmt.Errorf("数据库查询失败: %w", err)
+		}
+		detail.IsFavorited = count > 0
+
+		if err := s.DB.Model(&db.CartItem{}).
+			Where("user_id = ? AND product_id = ? AND status = ?", viewerID, productID, "in_cart").
+			Count(&count).Error; err != nil {
+			return nil, fmt.Errorf("数据库查询失败: %w", err)
+		}
+		detail.InCart = count > 0
+	}
+
+	return detail, nil
+}
+
+// productImages 汇总商品主图与各规格图片，去重并保持顺序
+func productImages(p *db.SpecialProduct) []string {
+	images := []string{}
+	seen := make(map[string]bool)
+	add := func(img string) {
+		if img != "" && !seen[img] {
+			seen[img] = true
+			images = append(images, img)
+		}
+	}
+	add(p.ImageURL)
+	for _, sku := range p.SKUs {
+		if sku.IsActive {
+			add(sku.ImageURL)
+		}
+	}
+	return images
+}
+
 // RemoveProductInput 删除商品的输入参数
 type RemoveProductInput struct {
 	UserID    uint `json:"user_id"`
@@ -113,10 +800,32 @@ func (s *ProductService) RemoveProduct(input *RemoveProductInput) error {
 		return fmt.Errorf("数据库查询失败: %w", err)
 	}
 
-	// 删除商品
+	// 软删除商品：写入删除时间，保留记录与规格供历史订单、购物车解析
 	if err := s.DB.Delete(&product).Error; err != nil {
 		return fmt.Errorf("删除商品失败: %w", err)
 	}
+	cache.Invalidate(product.ProductID)
 
 	return nil
 }
+
+// ModerateProduct 管理员审核商品，标记或取消违规
+func (s *ProductService) ModerateProduct(productID uint, isViolation bool) error {
+	result := s.DB.Model(&db.SpecialProduct{}).
+		Where("product_id = ?", productID).
+		Update("is_violation", isViolation)
+	if result.Error != nil {
+		return fmt.Errorf("审核商品失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		var count int64
+		if err := s.DB.Model(&db.SpecialProduct{}).Where("product_id = ?", productID).Count(&count).Error; err != nil {
+			return fmt.Errorf("数据库查询失败: %w", err)
+		}
+		if count == 0 {
+			return ErrProductNotFound
+		}
+	}
+	cache.Invalidate(productID)
+	return nil
+}
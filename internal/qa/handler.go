@@ -0,0 +1,166 @@
+package qa
+
+import (
+	"net/http"
+	"strconv"
+
+	"szu_market/internal/db"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultPageSize = 10
+	maxPageSize     = 50
+)
+
+// QAHandler 商品问答处理程序
+type QAHandler struct {
+	Service *QAService
+}
+
+// NewQAHandler 创建新的问答处理程序
+func NewQAHandler(service *QAService) *QAHandler {
+	return &QAHandler{Service: service}
+}
+
+// AskQuestion 对商品提问
+func (h *QAHandler) AskQuestion(c *gin.Context) {
+	productID, err := strconv.ParseUint(c.Param("product_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "商品ID无效"})
+		return
+	}
+
+	var input AskInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "请求数据无效"})
+		return
+	}
+
+	question, err := h.Service.AskQuestion(uint(productID), &input)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success":  true,
+		"message":  "提问成功",
+		"question": question,
+	})
+}
+
+// GetProductQuestions 分页获取商品问答
+func (h *QAHandler) GetProductQuestions(c *gin.Context) {
+	productID, err := strconv.ParseUint(c.Param("product_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "商品ID无效"})
+		return
+	}
+
+	// 未登录时 user_id 可为空
+	var viewerID uint64
+	if userIDStr := c.Query("user_id"); userIDStr != "" {
+		viewerID, err = strconv.ParseUint(userIDStr, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "用户ID无效"})
+			return
+		}
+	}
+
+	page, pageSize := db.ParsePage(c.Query("page"), c.Query("page_size"), defaultPageSize, maxPageSize)
+	questions, err := h.Service.GetProductQuestions(uint(productID), uint(viewerID), page, pageSize)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    questions,
+	})
+}
+
+// AnswerQuestion 卖家回答问题
+func (h *QAHandler) AnswerQuestion(c *gin.Context) {
+	questionID, err := strconv.ParseUint(c.Param("question_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "问题ID无效"})
+		return
+	}
+
+	var input AnswerInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "请求数据无效"})
+		return
+	}
+
+	question, err := h.Service.AnswerQuestion(uint(questionID), &input)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":  true,
+		"message":  "回答成功",
+		"question": question,
+	})
+}
+
+// SetVisibility 卖家隐藏或恢复问题
+func (h *QAHandler) SetVisibility(c *gin.Context) {
+	questionID, err := strconv.ParseUint(c.Param("question_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "问题ID无效"})
+		return
+	}
+
+	var input VisibilityInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "请求数据无效"})
+		return
+	}
+
+	if err := h.Service.SetVisibility(uint(questionID), &input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "问题状态已更新"})
+}
+
+// DeleteQuestion 删除问题
+func (h *QAHandler) DeleteQuestion(c *gin.Context) {
+	questionID, err := strconv.ParseUint(c.Param("question_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "问题ID无效"})
+		return
+	}
+	userID, err := strconv.ParseUint(c.Query("user_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "用户ID无效"})
+		return
+	}
+
+	if err := h.Service.DeleteQuestion(uint(questionID), uint(userID)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "问题已删除"})
+}
+
+// RegisterQARoutes 注册商品问答路由
+func RegisterQARoutes(r *gin.Engine, db *gorm.DB) {
+	qaService := NewQAService(db)
+	qaHandler := NewQAHandler(qaService)
+
+	r.POST("/products/:product_id/questions", qaHandler.AskQuestion)
+	r.GET("/products/:product_id/questions", qaHandler.GetProductQuestions)
+	r.POST("/questions/:question_id/answer", qaHandler.AnswerQuestion)
+	r.PUT("/questions/:question_id/visibility", qaHandler.SetVisibility)
+	r.DELETE("/questions/:question_id", qaHandler.DeleteQuestion)
+}
@@ -0,0 +1,336 @@
+package qa
+
+import (
+	"errors"
+	"fmt"
+	"time"
+	"unicode/utf8"
+
+	"szu_market/internal/db"
+	"szu_market/internal/notify"
+	"szu_market/internal/sensitive"
+
+	"gorm.io/gorm"
+)
+
+const (
+	maxQuestionLength = 200 // 提问最大字数
+	maxAnswerLength   = 500 // 回答最大字数
+)
+
+// QAService 定义商品问答服务
+type QAService struct {
+	DB *gorm.DB
+}
+
+// NewQAService 创建新的问答服务实例
+func NewQAService(db *gorm.DB) *QAService {
+	return &QAService{DB: db}
+}
+
+// AskInput 提问的输入参数
+type AskInput struct {
+	UserID  uint   `json:"user_id"`
+	Content string `json:"content"`
+}
+
+// AnswerInput 回答的输入参数
+type AnswerInput struct {
+	UserID  uint   `json:"user_id"`
+	Content string `json:"content"`
+}
+
+// VisibilityInput 卖家隐藏或恢复问题的输入参数
+type VisibilityInput struct {
+	UserID   uint `json:"user_id"`
+	IsHidden bool `json:"is_hidden"`
+}
+
+// QuestionResponse 问答响应结构
+type QuestionResponse struct {
+	db.ProductQuestion
+	Username string `json:"username"`
+}
+
+// QuestionPage 问答分页结果
+type QuestionPage struct {
+	Total    int64              `json:"total"`
+	Page     int                `json:"page"`
+	PageSize int                `json:"page_size"`
+	Items    []QuestionResponse `json:"items"`
+}
+
+// AskQuestion 对商品提问，登录用户均可提问，卖家不能向自己的商品提问
+func (s *QAService) AskQuestion(productID uint, input *AskInput) (*db.ProductQuestion, error) {
+	if input.UserID == 0 {
+		return nil, errors.New("用户未登录")
+	}
+	if err := validateText(input.Content, maxQuestionLength, "提问"); err != nil {
+		return nil, err
+	}
+
+	var user db.User
+	if err := s.DB.Select("user_id").First(&user, input.UserID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("用户不存在")
+		}
+		return nil, fmt.Errorf("数据库查询失败: %w", err)
+	}
+
+	product, err := s.visibleProduct(productID)
+	if err != nil {
+		return nil, err
+	}
+	if product.UserID == input.UserID {
+		return nil, errors.New("不能向自己的商品提问")
+	}
+
+	// 命中需审核的敏感词时先隐藏，由卖家决定是否公开
+	result, err := sensitive.Check(s.DB, input.Content)
+	if err != nil {
+		return nil, err
+	}
+	if result.Rejected() {
+		return nil, fmt.Errorf("提问包含违禁内容：%s", result.Words())
+	}
+
+	question := db.ProductQuestion{
+		ProductID: productID,
+		UserID:    input.UserID,
+		Content:   input.Content,
+		IsHidden:  result.Flagged(),
+	}
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&question).Error; err != nil {
+			return fmt.Errorf("提问失败: %w", err)
+		}
+		return notify.Send(tx, []db.Notification{{
+			UserID:    product.UserID,
+			Type:      notify.TypeNewQuestion,
+			Title:     "商品收到新提问",
+			Content:   fmt.Sprintf("有买家对「%s」提问：%s", product.ProductName, summary(input.Content)),
+			ProductID: productID,
+		}})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &question, nil
+}
+
+// AnswerQuestion 卖家回答问题，重复回答会覆盖之前的回答
+func (s *QAService) AnswerQuestion(questionID uint, input *AnswerInput) (*db.ProductQuestion, error) {
+	if input.UserID == 0 {
+		return nil, errors.New("用户未登录")
+	}
+	if err := validateText(input.Content, maxAnswerLength, "回答"); err != nil {
+		return nil, err
+	}
+
+	question, product, err := s.sellerQuestion(questionID, input.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 回答没有审核流程，命中任何级别的敏感内容都直接拒绝
+	result, err := sensitive.Check(s.DB, input.Content)
+	if err != nil {
+		return nil, err
+	}
+	if result.Severity != "" {
+		return nil, fmt.Errorf("回答包含违禁内容：%s", result.Words())
+	}
+
+	firstAnswer := question.Answer == ""
+	now := time.Now()
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(question).Updates(map[string]interface{}{
+			"answer":      input.Content,
+			"answer_time": now,
+		}).Error; err != nil {
+			return fmt.Errorf("回答失败: %w", err)
+		}
+		// 只在首次回答时通知提问者
+		if !firstAnswer {
+			return nil
+		}
+		return notify.Send(tx, []db.Notification{{
+			UserID:    question.UserID,
+			Type:      notify.TypeQuestionAnswered,
+			Title:     "您的提问已被回答",
+			Content:   fmt.Sprintf("卖家回答了您关于「%s」的提问：%s", product.ProductName, summary(input.Content)),
+			ProductID: product.ProductID,
+		}})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	question.Answer = input.Content
+	question.AnswerTime = &now
+	return question, nil
+}
+
+// SetVisibility 卖家隐藏或恢复问题
+func (s *QAService) SetVisibility(questionID uint, input *VisibilityInput) error {
+	question, _, err := s.sellerQuestion(questionID, input.UserID)
+	if err != nil {
+		return err
+	}
+	if err := s.DB.Model(question).Update("is_hidden", input.IsHidden).Error; err != nil {
+		return fmt.Errorf("更新问题状态失败: %w", err)
+	}
+	return nil
+}
+
+// DeleteQuestion 删除问题，卖家可删除任意问题，提问者只能删除尚未回答的问题
+func (s *QAService) DeleteQuestion(questionID, userID uint) error {
+	if userID == 0 {
+		return errors.New("用户未登录")
+	}
+
+	var question db.ProductQuestion
+	if err := s.DB.First(&question, questionID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("问题不存在")
+		}
+		return fmt.Errorf("数据库查询失败: %w", err)
+	}
+
+	var product db.SpecialProduct
+	if err := s.DB.Unscoped().Select("product_id", "user_id").First(&product, question.ProductID).Error; err != nil {
+		return fmt.Errorf("数据库查询失败: %w", err)
+	}
+
+	switch {
+	case product.UserID == userID:
+	case question.UserID == userID:
+		if question.Answer != "" {
+			return errors.New("问题已被回答，不能删除")
+		}
+	default:
+		return errors.New("无权删除该问题")
+	}
+
+	if err := s.DB.Delete(&question).Error; err != nil {
+		return fmt.Errorf("删除问题失败: %w", err)
+	}
+	return nil
+}
+
+// GetProductQuestions 分页获取商品问答，已回答的排在前面
+// 普通用户只能看到公开的问题和自己的提问，卖家可以看到全部问题
+func (s *QAService) GetProductQuestions(productID, viewerID uint, page, pageSize int) (*QuestionPage, error) {
+	res := &QuestionPage{Page: page, PageSize: pageSize, Items: []QuestionResponse{}}
+
+	var product db.SpecialProduct
+	if err := s.DB.Select("product_id", "user_id").First(&product, productID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("商品不存在")
+		}
+		return nil, fmt.Errorf("数据库查询失败: %w", err)
+	}
+	isSeller := viewerID != 0 && viewerID == product.UserID
+
+	query := func() *gorm.DB {
+		q := s.DB.Model(&db.ProductQuestion{}).Where("product_id = ?", productID)
+		if !isSeller {
+			q = q.Where("is_hidden = ? OR user_id = ?", false, viewerID)
+		}
+		return q
+	}
+	if err := query().Count(&res.Total).Error; err != nil {
+		return nil, fmt.Errorf("查询失败: %w", err)
+	}
+	if res.Total == 0 {
+		return res, nil
+	}
+
+	var questions []db.ProductQuestion
+	if err := query().
+		Order("answer_time IS NULL, answer_time DESC, created_at DESC").
+		Offset((page - 1) * pageSize).Limit(pageSize).
+		Find(&questions).Error; err != nil {
+		return nil, fmt.Errorf("查询失败: %w", err)
+	}
+
+	userIDs := make([]uint, 0, len(questions))
+	for _, q := range questions {
+		userIDs = append(userIDs, q.UserID)
+	}
+	usernames := make(map[uint]string)
+	var users []db.User
+	if err := s.DB.Select("user_id", "username").Where("user_id IN ?", userIDs).Find(&users).Error; err != nil {
+		return nil, fmt.Errorf("查询失败: %w", err)
+	}
+	for _, u := range users {
+		usernames[u.UserID] = u.Username
+	}
+
+	for _, q := range questions {
+		res.Items = append(res.Items, QuestionResponse{ProductQuestion: q, Username: usernames[q.UserID]})
+	}
+	return res, nil
+}
+
+// visibleProduct 查询可以提问的商品（未删除、在售且未违规）
+func (s *QAService) visibleProduct(productID uint) (*db.SpecialProduct, error) {
+	var product db.SpecialProduct
+	if err := s.DB.Select("product_id", "user_id", "product_name", "is_active", "is_violation").
+		First(&product, productID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("商品不存在")
+		}
+		return nil, fmt.Errorf("数据库查询失败: %w", err)
+	}
+	if !product.IsActive || product.IsViolation {
+		return nil, errors.New("商品已下架")
+	}
+	return &product, nil
+}
+
+// sellerQuestion 查询问题并校验操作者是该商品的卖家
+func (s *QAService) sellerQuestion(questionID, userID uint) (*db.ProductQuestion, *db.SpecialProduct, error) {
+	if userID == 0 {
+		return nil, nil, errors.New("用户未登录")
+	}
+
+	var question db.ProductQuestion
+	if err := s.DB.First(&question, questionID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, errors.New("问题不存在")
+		}
+		return nil, nil, fmt.Errorf("数据库查询失败: %w", err)
+	}
+
+	var product db.SpecialProduct
+	if err := s.DB.Unscoped().Select("product_id", "user_id", "product_name").
+		First(&product, question.ProductID).Error; err != nil {
+		return nil, nil, fmt.Errorf("数据库查询失败: %w", err)
+	}
+	if product.UserID != userID {
+		return nil, nil, errors.New("只有卖家可以管理问答")
+	}
+	return &question, &product, nil
+}
+
+// validateText 校验提问或回答的内容
+func validateText(content string, maxLength int, name string) error {
+	if content == "" {
+		return fmt.Errorf("%s内容不能为空", name)
+	}
+	if utf8.RuneCountInString(content) > maxLength {
+		return fmt.Errorf("%s内容不能超过 %d 字", name, maxLength)
+	}
+	return nil
+}
+
+// summary 截取通知中展示的内容摘要
+func summary(content string) string {
+	const maxRunes = 100
+	runes := []rune(content)
+	if len(runes) <= maxRunes {
+		return content
+	}
+	return string(runes[:maxRunes]) + "…"
+}
@@ -0,0 +1,67 @@
+package recommend
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// RecommendHandler 推荐处理程序
+type RecommendHandler struct {
+	Service *RecommendService
+}
+
+// NewRecommendHandler 创建新的推荐处理程序
+func NewRecommendHandler(service *RecommendService) *RecommendHandler {
+	return &RecommendHandler{Service: service}
+}
+
+// GetProductRecommendations 获取商品详情页推荐
+func (h *RecommendHandler) GetProductRecommendations(c *gin.Context) {
+	productID, err := strconv.ParseUint(c.Param("product_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "商品 ID 无效"})
+		return
+	}
+	limit, _ := strconv.Atoi(c.Query("limit"))
+
+	products, err := h.Service.GetProductRecommendations(uint(productID), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, products)
+}
+
+// GetCartRecommendations 获取购物车页推荐
+func (h *RecommendHandler) GetCartRecommendations(c *gin.Context) {
+	userIDStr := c.Query("user_id")
+	if userIDStr == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "用户未登录"})
+		return
+	}
+	userID, err := strconv.ParseUint(userIDStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "用户ID无效"})
+		return
+	}
+	limit, _ := strconv.Atoi(c.Query("limit"))
+
+	products, err := h.Service.GetCartRecommendations(uint(userID), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, products)
+}
+
+// RegisterRecommendRoutes 注册推荐路由
+func RegisterRecommendRoutes(r *gin.Engine, db *gorm.DB) {
+	recommendService := NewRecommendService(db)
+	recommendHandler := NewRecommendHandler(recommendService)
+
+	r.GET("/products/:product_id/recommendations", recommendHandler.GetProductRecommendations)
+	r.GET("/cart/recommendations", recommendHandler.GetCartRecommendations)
+}
@@ -0,0 +1,296 @@
+package recommend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"time"
+
+	"szu_market/internal/db"
+
+	"github.com/go-redis/redis/v8"
+	"gorm.io/gorm"
+)
+
+const (
+	recomputeInterval = time.Hour        // 推荐结果重算周期
+	recomputeLockTTL  = 30 * time.Minute // 重算锁过期时间，避免多实例重复计算
+	resultTTL         = 3 * time.Hour    // 推荐结果过期时间，调度停止后自然失效
+	maxPerProduct     = 30               // 每个商品保留的推荐数量
+
+	orderWeight    = 1.0 // 同一订单共同购买
+	favoriteWeight = 0.3 // 同一用户共同收藏，信号较弱
+
+	defaultLimit = 10
+	maxLimit     = 30
+)
+
+// RecommendService 定义"买了又买"推荐服务
+type RecommendService struct {
+	DB *gorm.DB
+}
+
+// NewRecommendService 创建新的推荐服务实例
+func NewRecommendService(db *gorm.DB) *RecommendService {
+	return &RecommendService{DB: db}
+}
+
+// pairWeight 商品对的共现次数
+type pairWeight struct {
+	SourceID uint
+	TargetID uint
+	Weight   float64
+}
+
+func recommendKey(productID uint) string {
+	return fmt.Sprintf("recommend:%d", productID)
+}
+
+// StartScheduler 启动定时重算推荐结果（后台运行）
+func (s *RecommendService) StartScheduler() {
+	ticker := time.NewTicker(recomputeInterval)
+	defer ticker.Stop()
+
+	for {
+		s.recomputeWithLock()
+		<-ticker.C
+	}
+}
+
+// recomputeWithLock 获取分布式锁后重算，未抢到锁说明其他实例正在计算
+func (s *RecommendService) recomputeWithLock() {
+	unlock, ok, err := db.TryLock(context.Background(), "recommend:recompute:lock", recomputeLockTTL)
+	if err != nil {
+		log.Printf("WARN: 获取推荐重算锁失败: %v", err)
+		return
+	}
+	if !ok {
+		return
+	}
+	defer unlock()
+
+	start := time.Now()
+	if err := s.Recompute(); err != nil {
+		log.Printf("推荐结果重算失败: %v", err)
+		return
+	}
+	log.Printf("推荐结果重算完成，耗时 %v", time.Since(start))
+}
+
+// Recompute 根据订单共同购买和共同收藏重新计算商品推荐
+func (s *RecommendService) Recompute() error {
+	ctx := context.Background()
+
+	// 共同购买：同一订单内的其他商品
+	var orderPairs []pairWeight
+	if err := s.DB.Table("order_products AS a").
+		Select("a.product_id AS source_id, b.product_id AS target_id, COUNT(DISTINCT a.order_id) AS weight").
+		Joins("JOIN order_products b ON b.order_id = a.order_id AND b.product_id <> a.product_id").
+		Joins("JOIN special_products sp ON sp.product_id = b.product_id").
+		Where("sp.is_active = ? AND sp.is_violation = ? AND sp.deleted_at IS NULL", true, false).
+		Group("a.product_id, b.product_id").
+		Scan(&orderPairs).Error; err != nil {
+		return fmt.Errorf("统计共同购买失败: %w", err)
+	}
+
+	// 共同收藏：同一用户收藏的其他商品
+	var favoritePairs []pairWeight
+	if err := s.DB.Table("favorite AS a").
+		Select("a.product_id AS source_id, b.product_id AS target_id, COUNT(DISTINCT a.user_id) AS weight").
+		Joins("JOIN favorite b ON b.user_id = a.user_id AND b.product_id <> a.product_id").
+		Joins("JOIN special_products sp ON sp.product_id = b.product_id").
+		Where("sp.is_active = ? AND sp.is_violation = ? AND sp.deleted_at IS NULL", true, false).
+		Group("a.product_id, b.product_id").
+		Scan(&favoritePairs).Error; err != nil {
+		return fmt.Errorf("统计共同收藏失败: %w", err)
+	}
+
+	scores := make(map[uint]map[uint]float64)
+	add := func(pairs []pairWeight, weight float64) {
+		for _, p := range pairs {
+			if scores[p.SourceID] == nil {
+				scores[p.SourceID] = make(map[uint]float64)
+			}
+			scores[p.SourceID][p.TargetID] += p.Weight * weight
+		}
+	}
+	add(orderPairs, orderWeight)
+	add(favoritePairs, favoriteWeight)
+
+	pipe := db.RDB.Pipeline()
+	for sourceID, targets := range scores {
+		members := make([]*redis.Z, 0, len(targets))
+		for targetID, score := range targets {
+			members = append(members, &redis.Z{Score: score, Member: targetID})
+		}
+		sort.Slice(members, func(i, j int) bool { return members[i].Score > members[j].Score })
+		if len(members) > maxPerProduct {
+			members = members[:maxPerProduct]
+		}
+
+		key := recommendKey(sourceID)
+		tmpKey := key + ":tmp"
+		pipe.Del(ctx, tmpKey)
+		pipe.ZAdd(ctx, tmpKey, members...)
+		pipe.Rename(ctx, tmpKey, key)
+		pipe.Expire(ctx, key, resultTTL)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("写入推荐结果失败: %w", err)
+	}
+	return nil
+}
+
+// GetProductRecommendations 获取商品详情页的"买了又买"推荐
+func (s *RecommendService) GetProductRecommendations(productID uint, limit int) ([]db.SpecialProduct, error) {
+	limit = normalizeLimit(limit)
+	ctx := context.Background()
+
+	members, err := db.RDB.ZRevRangeWithScores(ctx, recommendKey(productID), 0, maxPerProduct-1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("读取推荐结果失败: %w", err)
+	}
+
+	ids := make([]uint, 0, len(members))
+	for _, m := range members {
+		if id, ok := parseMember(m.Member); ok {
+			ids = append(ids, id)
+		}
+	}
+
+	products, err := s.loadAvailable(ids, map[uint]bool{productID: true}, limit)
+	if err != nil {
+		return nil, err
+	}
+	if len(products) > 0 {
+		return products, nil
+	}
+
+	// 还没有共现数据时，退化为同类目热销商品
+	return s.sameCategoryBestSellers(productID, limit)
+}
+
+// GetCartRecommendations 根据购物车中的商品汇总推荐，排除已在购物车中的商品
+func (s *RecommendService) GetCartRecommendations(userID uint, limit int) ([]db.SpecialProduct, error) {
+	limit = normalizeLimit(limit)
+	ctx := context.Background()
+
+	var cartProductIDs []uint
+	if err := s.DB.Model(&db.CartItem{}).
+		Where("user_id = ? AND status = ?", userID, "in_cart").
+		Distinct().Pluck("product_id", &cartProductIDs).Error; err != nil {
+		return nil, fmt.Errorf("查询购物车失败: %w", err)
+	}
+	if len(cartProductIDs) == 0 {
+		return []db.SpecialProduct{}, nil
+	}
+
+	exclude := make(map[uint]bool, len(cartProductIDs))
+	for _, id := range cartProductIDs {
+		exclude[id] = true
+	}
+
+	pipe := db.RDB.Pipeline()
+	cmds := make([]*redis.ZSliceCmd, 0, len(cartProductIDs))
+	for _, id := range cartProductIDs {
+		cmds = append(cmds, pipe.ZRevRangeWithScores(ctx, recommendKey(id), 0, maxPerProduct-1))
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("读取推荐结果失败: %w", err)
+	}
+
+	totals := make(map[uint]float64)
+	for _, cmd := range cmds {
+		for _, m := range cmd.Val() {
+			if id, ok := parseMember(m.Member); ok && !exclude[id] {
+				totals[id] += m.Score
+			}
+		}
+	}
+
+	ids := make([]uint, 0, len(totals))
+	for id := range totals {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return totals[ids[i]] > totals[ids[j]] })
+
+	return s.loadAvailable(ids, exclude, limit)
+}
+
+// loadAvailable 按给定顺序加载在售且未违规的商品
+func (s *RecommendService) loadAvailable(ids []uint, exclude map[uint]bool, limit int) ([]db.SpecialProduct, error) {
+	result := []db.SpecialProduct{}
+	if len(ids) == 0 {
+		return result, nil
+	}
+
+	var products []db.SpecialProduct
+	if err := s.DB.Preload("SKUs").
+		Where("product_id IN ? AND is_active = ? AND is_violation = ?", ids, true, false).
+		Find(&products).Error; err != nil {
+		return nil, fmt.Errorf("查询商品失败: %w", err)
+	}
+	productMap := make(map[uint]db.SpecialProduct, len(products))
+	for _, p := range products {
+		p.FillPriceRange()
+		productMap[p.ProductID] = p
+	}
+
+	for _, id := range ids {
+		if exclude[id] {
+			continue
+		}
+		if p, ok := productMap[id]; ok {
+			result = append(result, p)
+			if len(result) >= limit {
+				break
+			}
+		}
+	}
+	return result, nil
+}
+
+// sameCategoryBestSellers 同类目销量最高的在售商品
+func (s *RecommendService) sameCategoryBestSellers(productID uint, limit int) ([]db.SpecialProduct, error) {
+	var source db.SpecialProduct
+	if err := s.DB.Select("product_id", "category").First(&source, productID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return []db.SpecialProduct{}, nil
+		}
+		return nil, fmt.Errorf("查询商品失败: %w", err)
+	}
+
+	var products []db.SpecialProduct
+	if err := s.DB.Preload("SKUs").
+		Where("category = ? AND product_id <> ? AND is_active = ? AND is_violation = ?",
+			source.Category, productID, true, false).
+		Order("sales DESC").Limit(limit).
+		Find(&products).Error; err != nil {
+		return nil, fmt.Errorf("查询商品失败: %w", err)
+	}
+	for i := range products {
+		products[i].FillPriceRange()
+	}
+	return products, nil
+}
+
+func normalizeLimit(limit int) int {
+	if limit <= 0 {
+		return defaultLimit
+	}
+	if limit > maxLimit {
+		return maxLimit
+	}
+	return limit
+}
+
+func parseMember(member interface{}) (uint, bool) {
+	id, err := strconv.ParseUint(fmt.Sprint(member), 10, 32)
+	if err != nil {
+		return 0, false
+	}
+	return uint(id), true
+}
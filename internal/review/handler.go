@@ -0,0 +1,133 @@
+package review
+
+import (
+	"net/http"
+	"strconv"
+
+	"szu_market/internal/db"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultPageSize = 10
+	maxPageSize     = 50
+)
+
+// ReviewHandler 评价处理程序
+type ReviewHandler struct {
+	Service *ReviewService
+}
+
+// NewReviewHandler 创建新的评价处理程序
+func NewReviewHandler(service *ReviewService) *ReviewHandler {
+	return &ReviewHandler{Service: service}
+}
+
+// CreateReview 创建评价
+func (h *ReviewHandler) CreateReview(c *gin.Context) {
+	var input CreateReviewInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "请求数据无效"})
+		return
+	}
+
+	review, err := h.Service.CreateReview(&input)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"message": "评价成功",
+		"review":  review,
+	})
+}
+
+// AddFollowUp 追加评价
+func (h *ReviewHandler) AddFollowUp(c *gin.Context) {
+	reviewID, err := strconv.ParseUint(c.Param("review_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "评价ID无效"})
+		return
+	}
+
+	var input FollowUpInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "请求数据无效"})
+		return
+	}
+
+	review, err := h.Service.AddFollowUp(uint(reviewID), &input)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"message": "追评成功",
+		"review":  review,
+	})
+}
+
+// ReplyReview 卖家回复评价
+func (h *ReviewHandler) ReplyReview(c *gin.Context) {
+	reviewID, err := strconv.ParseUint(c.Param("review_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "评价ID无效"})
+		return
+	}
+
+	var input ReplyInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "请求数据无效"})
+		return
+	}
+
+	review, err := h.Service.ReplyReview(uint(reviewID), &input)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "回复成功",
+		"review":  review,
+	})
+}
+
+// GetProductReviews 分页获取商品评价
+func (h *ReviewHandler) GetProductReviews(c *gin.Context) {
+	productID, err := strconv.ParseUint(c.Param("product_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "商品ID无效"})
+		return
+	}
+
+	page, pageSize := db.ParsePage(c.Query("page"), c.Query("page_size"), defaultPageSize, maxPageSize)
+	reviews, err := h.Service.GetProductReviews(uint(productID), page, pageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    reviews,
+	})
+}
+
+// RegisterReviewRoutes 注册评价路由
+func RegisterReviewRoutes(r *gin.Engine, db *gorm.DB) {
+	reviewService := NewReviewService(db)
+	reviewHandler := NewReviewHandler(reviewService)
+
+	r.POST("/reviews", reviewHandler.CreateReview)
+	r.POST("/reviews/:review_id/followup", reviewHandler.AddFollowUp)
+	r.POST("/reviews/:review_id/reply", reviewHandler.ReplyReview)
+	r.GET("/products/:product_id/reviews", reviewHandler.GetProductReviews)
+}
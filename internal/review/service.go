@@ -0,0 +1,334 @@
+package review
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"time"
+	"unicode/utf8"
+
+	"szu_market/internal/cache"
+	"szu_market/internal/db"
+
+	"gorm.io/gorm"
+)
+
+const (
+	maxContentLength = 500 // 评价内容最大字数
+	maxImages        = 9   // 评价图片最大数量
+	orderReceived    = "已收货"
+)
+
+// ReviewService 定义评价服务
+type ReviewService struct {
+	DB *gorm.DB
+}
+
+// NewReviewService 创建新的评价服务实例
+func NewReviewService(db *gorm.DB) *ReviewService {
+	return &ReviewService{DB: db}
+}
+
+// CreateReviewInput 创建评价的输入参数
+type CreateReviewInput struct {
+	UserID    uint     `json:"user_id"`
+	OrderID   uint     `json:"order_id"`
+	ProductID uint     `json:"product_id"`
+	Rating    int      `json:"rating"`
+	Content   string   `json:"content"`
+	Images    []string `json:"images"`
+}
+
+// FollowUpInput 追评的输入参数
+type FollowUpInput struct {
+	UserID  uint     `json:"user_id"`
+	Content string   `json:"content"`
+	Images  []string `json:"images"`
+}
+
+// ReplyInput 卖家回复的输入参数
+type ReplyInput struct {
+	UserID  uint   `json:"user_id"`
+	Content string `json:"content"`
+}
+
+// ReviewResponse 评价响应结构
+type ReviewResponse struct {
+	db.Review
+	Username string     `json:"username"`
+	FollowUp *db.Review `json:"follow_up,omitempty"`
+}
+
+// ReviewPage 评价分页结果
+type ReviewPage struct {
+	Total    int64            `json:"total"`
+	Page     int              `json:"page"`
+	PageSize int              `json:"page_size"`
+	Items    []ReviewResponse `json:"items"`
+}
+
+// CreateReview 创建首次评价，仅已收货订单的购买者可评价
+func (s *ReviewService) CreateReview(input *CreateReviewInput) (*db.Review, error) {
+	if input.UserID == 0 || input.OrderID == 0 || input.ProductID == 0 {
+		return nil, errors.New("无效的用户、订单或商品ID")
+	}
+	if input.Rating < 1 || input.Rating > 5 {
+		return nil, errors.New("评分必须在 1 到 5 之间")
+	}
+	images, err := validateContent(input.Content, input.Images)
+	if err != nil {
+		return nil, err
+	}
+
+	// 校验购买记录
+	orderProduct, err := s.verifyPurchase(input.UserID, input.OrderID, input.ProductID)
+	if err != nil {
+		return nil, err
+	}
+
+	review := db.Review{
+		ProductID: input.ProductID,
+		OrderID:   input.OrderID,
+		UserID:    input.UserID,
+		SKUID:     orderProduct.SKUID,
+		Rating:    input.Rating,
+		Content:   input.Content,
+		Images:    images,
+	}
+
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&db.Review{}).
+			Where("order_id = ? AND product_id = ? AND parent_id = 0", input.OrderID, input.ProductID).
+			Count(&count).Error; err != nil {
+			return fmt.Errorf("数据库查询失败: %w", err)
+		}
+		if count > 0 {
+			return errors.New("该商品已评价过")
+		}
+		if err := tx.Create(&review).Error; err != nil {
+			return fmt.Errorf("评价失败: %w", err)
+		}
+		return refreshProductRating(tx, input.ProductID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	// 评分变化后清除商品缓存
+	cache.Invalidate(input.ProductID)
+	return &review, nil
+}
+
+// AddFollowUp 追加评价，每条评价只能追评一次
+func (s *ReviewService) AddFollowUp(reviewID uint, input *FollowUpInput) (*db.Review, error) {
+	if input.UserID == 0 {
+		return nil, errors.New("用户未登录")
+	}
+	if input.Content == "" && len(input.Images) == 0 {
+		return nil, errors.New("追评内容不能为空")
+	}
+	images, err := validateContent(input.Content, input.Images)
+	if err != nil {
+		return nil, err
+	}
+
+	var parent db.Review
+	if err := s.DB.Where("review_id = ? AND parent_id = 0", reviewID).First(&parent).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("评价不存在")
+		}
+		return nil, fmt.Errorf("数据库查询失败: %w", err)
+	}
+	if parent.UserID != input.UserID {
+		return nil, errors.New("只能追评自己的评价")
+	}
+
+	var count int64
+	if err := s.DB.Model(&db.Review{}).Where("parent_id = ?", parent.ReviewID).Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("数据库查询失败: %w", err)
+	}
+	if count > 0 {
+		return nil, errors.New("每条评价只能追评一次")
+	}
+
+	followUp := db.Review{
+		ProductID: parent.ProductID,
+		OrderID:   parent.OrderID,
+		ParentID:  parent.ReviewID,
+		UserID:    parent.UserID,
+		SKUID:     parent.SKUID,
+		Content:   input.Content,
+		Images:    images,
+	}
+	if err := s.DB.Create(&followUp).Error; err != nil {
+		return nil, fmt.Errorf("追评失败: %w", err)
+	}
+	return &followUp, nil
+}
+
+// ReplyReview 卖家回复评价（含追评），每条只能回复一次
+func (s *ReviewService) ReplyReview(reviewID uint, input *ReplyInput) (*db.Review, error) {
+	if input.UserID == 0 {
+		return nil, errors.New("用户未登录")
+	}
+	if input.Content == "" {
+		return nil, errors.New("回复内容不能为空")
+	}
+	if utf8.RuneCountInString(input.Content) > maxContentLength {
+		return nil, fmt.Errorf("回复内容不能超过 %d 字", maxContentLength)
+	}
+
+	var review db.Review
+	if err := s.DB.First(&review, reviewID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("评价不存在")
+		}
+		return nil, fmt.Errorf("数据库查询失败: %w", err)
+	}
+
+	var product db.SpecialProduct
+	if err := s.DB.Unscoped().Select("product_id", "user_id").First(&product, review.ProductID).Error; err != nil {
+		return nil, fmt.Errorf("数据库查询失败: %w", err)
+	}
+	if product.UserID != input.UserID {
+		return nil, errors.New("只有卖家可以回复评价")
+	}
+
+	now := time.Now()
+	result := s.DB.Model(&db.Review{}).
+		Where("review_id = ? AND (seller_reply IS NULL OR seller_reply = '')", reviewID).
+		Updates(map[string]interface{}{"seller_reply": input.Content, "reply_time": now})
+	if result.Error != nil {
+		return nil, fmt.Errorf("回复失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, errors.New("该评价已回复过")
+	}
+
+	review.SellerReply = input.Content
+	review.ReplyTime = &now
+	return &review, nil
+}
+
+// GetProductReviews 分页获取商品评价，追评附在对应评价下
+func (s *ReviewService) GetProductReviews(productID uint, page, pageSize int) (*ReviewPage, error) {
+	res := &ReviewPage{Page: page, PageSize: pageSize, Items: []ReviewResponse{}}
+
+	query := func() *gorm.DB {
+		return s.DB.Model(&db.Review{}).Where("product_id = ? AND parent_id = 0", productID)
+	}
+	if err := query().Count(&res.Total).Error; err != nil {
+		return nil, fmt.Errorf("查询失败: %w", err)
+	}
+	if res.Total == 0 {
+		return res, nil
+	}
+
+	var reviews []db.Review
+	if err := query().Order("created_at DESC").
+		Offset((page - 1) * pageSize).Limit(pageSize).
+		Find(&reviews).Error; err != nil {
+		return nil, fmt.Errorf("查询失败: %w", err)
+	}
+
+	var reviewIDs, userIDs []uint
+	for _, r := range reviews {
+		reviewIDs = append(reviewIDs, r.ReviewID)
+		userIDs = append(userIDs, r.UserID)
+	}
+
+	// 追评
+	followUps := make(map[uint]db.Review)
+	if len(reviewIDs) > 0 {
+		var rows []db.Review
+		if err := s.DB.Where("parent_id IN ?", reviewIDs).Find(&rows).Error; err != nil {
+			return nil, fmt.Errorf("查询失败: %w", err)
+		}
+		for _, r := range rows {
+			followUps[r.ParentID] = r
+		}
+	}
+
+	// 用户名
+	usernames := make(map[uint]string)
+	if len(userIDs) > 0 {
+		var users []db.User
+		if err := s.DB.Select("user_id", "username").Where("user_id IN ?", userIDs).Find(&users).Error; err != nil {
+			return nil, fmt.Errorf("查询失败: %w", err)
+		}
+		for _, u := range users {
+			usernames[u.UserID] = u.Username
+		}
+	}
+
+	for _, r := range reviews {
+		item := ReviewResponse{Review: r, Username: usernames[r.UserID]}
+		if f, ok := followUps[r.ReviewID]; ok {
+			item.FollowUp = &f
+		}
+		res.Items = append(res.Items, item)
+	}
+	return res, nil
+}
+
+// verifyPurchase 校验用户确实购买并收到了该商品
+func (s *ReviewService) verifyPurchase(userID, orderID, productID uint) (*db.OrderProduct, error) {
+	var order db.Order
+	if err := s.DB.Where("order_id = ? AND user_id = ?", orderID, userID).First(&order).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("订单不存在")
+		}
+		return nil, fmt.Errorf("数据库查询失败: %w", err)
+	}
+	if order.Status != orderReceived {
+		return nil, errors.New("订单确认收货后才能评价")
+	}
+
+	var orderProduct db.OrderProduct
+	if err := s.DB.Where("order_id = ? AND product_id = ?", orderID, productID).First(&orderProduct).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("订单中没有该商品")
+		}
+		return nil, fmt.Errorf("数据库查询失败: %w", err)
+	}
+	return &orderProduct, nil
+}
+
+// validateContent 校验评价文字与图片，返回规范化后的图片路径
+func validateContent(content string, images []string) (db.StringList, error) {
+	if utf8.RuneCountInString(content) > maxContentLength {
+		return nil, fmt.Errorf("评价内容不能超过 %d 字", maxContentLength)
+	}
+	if len(images) > maxImages {
+		return nil, fmt.Errorf("评价图片不能超过 %d 张", maxImages)
+	}
+	list := db.StringList{}
+	for _, img := range images {
+		if img == "" {
+			continue
+		}
+		list = append(list, path.Join("goods_pic", path.Base(img)))
+	}
+	return list, nil
+}
+
+// refreshProductRating 重新统计商品的平均评分与评价数（仅统计首次评价）
+func refreshProductRating(tx *gorm.DB, productID uint) error {
+	var stat struct {
+		Count int64
+		Avg   float64
+	}
+	if err := tx.Model(&db.Review{}).
+		Select("COUNT(*) AS count, COALESCE(AVG(rating), 0) AS avg").
+		Where("product_id = ? AND parent_id = 0", productID).
+		Scan(&stat).Error; err != nil {
+		return fmt.Errorf("统计评分失败: %w", err)
+	}
+
+	return tx.Model(&db.SpecialProduct{}).
+		Where("product_id = ?", productID).
+		UpdateColumns(map[string]interface{}{
+			"avg_rating":   stat.Avg,
+			"review_count": stat.Count,
+		}).Error
+}
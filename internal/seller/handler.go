@@ -0,0 +1,197 @@
+package seller
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"szu_market/internal/db"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// SellerHandler 卖家店铺处理程序
+type SellerHandler struct {
+	Service *SellerService
+}
+
+// NewSellerHandler 创建新的卖家处理程序
+func NewSellerHandler(service *SellerService) *SellerHandler {
+	return &SellerHandler{Service: service}
+}
+
+// GetStorefront 获取卖家公开店铺
+func (h *SellerHandler) GetStorefront(c *gin.Context) {
+	sellerID, err := strconv.ParseUint(c.Param("seller_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "卖家 ID 无效"})
+		return
+	}
+
+	page, pageSize := db.ParsePage(c.Query("page"), c.Query("page_size"), defaultPageSize, maxPageSize)
+	storefront, err := h.Service.GetStorefront(uint(sellerID), &StorefrontQuery{
+		Category: c.Query("category"),
+		Sort:     c.Query("sort"),
+		Page:     page,
+		PageSize: pageSize,
+	})
+	if errors.Is(err, ErrSellerNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, storefront)
+}
+
+// GetSummary 获取卖家自己的商品统计，仅卖家本人可查看
+func (h *SellerHandler) GetSummary(c *gin.Context) {
+	sellerID, err := strconv.ParseUint(c.Param("seller_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "卖家 ID 无效"})
+		return
+	}
+	userID, err := strconv.ParseUint(c.Query("user_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "用户 ID 无效"})
+		return
+	}
+	if userID != sellerID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "只能查看自己的店铺统计"})
+		return
+	}
+
+	summary, err := h.Service.GetSummary(uint(sellerID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, summary)
+}
+
+// SetShipping 设置店铺运费，仅卖家本人可操作
+func (h *SellerHandler) SetShipping(c *gin.Context) {
+	sellerID, err := strconv.ParseUint(c.Param("seller_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "卖家 ID 无效"})
+		return
+	}
+	var input ShippingInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求数据无效"})
+		return
+	}
+	if input.UserID != uint(sellerID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "只能设置自己店铺的运费"})
+		return
+	}
+
+	shipping, err := h.Service.SetShipping(uint(sellerID), &input)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, shipping)
+}
+
+// GetPromotions 获取店铺未结束的满减活动
+func (h *SellerHandler) GetPromotions(c *gin.Context) {
+	sellerID, err := strconv.ParseUint(c.Param("seller_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "卖家 ID 无效"})
+		return
+	}
+
+	promotions, err := h.Service.GetPromotions(uint(sellerID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, promotions)
+}
+
+// CreatePromotion 创建店铺满减活动，仅卖家本人可操作
+func (h *SellerHandler) CreatePromotion(c *gin.Context) {
+	sellerID, err := strconv.ParseUint(c.Param("seller_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "卖家 ID 无效"})
+		return
+	}
+	var input PromotionInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求数据无效"})
+		return
+	}
+	if input.UserID != uint(sellerID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "只能为自己的店铺创建活动"})
+		return
+	}
+
+	promotion, err := h.Service.CreatePromotion(uint(sellerID), &input)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, promotion)
+}
+
+// DeletePromotion 删除店铺满减活动，仅卖家本人可操作
+func (h *SellerHandler) DeletePromotion(c *gin.Context) {
+	sellerID, err := strconv.ParseUint(c.Param("seller_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "卖家 ID 无效"})
+		return
+	}
+	promotionID, err := strconv.ParseUint(c.Param("promotion_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "活动 ID 无效"})
+		return
+	}
+	userID, err := strconv.ParseUint(c.Query("user_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "用户 ID 无效"})
+		return
+	}
+	if userID != sellerID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "只能删除自己店铺的活动"})
+		return
+	}
+
+	err = h.Service.DeletePromotion(uint(sellerID), uint(promotionID))
+	if errors.Is(err, ErrPromotionNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "活动已删除"})
+}
+
+// RegisterSellerRoutes 注册卖家店铺路由
+func RegisterSellerRoutes(r *gin.Engine, db *gorm.DB) {
+	sellerService := NewSellerService(db)
+	sellerHandler := NewSellerHandler(sellerService)
+
+	r.GET("/sellers/:seller_id", sellerHandler.GetStorefront)
+	r.GET("/sellers/:seller_id/summary", sellerHandler.GetSummary)
+	r.PUT("/sellers/:seller_id/shipping", sellerHandler.SetShipping)
+	r.GET("/sellers/:seller_id/promotions", sellerHandler.GetPromotions)
+	r.POST("/sellers/:seller_id/promotions", sellerHandler.CreatePromotion)
+	r.DELETE("/sellers/:seller_id/promotions/:promotion_id", sellerHandler.DeletePromotion)
+}
@@ -0,0 +1,120 @@
+package seller
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"szu_market/internal/db"
+
+	"gorm.io/gorm/clause"
+)
+
+// ErrPromotionNotFound 满减活动不存在
+var ErrPromotionNotFound = errors.New("满减活动不存在")
+
+// ShippingInput 卖家运费设置
+type ShippingInput struct {
+	UserID        uint   `json:"user_id"`
+	Fee           string `json:"fee"`
+	FreeThreshold string `json:"free_threshold"` // 为空或 0 表示不包邮
+}
+
+// SetShipping 设置卖家的每单运费与包邮门槛
+func (s *SellerService) SetShipping(sellerID uint, input *ShippingInput) (*db.SellerShipping, error) {
+	fee, err := db.ParseAmount(input.Fee, true)
+	if err != nil {
+		return nil, errors.New("运费无效")
+	}
+	threshold, err := db.ParseAmount(input.FreeThreshold, true)
+	if err != nil {
+		return nil, errors.New("包邮门槛无效")
+	}
+
+	shipping := &db.SellerShipping{
+		SellerID:      sellerID,
+		Fee:           db.CentsToPrice(fee),
+		FreeThreshold: db.CentsToPrice(threshold),
+	}
+	if err := s.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "seller_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"fee", "free_threshold", "updated_at"}),
+	}).Create(shipping).Error; err != nil {
+		return nil, fmt.Errorf("保存运费设置失败: %w", err)
+	}
+	return shipping, nil
+}
+
+// PromotionInput 创建满减活动的输入，时间格式为 2006-01-02 15:04:05
+type PromotionInput struct {
+	UserID    uint   `json:"user_id"`
+	Title     string `json:"title"`
+	Threshold string `json:"threshold"`
+	Reduction string `json:"reduction"`
+	StartAt   string `json:"start_at"` // 为空表示立即开始
+	EndAt     string `json:"end_at"`
+}
+
+// CreatePromotion 创建满减活动，优惠金额必须小于门槛
+func (s *SellerService) CreatePromotion(sellerID uint, input *PromotionInput) (*db.SellerPromotion, error) {
+	if input.Title == "" {
+		return nil, errors.New("活动名称不能为空")
+	}
+	threshold, err := db.ParseAmount(input.Threshold, false)
+	if err != nil {
+		return nil, errors.New("满减门槛无效")
+	}
+	reduction, err := db.ParseAmount(input.Reduction, false)
+	if err != nil || reduction >= threshold {
+		return nil, errors.New("优惠金额无效")
+	}
+
+	startAt := time.Now()
+	if input.StartAt != "" {
+		if startAt, err = time.ParseInLocation(time.DateTime, input.StartAt, time.Local); err != nil {
+			return nil, errors.New("开始时间格式无效")
+		}
+	}
+	endAt, err := time.ParseInLocation(time.DateTime, input.EndAt, time.Local)
+	if err != nil {
+		return nil, errors.New("结束时间格式无效")
+	}
+	if !endAt.After(startAt) {
+		return nil, errors.New("结束时间必须晚于开始时间")
+	}
+
+	promotion := &db.SellerPromotion{
+		SellerID:  sellerID,
+		Title:     input.Title,
+		Threshold: db.CentsToPrice(threshold),
+		Reduction: db.CentsToPrice(reduction),
+		StartAt:   startAt,
+		EndAt:     endAt,
+	}
+	if err := s.DB.Create(promotion).Error; err != nil {
+		return nil, fmt.Errorf("创建满减活动失败: %w", err)
+	}
+	return promotion, nil
+}
+
+// GetPromotions 获取卖家未结束的满减活动，按门槛从低到高排列
+func (s *SellerService) GetPromotions(sellerID uint) ([]db.SellerPromotion, error) {
+	promotions := []db.SellerPromotion{}
+	if err := s.DB.Where("seller_id = ? AND end_at > ?", sellerID, time.Now()).
+		Order("threshold, promotion_id").Find(&promotions).Error; err != nil {
+		return nil, fmt.Errorf("查询满减活动失败: %w", err)
+	}
+	return promotions, nil
+}
+
+// DeletePromotion 删除卖家的满减活动
+func (s *SellerService) DeletePromotion(sellerID, promotionID uint) error {
+	result := s.DB.Where("promotion_id = ? AND seller_id = ?", promotionID, sellerID).Delete(&db.SellerPromotion{})
+	if result.Error != nil {
+		return fmt.Errorf("删除满减活动失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrPromotionNotFound
+	}
+	return nil
+}
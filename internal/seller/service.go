@@ -0,0 +1,202 @@
+package seller
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"szu_market/internal/db"
+
+	"gorm.io/gorm"
+)
+
+// ErrSellerNotFound 卖家不存在
+var ErrSellerNotFound = errors.New("卖家不存在")
+
+// SellerService 定义卖家店铺服务
+type SellerService struct {
+	DB *gorm.DB
+}
+
+// NewSellerService 创建新的卖家服务实例
+func NewSellerService(db *gorm.DB) *SellerService {
+	return &SellerService{DB: db}
+}
+
+// SellerProfile 卖家公开信息
+type SellerProfile struct {
+	UserID           uint    `json:"user_id"`
+	Username         string  `json:"username"`
+	RegistrationDate string  `json:"registration_date"`
+	ActiveProducts   int64   `json:"active_products"`
+	TotalSales       uint    `json:"total_sales"`
+	AvgRating        float64 `json:"avg_rating"`
+	ReviewCount      int64   `json:"review_count"`
+	ResponseRate     float64 `json:"response_rate"` // 卖家已回复的评价占比，0~1
+}
+
+// StorefrontQuery 店铺商品列表查询条件
+type StorefrontQuery struct {
+	Category string
+	Sort     string // newest（默认）、sales、price_asc、price_desc
+	Page     int
+	PageSize int
+}
+
+// Storefront 卖家店铺响应结构
+type Storefront struct {
+	Seller   *SellerProfile      `json:"seller"`
+	Total    int64               `json:"total"`
+	Page     int                 `json:"page"`
+	PageSize int                 `json:"page_size"`
+	Products []db.SpecialProduct `json:"products"`
+}
+
+// SellerSummary 卖家私有的商品统计
+type SellerSummary struct {
+	Total            int64 `json:"total"`             // 未删除的商品总数
+	Active           int64 `json:"active"`            // 在售
+	Inactive         int64 `json:"inactive"`          // 已下架
+	Violation        int64 `json:"violation"`         // 违规
+	Deleted          int64 `json:"deleted"`           // 已删除
+	SoldOutSKUs      int64 `json:"sold_out_skus"`     // 在售商品中库存为 0 的规格数
+	TotalSales       uint  `json:"total_sales"`       // 累计销量
+	UnrepliedReviews int64 `json:"unreplied_reviews"` // 待回复的评价数
+}
+
+// GetProfile 获取卖家公开信息及统计数据
+func (s *SellerService) GetProfile(sellerID uint) (*SellerProfile, error) {
+	var user db.User
+	if err := s.DB.Select("user_id", "username", "registration_date").First(&user, sellerID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSellerNotFound
+		}
+		return nil, fmt.Errorf("查询卖家信息失败: %w", err)
+	}
+
+	profile := &SellerProfile{
+		UserID:           user.UserID,
+		Username:         user.Username,
+		RegistrationDate: user.RegistrationDate.Format("2006-01-02 15:04:05"),
+	}
+
+	if err := s.DB.Model(&db.SpecialProduct{}).
+		Where("user_id = ? AND is_active = ? AND is_violation = ?", sellerID, true, false).
+		Count(&profile.ActiveProducts).Error; err != nil {
+		return nil, fmt.Errorf("查询卖家信息失败: %w", err)
+	}
+
+	// 累计销量包含已删除的商品
+	if err := s.DB.Unscoped().Model(&db.SpecialProduct{}).
+		Select("COALESCE(SUM(sales), 0)").
+		Where("user_id = ?", sellerID).
+		Scan(&profile.TotalSales).Error; err != nil {
+		return nil, fmt.Errorf("查询卖家信息失败: %w", err)
+	}
+
+	// 评分按首次评价统计，回复率按所有评价（含追评）统计
+	var stat struct {
+		ReviewCount  int64
+		AvgRating    float64
+		AllCount     int64
+		RepliedCount int64
+	}
+	if err := s.DB.Table("reviews AS r").
+		Select(`COALESCE(SUM(r.parent_id = 0), 0) AS review_count,
+			COALESCE(AVG(CASE WHEN r.parent_id = 0 THEN r.rating END), 0) AS avg_rating,
+			COUNT(*) AS all_count,
+			COALESCE(SUM(r.seller_reply IS NOT NULL AND r.seller_reply <> ''), 0) AS replied_count`).
+		Joins("JOIN special_products sp ON sp.product_id = r.product_id").
+		Where("sp.user_id = ?", sellerID).
+		Scan(&stat).Error; err != nil {
+		return nil, fmt.Errorf("统计卖家评价失败: %w", err)
+	}
+	profile.ReviewCount = stat.ReviewCount
+	profile.AvgRating = math.Round(stat.AvgRating*100) / 100
+	if stat.AllCount > 0 {
+		profile.ResponseRate = math.Round(float64(stat.RepliedCount)/float64(stat.AllCount)*100) / 100
+	}
+	return profile, nil
+}
+
+// GetStorefront 获取卖家店铺：公开信息与分页的在售商品
+func (s *SellerService) GetStorefront(sellerID uint, q *StorefrontQuery) (*Storefront, error) {
+	profile, err := s.GetProfile(sellerID)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &Storefront{Seller: profile, Page: q.Page, PageSize: q.PageSize, Products: []db.SpecialProduct{}}
+
+	query := func() *gorm.DB {
+		tx := s.DB.Model(&db.SpecialProduct{}).
+			Where("user_id = ? AND is_active = ? AND is_violation = ?", sellerID, true, false)
+		if q.Category != "" {
+			tx = tx.Where("category = ?", q.Category)
+		}
+		return tx
+	}
+	if err := query().Count(&res.Total).Error; err != nil {
+		return nil, fmt.Errorf("查询店铺商品失败: %w", err)
+	}
+	if res.Total == 0 {
+		return res, nil
+	}
+
+	if err := query().Preload("SKUs").
+		Order(storefrontOrder(q.Sort)).
+		Offset((q.Page - 1) * q.PageSize).Limit(q.PageSize).
+		Find(&res.Products).Error; err != nil {
+		return nil, fmt.Errorf("查询店铺商品失败: %w", err)
+	}
+	for i := range res.Products {
+		res.Products[i].FillPriceRange()
+	}
+	return res, nil
+}
+
+// storefrontOrder 店铺商品排序方式，未知值按最新上架排序
+func storefrontOrder(sort string) string {
+	switch sort {
+	case "sales":
+		return "sales DESC, product_id DESC"
+	case "price_asc":
+		return "price ASC, product_id DESC"
+	case "price_desc":
+		return "price DESC, product_id DESC"
+	default:
+		return "publish_date DESC, product_id DESC"
+	}
+}
+
+// GetSummary 获取卖家自己的商品状态统计
+func (s *SellerService) GetSummary(sellerID uint) (*SellerSummary, error) {
+	var summary SellerSummary
+	if err := s.DB.Unscoped().Model(&db.SpecialProduct{}).
+		Select(`COALESCE(SUM(deleted_at IS NULL), 0) AS total,
+			COALESCE(SUM(deleted_at IS NULL AND is_active = 1 AND is_violation = 0), 0) AS active,
+			COALESCE(SUM(deleted_at IS NULL AND is_active = 0 AND is_violation = 0), 0) AS inactive,
+			COALESCE(SUM(deleted_at IS NULL AND is_violation = 1), 0) AS violation,
+			COALESCE(SUM(deleted_at IS NOT NULL), 0) AS deleted,
+			COALESCE(SUM(sales), 0) AS total_sales`).
+		Where("user_id = ?", sellerID).
+		Scan(&summary).Error; err != nil {
+		return nil, fmt.Errorf("统计商品失败: %w", err)
+	}
+
+	if err := s.DB.Table("product_skus AS k").
+		Joins("JOIN special_products sp ON sp.product_id = k.product_id").
+		Where("sp.user_id = ? AND sp.deleted_at IS NULL AND sp.is_active = ? AND k.is_active = ? AND k.stock = 0",
+			sellerID, true, true).
+		Count(&summary.SoldOutSKUs).Error; err != nil {
+		return nil, fmt.Errorf("统计规格库存失败: %w", err)
+	}
+
+	if err := s.DB.Table("reviews AS r").
+		Joins("JOIN special_products sp ON sp.product_id = r.product_id").
+		Where("sp.user_id = ? AND (r.seller_reply IS NULL OR r.seller_reply = '')", sellerID).
+		Count(&summary.UnrepliedReviews).Error; err != nil {
+		return nil, fmt.Errorf("统计评价失败: %w", err)
+	}
+	return &summary, nil
+}
@@ -0,0 +1,81 @@
+package sensitive
+
+// automaton 基于 rune 的 Aho-Corasick 多模式匹配自动机，一次扫描即可找出文本中的所有敏感词
+type automaton struct {
+	nodes []acNode
+}
+
+type acNode struct {
+	next    map[rune]int
+	fail    int
+	outputs []int // 在该节点结束的模式下标，已合并失败链上的输出
+}
+
+// newAutomaton 根据模式串构建自动机，patterns 的下标即匹配结果中的模式编号
+func newAutomaton(patterns []string) *automaton {
+	a := &automaton{nodes: []acNode{{next: map[rune]int{}}}}
+
+	// 构建字典树
+	for i, p := range patterns {
+		cur := 0
+		for _, r := range p {
+			nxt, ok := a.nodes[cur].next[r]
+			if !ok {
+				a.nodes = append(a.nodes, acNode{next: map[rune]int{}})
+				nxt = len(a.nodes) - 1
+				a.nodes[cur].next[r] = nxt
+			}
+			cur = nxt
+		}
+		if cur != 0 {
+			a.nodes[cur].outputs = append(a.nodes[cur].outputs, i)
+		}
+	}
+
+	// 按层构建失败指针，根节点的子节点失败指针指向根
+	queue := make([]int, 0, len(a.nodes))
+	for _, child := range a.nodes[0].next {
+		queue = append(queue, child)
+	}
+	for len(queue) > 0 {
+		u := queue[0]
+		queue = queue[1:]
+		for r, v := range a.nodes[u].next {
+			f := a.nodes[u].fail
+			for {
+				if w, ok := a.nodes[f].next[r]; ok {
+					a.nodes[v].fail = w
+					break
+				}
+				if f == 0 {
+					break
+				}
+				f = a.nodes[f].fail
+			}
+			if inherited := a.nodes[a.nodes[v].fail].outputs; len(inherited) > 0 {
+				a.nodes[v].outputs = append(a.nodes[v].outputs, inherited...)
+			}
+			queue = append(queue, v)
+		}
+	}
+	return a
+}
+
+// match 扫描文本，对每个命中的模式调用 fn（同一模式可能多次命中）
+func (a *automaton) match(text []rune, fn func(pattern int)) {
+	cur := 0
+	for _, r := range text {
+		for cur != 0 {
+			if _, ok := a.nodes[cur].next[r]; ok {
+				break
+			}
+			cur = a.nodes[cur].fail
+		}
+		if nxt, ok := a.nodes[cur].next[r]; ok {
+			cur = nxt
+		}
+		for _, p := range a.nodes[cur].outputs {
+			fn(p)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package sensitive
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestAutomatonMatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		text     string
+		want     []int // 命中的模式下标，按命中次数重复出现
+	}{
+		{"无命中", []string{"abc"}, "xyz", nil},
+		{"单个命中", []string{"abc"}, "xxabcxx", []int{0}},
+		{"重复命中", []string{"ab"}, "abab", []int{0, 0}},
+		{"经典重叠", []string{"he", "she", "his", "hers"}, "ushers", []int{0, 1, 3}},
+		{"后缀模式通过失败链输出", []string{"abcd", "bc"}, "abcx", []int{1}},
+		{"失败后回退继续匹配", []string{"aab"}, "aaab", []int{0}},
+		{"中文重叠", []string{"发票", "代开发票", "开发"}, "代开发票", []int{0, 1, 2}},
+		{"空模式不产生命中", []string{"", "a"}, "a", []int{1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []int
+			newAutomaton(tt.patterns).match([]rune(tt.text), func(p int) {
+				got = append(got, p)
+			})
+			sort.Ints(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("match(%q) = %v, want %v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
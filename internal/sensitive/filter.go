@@ -0,0 +1,174 @@
+package sensitive
+
+import (
+	"os"
+	"regexp"
+	"strings"
+
+	"szu_market/internal/db"
+)
+
+// 处理级别，reject 直接拒绝发布，flag 允许保存但标记违规等待人工审核
+const (
+	SeverityReject = "reject"
+	SeverityFlag   = "flag"
+)
+
+// 命中类型
+const (
+	KindWord    = "word"    // 规范化后直接命中敏感词
+	KindPinyin  = "pinyin"  // 拼音或同音字命中
+	KindContact = "contact" // 疑似站外联系方式
+)
+
+// minPinyinLength 参与拼音匹配的敏感词拼音最短长度，过短的拼音容易误伤正常文本
+const minPinyinLength = 4
+
+// Hit 单个命中结果
+type Hit struct {
+	Word     string `json:"word"`
+	Kind     string `json:"kind"`
+	Severity string `json:"severity"`
+	Category string `json:"category,omitempty"`
+}
+
+// Result 文本检查结果，Severity 为所有命中中最严重的级别，未命中时为空
+type Result struct {
+	Severity string `json:"severity"`
+	Hits     []Hit  `json:"hits"`
+}
+
+// Rejected 是否应拒绝发布
+func (r *Result) Rejected() bool {
+	return r.Severity == SeverityReject
+}
+
+// Flagged 是否应标记为违规等待审核
+func (r *Result) Flagged() bool {
+	return r.Severity == SeverityFlag
+}
+
+// Words 命中的敏感词列表，用于提示用户
+func (r *Result) Words() string {
+	words := make([]string, 0, len(r.Hits))
+	for _, h := range r.Hits {
+		words = append(words, h.Word)
+	}
+	return strings.Join(words, "、")
+}
+
+// add 记录命中，同一敏感词已直接命中时不再重复记录拼音命中
+func (r *Result) add(hit Hit) {
+	for _, h := range r.Hits {
+		if h.Word == hit.Word && (h.Kind == hit.Kind || hit.Kind == KindPinyin) {
+			return
+		}
+	}
+	r.Hits = append(r.Hits, hit)
+	if hit.Severity == SeverityReject || r.Severity == "" {
+		r.Severity = hit.Severity
+	}
+}
+
+// contactRule 联系方式识别规则，在 contactForm 规范化后的文本上匹配
+type contactRule struct {
+	name    string
+	pattern *regexp.Regexp
+}
+
+var contactRules = []contactRule{
+	{"手机号", regexp.MustCompile(`1[3-9]\d{9}`)},
+	{"QQ号", regexp.MustCompile(`(qq|扣扣|企鹅|球球)号?码?\d{5,11}`)},
+	{"微信号", regexp.MustCompile(`(微信|威信|薇信|维信|v信|weixin|vx|wx)号?[a-z][a-z0-9]{5,19}`)},
+}
+
+// emailPattern 邮箱需要保留 @ 和 . 等符号，只在统一字形后的文本上匹配
+var emailPattern = regexp.MustCompile(`[a-z0-9._%+\-]+@[a-z0-9.\-]+\.[a-z]{2,}`)
+
+// contactSeverity 联系方式命中后的处理级别，可通过环境变量 SENSITIVE_CONTACT_SEVERITY 配置为 flag
+func contactSeverity() string {
+	if os.Getenv("SENSITIVE_CONTACT_SEVERITY") == SeverityFlag {
+		return SeverityFlag
+	}
+	return SeverityReject
+}
+
+// Filter 由词库构建的敏感内容过滤器，构建后只读，可并发使用
+type Filter struct {
+	words       []db.SensitiveWord
+	exact       *automaton
+	pinyin      *automaton
+	pinyinWords []int // pinyin 自动机中模式编号对应的 words 下标
+}
+
+// NewFilter 根据词库构建过滤器
+func NewFilter(words []db.SensitiveWord) *Filter {
+	f := &Filter{words: words}
+
+	exact := make([]string, len(words))
+	var pinyinPatterns []string
+	for i, w := range words {
+		norm := normalize(w.Word)
+		exact[i] = string(norm)
+		if !hasHan(w.Word) {
+			continue
+		}
+		if py := toPinyin(norm); len(py) >= minPinyinLength {
+			pinyinPatterns = append(pinyinPatterns, string(py))
+			f.pinyinWords = append(f.pinyinWords, i)
+		}
+	}
+	f.exact = newAutomaton(exact)
+	f.pinyin = newAutomaton(pinyinPatterns)
+	return f
+}
+
+// Check 检查多段文本（如标题、描述），每段单独匹配，避免跨字段拼接误报
+func (f *Filter) Check(texts ...string) *Result {
+	result := &Result{Hits: []Hit{}}
+	for _, text := range texts {
+		if text == "" {
+			continue
+		}
+		norm := normalize(text)
+
+		f.exact.match(norm, func(p int) {
+			w := f.words[p]
+			result.add(Hit{Word: w.Word, Kind: KindWord, Severity: w.Severity, Category: w.Category})
+		})
+
+		// 拼音命中存在同音误判的可能，最高只标记违规交给人工审核
+		f.pinyin.match(toPinyin(norm), func(p int) {
+			w := f.words[f.pinyinWords[p]]
+			result.add(Hit{Word: w.Word, Kind: KindPinyin, Severity: SeverityFlag, Category: w.Category})
+		})
+
+		f.checkContacts(text, norm, result)
+	}
+	return result
+}
+
+// checkContacts 识别手机号、QQ、微信、邮箱等站外联系方式
+func (f *Filter) checkContacts(text string, norm []rune, result *Result) {
+	severity := contactSeverity()
+	form := contactForm(norm)
+	for _, rule := range contactRules {
+		for _, loc := range rule.pattern.FindAllStringIndex(form, -1) {
+			// 手机号前后不能紧挨其他数字，避免把长编号误判为手机号
+			if rule.name == "手机号" && (isDigitAt(form, loc[0]-1) || isDigitAt(form, loc[1])) {
+				continue
+			}
+			result.add(Hit{Word: rule.name, Kind: KindContact, Severity: severity})
+			break
+		}
+	}
+
+	folded := strings.Map(foldRune, text)
+	if emailPattern.MatchString(folded) {
+		result.add(Hit{Word: "邮箱", Kind: KindContact, Severity: severity})
+	}
+}
+
+func isDigitAt(s string, i int) bool {
+	return i >= 0 && i < len(s) && s[i] >= '0' && s[i] <= '9'
+}
@@ -0,0 +1,75 @@
+package sensitive
+
+import (
+	"testing"
+
+	"szu_market/internal/db"
+)
+
+func testFilter() *Filter {
+	return NewFilter([]db.SensitiveWord{
+		{Word: "代开发票", Severity: SeverityReject, Category: "违法"},
+		{Word: "发票", Severity: SeverityFlag},
+		{Word: "赌博", Severity: SeverityReject},
+		{Word: "VIP", Severity: SeverityFlag},
+	})
+}
+
+func TestFilterCheck(t *testing.T) {
+	tests := []struct {
+		name     string
+		texts    []string
+		severity string
+		words    []string // 期望命中的词（不要求顺序）
+	}{
+		{"正常文本", []string{"全新自行车，九成新"}, "", nil},
+		{"重叠词全部命中且取最严重级别", []string{"可代开发票"}, SeverityReject, []string{"代开发票", "发票"}},
+		{"全角与大小写", []string{"ｖｉｐ会员"}, SeverityFlag, []string{"VIP"}},
+		{"空格和符号插入规避", []string{"代 开.发*票"}, SeverityReject, []string{"代开发票", "发票"}},
+		{"繁体规避", []string{"代開發票"}, SeverityReject, []string{"代开发票", "发票"}},
+		{"拼音规避最高只标记", []string{"du bo 平台"}, SeverityFlag, []string{"赌博"}},
+		{"同音字规避", []string{"读博网站"}, SeverityFlag, []string{"赌博"}},
+		{"多段文本分别检查", []string{"代开", "发票"}, SeverityFlag, []string{"发票"}},
+		{"变形手机号", []string{"联系 一三八 零零一三 八零零零"}, SeverityReject, []string{"手机号"}},
+		{"圈号数字手机号", []string{"①③⑧⓪⓪①③⑧⓪⓪⓪"}, SeverityReject, []string{"手机号"}},
+		{"长编号不当作手机号", []string{"订单号 9913800138000123"}, "", nil},
+		{"微信号", []string{"加 V信: abc12345"}, SeverityReject, []string{"微信号"}},
+		{"邮箱", []string{"发到 Foo@Example.COM"}, SeverityReject, []string{"邮箱"}},
+	}
+	f := testFilter()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := f.Check(tt.texts...)
+			if res.Severity != tt.severity {
+				t.Errorf("Severity = %q, want %q (hits %+v)", res.Severity, tt.severity, res.Hits)
+			}
+			got := make(map[string]bool, len(res.Hits))
+			for _, h := range res.Hits {
+				got[h.Word] = true
+			}
+			if len(got) != len(tt.words) {
+				t.Errorf("hits = %+v, want words %v", res.Hits, tt.words)
+			}
+			for _, w := range tt.words {
+				if !got[w] {
+					t.Errorf("missing hit %q in %+v", w, res.Hits)
+				}
+			}
+		})
+	}
+}
+
+func TestFilterPinyinDoesNotDuplicateDirectHit(t *testing.T) {
+	res := testFilter().Check("赌博")
+	if len(res.Hits) != 1 || res.Hits[0].Kind != KindWord {
+		t.Errorf("hits = %+v, want a single word hit", res.Hits)
+	}
+}
+
+func TestContactSeverityFromEnv(t *testing.T) {
+	t.Setenv("SENSITIVE_CONTACT_SEVERITY", SeverityFlag)
+	res := testFilter().Check("电话 13800138000")
+	if !res.Flagged() {
+		t.Errorf("Severity = %q, want %q", res.Severity, SeverityFlag)
+	}
+}
@@ -0,0 +1,115 @@
+package sensitive
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// SensitiveHandler 敏感词库管理处理程序
+type SensitiveHandler struct {
+	Service *SensitiveService
+}
+
+// NewSensitiveHandler 创建新的敏感词处理程序
+func NewSensitiveHandler(service *SensitiveService) *SensitiveHandler {
+	return &SensitiveHandler{Service: service}
+}
+
+// checkRequest 文本检查请求
+type checkRequest struct {
+	Texts []string `json:"texts"`
+}
+
+// ListWords 查询词库
+func (h *SensitiveHandler) ListWords(c *gin.Context) {
+	words, err := h.Service.ListWords(c.Query("keyword"), c.Query("severity"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, words)
+}
+
+// AddWord 新增敏感词
+func (h *SensitiveHandler) AddWord(c *gin.Context) {
+	var input WordInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求数据无效"})
+		return
+	}
+
+	word, err := h.Service.AddWord(&input)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"message": "敏感词添加成功", "word": word})
+}
+
+// UpdateWord 修改敏感词
+func (h *SensitiveHandler) UpdateWord(c *gin.Context) {
+	wordID, err := strconv.ParseUint(c.Param("word_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "敏感词 ID 无效"})
+		return
+	}
+
+	var input WordInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求数据无效"})
+		return
+	}
+
+	word, err := h.Service.UpdateWord(uint(wordID), &input)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "敏感词修改成功", "word": word})
+}
+
+// DeleteWord 删除敏感词
+func (h *SensitiveHandler) DeleteWord(c *gin.Context) {
+	wordID, err := strconv.ParseUint(c.Param("word_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "敏感词 ID 无效"})
+		return
+	}
+
+	if err := h.Service.DeleteWord(uint(wordID)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "敏感词已删除"})
+}
+
+// CheckText 用当前词库检查文本，便于管理员调试词库
+func (h *SensitiveHandler) CheckText(c *gin.Context) {
+	var req checkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求数据无效"})
+		return
+	}
+
+	result, err := Check(h.Service.DB, req.Texts...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// RegisterSensitiveRoutes 注册敏感词库管理路由
+func RegisterSensitiveRoutes(r *gin.Engine, db *gorm.DB) {
+	sensitiveService := NewSensitiveService(db)
+	sensitiveHandler := NewSensitiveHandler(sensitiveService)
+
+	r.GET("/admin/sensitive_words", sensitiveHandler.ListWords)
+	r.POST("/admin/sensitive_words", sensitiveHandler.AddWord)
+	r.POST("/admin/sensitive_words/check", sensitiveHandler.CheckText)
+	r.PUT("/admin/sensitive_words/:word_id", sensitiveHandler.UpdateWord)
+	r.DELETE("/admin/sensitive_words/:word_id", sensitiveHandler.DeleteWord)
+}
@@ -0,0 +1,124 @@
+package sensitive
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/mozillazg/go-pinyin"
+)
+
+// traditionalPairs 常见繁体字到简体字的映射（繁简成对排列），覆盖规避审核时常用的写法
+const traditionalPairs = "們们個个來来說说這这時时國国會会對对後后過过經经開开關关點点發发電电號号碼码話话聯联繫系係系" +
+	"帳账賬账錢钱買买賣卖貨货價价員员網网絡络頁页書书軟软體体槍枪彈弹藥药賭赌黃黄詐诈騙骗幣币銀银" +
+	"證证偽伪簽签辦办隱隐傳传銷销違违獎奖紅红贏赢轉转匯汇衛卫鐵铁線线約约務务復复複复團团飛飞機机" +
+	"場场車车門门問问間间聞闻訊讯設设計计認认識识讓让請请謝谢麼么裡里裏里為为無无與与專专業业東东" +
+	"級级絲丝純纯紙纸組组細细終终給给統统題题顏颜風风養养馬马魚鱼鳥鸟龍龙齒齿氣气漢汉湯汤滿满標标" +
+	"樣样權权歡欢禮礼禍祸視视覽览親亲觸触變变讀读賠赔贈赠購购財财貸贷資资質质賽赛趕赶達达" +
+	"運运還还進进遠远鄉乡醫医鎮镇長长陣阵陸陆陰阴陽阳隊队際际險险雜杂雞鸡雙双離离難难靈灵韓韩"
+
+// traditionalMap 繁体到简体的映射表
+var traditionalMap = func() map[rune]rune {
+	m := make(map[rune]rune)
+	runes := []rune(traditionalPairs)
+	for i := 0; i+1 < len(runes); i += 2 {
+		if runes[i] != runes[i+1] {
+			m[runes[i]] = runes[i+1]
+		}
+	}
+	return m
+}()
+
+// numeralMap 中文数字、圈号数字等到阿拉伯数字的映射，用于识别变形的联系方式
+var numeralMap = func() map[rune]rune {
+	m := make(map[rune]rune)
+	for digit, variants := range []string{
+		"零〇洞⓪", "一壹幺①⑴⒈㈠", "二贰两②⑵⒉㈡", "三叁③⑶⒊㈢", "四肆④⑷⒋㈣",
+		"五伍⑤⑸⒌㈤", "六陆⑥⑹⒍㈥", "七柒拐⑦⑺⒎㈦", "八捌⑧⑻⒏㈧", "九玖勾⑨⑼⒐㈨",
+	} {
+		for _, r := range variants {
+			m[r] = rune('0' + digit)
+		}
+	}
+	return m
+}()
+
+var pinyinArgs = pinyin.NewArgs()
+
+// foldRune 统一字符形态：全角转半角、大写转小写、繁体转简体
+func foldRune(r rune) rune {
+	switch {
+	case r == 0x3000:
+		r = ' '
+	case r >= 0xFF01 && r <= 0xFF5E:
+		r -= 0xFEE0
+	}
+	r = unicode.ToLower(r)
+	if s, ok := traditionalMap[r]; ok {
+		r = s
+	}
+	return r
+}
+
+// isSeparator 判断是否为插入在敏感词中间用来规避匹配的分隔字符
+// 圈号、括号数字不属于字母数字，但需保留给 contactForm 识别
+func isSeparator(r rune) bool {
+	if _, ok := numeralMap[r]; ok {
+		return false
+	}
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.Is(unicode.Han, r)
+}
+
+// normalize 规范化文本用于敏感词匹配：统一字形并去掉空格、标点、符号等分隔字符
+func normalize(text string) []rune {
+	result := make([]rune, 0, len(text))
+	for _, r := range text {
+		r = foldRune(r)
+		if isSeparator(r) {
+			continue
+		}
+		result = append(result, r)
+	}
+	return result
+}
+
+// normalizeWord 规范化词库中的敏感词
+func normalizeWord(word string) string {
+	return string(normalize(word))
+}
+
+// contactForm 在 normalize 的基础上把变形数字转为阿拉伯数字，用于识别联系方式
+func contactForm(norm []rune) string {
+	var b strings.Builder
+	for _, r := range norm {
+		if d, ok := numeralMap[r]; ok {
+			r = d
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// toPinyin 把规范化文本中的汉字转为无声调拼音，其他字符保持不变，用于识别拼音和同音字规避
+func toPinyin(norm []rune) []rune {
+	result := make([]rune, 0, len(norm)*3)
+	for _, r := range norm {
+		if unicode.Is(unicode.Han, r) {
+			if py := pinyin.SinglePinyin(r, pinyinArgs); len(py) > 0 {
+				result = append(result, []rune(py[0])...)
+				continue
+			}
+		}
+		result = append(result, r)
+	}
+	return result
+}
+
+// hasHan 判断文本是否包含汉字
+func hasHan(text string) bool {
+	for _, r := range text {
+		if unicode.Is(unicode.Han, r) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,59 @@
+package sensitive
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"大写转小写", "ABC", "abc"},
+		{"全角转半角", "ＡＢＣ１２３", "abc123"},
+		{"全角空格与标点", "代　开，发。票", "代开发票"},
+		{"去掉空格和符号", "代 开-发*票", "代开发票"},
+		{"繁体转简体", "發票", "发票"},
+		{"混合形态", "Ｖ 信：ＡＢＣ", "v信abc"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(normalize(tt.in)); got != tt.want {
+				t.Errorf("normalize(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestContactForm(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"一三八零零一三八零零零", "13800138000"},
+		{"壹叁捌", "138"},
+		{"①③⑧", "138"},
+		{"幺三八洞洞", "13800"},
+		{"abc", "abc"},
+	}
+	for _, tt := range tests {
+		if got := contactForm(normalize(tt.in)); got != tt.want {
+			t.Errorf("contactForm(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestToPinyin(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"发票", "fapiao"},
+		{"代开a1", "daikaia1"},
+		{"abc", "abc"},
+	}
+	for _, tt := range tests {
+		if got := string(toPinyin(normalize(tt.in))); got != tt.want {
+			t.Errorf("toPinyin(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package sensitive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"szu_market/internal/db"
+
+	"github.com/go-redis/redis/v8"
+	"gorm.io/gorm"
+)
+
+const (
+	versionKey    = "sensitive:ver"  // 词库版本号，任一实例修改词库后递增
+	versionCheck  = 30 * time.Second // 检查词库版本的最小间隔
+	maxWordLength = 50
+)
+
+// 进程内缓存的过滤器，词库版本变化时重建
+var (
+	filterMu      sync.Mutex
+	filterCache   *Filter
+	filterVersion string
+	checkedAt     time.Time
+)
+
+// Check 使用当前词库检查文本
+func Check(database *gorm.DB, texts ...string) (*Result, error) {
+	f, err := currentFilter(database)
+	if err != nil {
+		return nil, err
+	}
+	return f.Check(texts...), nil
+}
+
+// currentFilter 返回当前词库对应的过滤器，词库版本变化后重新加载
+func currentFilter(database *gorm.DB) (*Filter, error) {
+	filterMu.Lock()
+	defer filterMu.Unlock()
+
+	if filterCache != nil && time.Since(checkedAt) < versionCheck {
+		return filterCache, nil
+	}
+
+	ver, err := db.RDB.Get(context.Background(), versionKey).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		// Redis 不可用时继续使用已加载的词库
+		log.Printf("WARN: 读取敏感词库版本失败: %v", err)
+		if filterCache != nil {
+			return filterCache, nil
+		}
+	}
+	if filterCache != nil && ver == filterVersion {
+		checkedAt = time.Now()
+		return filterCache, nil
+	}
+
+	var words []db.SensitiveWord
+	if err := database.Find(&words).Error; err != nil {
+		return nil, fmt.Errorf("加载敏感词库失败: %w", err)
+	}
+	filterCache = NewFilter(words)
+	filterVersion = ver
+	checkedAt = time.Now()
+	return filterCache, nil
+}
+
+// bumpVersion 词库变更后递增版本号，并让本实例立即重新加载
+func bumpVersion() {
+	if err := db.RDB.Incr(context.Background(), versionKey).Err(); err != nil {
+		log.Printf("WARN: 更新敏感词库版本失败: %v", err)
+	}
+	filterMu.Lock()
+	filterCache = nil
+	filterMu.Unlock()
+}
+
+// SensitiveService 定义敏感词库管理服务
+type SensitiveService struct {
+	DB *gorm.DB
+}
+
+// NewSensitiveService 创建新的敏感词服务实例
+func NewSensitiveService(db *gorm.DB) *SensitiveService {
+	return &SensitiveService{DB: db}
+}
+
+// WordInput 新增或修改敏感词的输入参数
+type WordInput struct {
+	Word     string `json:"word"`
+	Severity string `json:"severity"`
+	Category string `json:"category"`
+}
+
+// ListWords 查询词库，keyword、severity 为空时不筛选
+func (s *SensitiveService) ListWords(keyword, severity string) ([]db.SensitiveWord, error) {
+	query := s.DB.Model(&db.SensitiveWord{})
+	if keyword != "" {
+		query = query.Where("word LIKE ?", "%"+normalizeWord(keyword)+"%")
+	}
+	if severity != "" {
+		query = query.Where("severity = ?", severity)
+	}
+
+	words := []db.SensitiveWord{}
+	if err := query.Order("word_id DESC").Find(&words).Error; err != nil {
+		return nil, fmt.Errorf("查询敏感词失败: %w", err)
+	}
+	return words, nil
+}
+
+// AddWord 新增敏感词，词语按规范化后的形式保存和去重
+func (s *SensitiveService) AddWord(input *WordInput) (*db.SensitiveWord, error) {
+	word := normalizeWord(input.Word)
+	if word == "" {
+		return nil, errors.New("敏感词不能为空")
+	}
+	if len([]rune(word)) > maxWordLength {
+		return nil, fmt.Errorf("敏感词不能超过 %d 个字", maxWordLength)
+	}
+	severity, err := validSeverity(input.Severity)
+	if err != nil {
+		return nil, err
+	}
+
+	var count int64
+	if err := s.DB.Model(&db.SensitiveWord{}).Where("word = ?", word).Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("数据库查询失败: %w", err)
+	}
+	if count > 0 {
+		return nil, errors.New("该敏感词已存在")
+	}
+
+	record := db.SensitiveWord{Word: word, Severity: severity, Category: input.Category}
+	if err := s.DB.Create(&record).Error; err != nil {
+		return nil, fmt.Errorf("添加敏感词失败: %w", err)
+	}
+	bumpVersion()
+	return &record, nil
+}
+
+// UpdateWord 修改敏感词的处理级别与分类
+func (s *SensitiveService) UpdateWord(wordID uint, input *WordInput) (*db.SensitiveWord, error) {
+	severity, err := validSeverity(input.Severity)
+	if err != nil {
+		return nil, err
+	}
+
+	var record db.SensitiveWord
+	if err := s.DB.First(&record, wordID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("敏感词不存在")
+		}
+		return nil, fmt.Errorf("数据库查询失败: %w", err)
+	}
+
+	if err := s.DB.Model(&record).Updates(map[string]interface{}{
+		"severity": severity,
+		"category": input.Category,
+	}).Error; err != nil {
+		return nil, fmt.Errorf("修改敏感词失败: %w", err)
+	}
+	bumpVersion()
+	return &record, nil
+}
+
+// DeleteWord 删除敏感词
+func (s *SensitiveService) DeleteWord(wordID uint) error {
+	result := s.DB.Delete(&db.SensitiveWord{}, wordID)
+	if result.Error != nil {
+		return fmt.Errorf("删除敏感词失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("敏感词不存在")
+	}
+	bumpVersion()
+	return nil
+}
+
+// validSeverity 校验处理级别，为空时默认拒绝发布
+func validSeverity(severity string) (string, error) {
+	switch severity {
+	case "":
+		return SeverityReject, nil
+	case SeverityReject, SeverityFlag:
+		return severity, nil
+	default:
+		return "", errors.New("处理级别只能是 reject 或 flag")
+	}
+}
@@ -0,0 +1,74 @@
+package trending
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// TrendingHandler 热门榜单处理程序
+type TrendingHandler struct {
+	Service *TrendingService
+}
+
+// NewTrendingHandler 创建新的热门榜单处理程序
+func NewTrendingHandler(service *TrendingService) *TrendingHandler {
+	return &TrendingHandler{Service: service}
+}
+
+// RecordView 记录商品浏览
+func (h *TrendingHandler) RecordView(c *gin.Context) {
+	productID, err := strconv.ParseUint(c.Param("product_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "商品 ID 无效"})
+		return
+	}
+
+	var input struct {
+		UserID uint `json:"user_id"`
+	}
+	_ = c.ShouldBindJSON(&input) // 未登录用户可不传请求体
+
+	if err := h.Service.RecordView(uint(productID), Viewer(c, input.UserID), c.ClientIP()); err != nil {
+		if errors.Is(err, ErrProductNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// GetTrending 获取热门商品榜单
+func (h *TrendingHandler) GetTrending(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+
+	products, err := h.Service.GetTrending(c.Query("category"), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, products)
+}
+
+// Viewer 返回浏览去重使用的访客标识：登录用户用用户ID，未登录用客户端IP
+// 校园网内大量用户共用出口IP，不能统一按IP去重；伪造 user_id 刷量由按IP的计数上限限制
+func Viewer(c *gin.Context, userID uint) string {
+	if userID != 0 {
+		return "u" + strconv.FormatUint(uint64(userID), 10)
+	}
+	return "ip" + c.ClientIP()
+}
+
+// RegisterTrendingRoutes 注册热门榜单路由
+func RegisterTrendingRoutes(r *gin.Engine, db *gorm.DB) {
+	trendingService := NewTrendingService(db)
+	trendingHandler := NewTrendingHandler(trendingService)
+
+	r.POST("/products/:product_id/view", trendingHandler.RecordView)
+	r.GET("/trending", trendingHandler.GetTrending)
+}
@@ -0,0 +1,310 @@
+package trending
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"time"
+
+	"szu_market/internal/cache"
+	"szu_market/internal/db"
+
+	"github.com/go-redis/redis/v8"
+	"gorm.io/gorm"
+)
+
+const (
+	viewDedupWindow = 30 * time.Minute // 同一访客在窗口内重复浏览只计一次
+	viewIPCap       = 300              // 同一IP在去重窗口内最多计入的浏览次数
+	viewKeepDays    = 8                // 每日浏览计数保留天数
+	scoreWindowDays = 7                // 参与热度计算的浏览/收藏天数
+	refreshInterval = 10 * time.Minute // 榜单刷新周期
+	refreshLockTTL  = 5 * time.Minute  // 刷新锁过期时间，避免多实例重复刷新
+	rankingTTL      = 2 * time.Hour    // 榜单过期时间，调度停止后自然失效
+
+	signalHalfLifeDays = 2.0  // 浏览、收藏信号的半衰期（天）
+	salesHalfLifeDays  = 14.0 // 销量信号按上架时间衰减的半衰期（天）
+
+	viewWeight     = 1.0
+	favoriteWeight = 3.0
+	salesWeight    = 5.0
+
+	defaultLimit = 20
+	maxLimit     = 100
+)
+
+// TrendingService 定义热门榜单服务
+type TrendingService struct {
+	DB *gorm.DB
+}
+
+// NewTrendingService 创建新的热门榜单服务实例
+func NewTrendingService(db *gorm.DB) *TrendingService {
+	return &TrendingService{DB: db}
+}
+
+// TrendingProduct 热门商品响应结构
+type TrendingProduct struct {
+	db.SpecialProduct
+	Score float64 `json:"trending_score"`
+}
+
+func viewsKey(day time.Time) string {
+	return "views:" + day.Format("20060102")
+}
+
+func rankingKey(category string) string {
+	if category == "" {
+		return "trending:all"
+	}
+	return "trending:cat:" + category
+}
+
+// ErrProductNotFound 浏览的商品不存在、已删除或已下架
+var ErrProductNotFound = errors.New("商品不存在")
+
+// RecordView 记录一次商品浏览，viewer 为访客标识（见 Viewer），窗口内去重
+// 只统计存在且在售的商品，避免无效商品ID写入浏览计数；同一IP在窗口内超过上限的浏览不再计入
+func (s *TrendingService) RecordView(productID uint, viewer, clientIP string) error {
+	if productID == 0 || viewer == "" {
+		return nil
+	}
+	product, err := cache.GetProduct(s.DB, productID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrProductNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("查询商品失败: %w", err)
+	}
+	if product.DeletedAt.Valid || !product.IsActive || product.IsViolation {
+		return ErrProductNotFound
+	}
+	ctx := context.Background()
+
+	dedupKey := fmt.Sprintf("view:dedup:%d:%s", productID, viewer)
+	first, err := db.RDB.SetNX(ctx, dedupKey, 1, viewDedupWindow).Result()
+	if err != nil {
+		return fmt.Errorf("记录浏览失败: %w", err)
+	}
+	if !first {
+		return nil
+	}
+
+	ipKey := "view:ip:" + clientIP
+	count, err := db.RDB.Incr(ctx, ipKey).Result()
+	if err != nil {
+		return fmt.Errorf("记录浏览失败: %w", err)
+	}
+	if count == 1 {
+		db.RDB.Expire(ctx, ipKey, viewDedupWindow)
+	}
+	if count > viewIPCap {
+		return nil
+	}
+
+	key := viewsKey(time.Now())
+	pipe := db.RDB.Pipeline()
+	pipe.HIncrBy(ctx, key, strconv.FormatUint(uint64(productID), 10), 1)
+	pipe.Expire(ctx, key, viewKeepDays*24*time.Hour)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("记录浏览失败: %w", err)
+	}
+	return nil
+}
+
+// StartScheduler 启动定时刷新热门榜单（后台运行）
+func (s *TrendingService) StartScheduler() {
+	ticker := time.NewTicker(refreshInterval)
+	defer ticker.Stop()
+
+	for {
+		s.refreshWithLock()
+		<-ticker.C
+	}
+}
+
+// refreshWithLock 获取分布式锁后刷新，未抢到锁说明其他实例正在刷新
+func (s *TrendingService) refreshWithLock() {
+	unlock, ok, err := db.TryLock(context.Background(), "trending:refresh:lock", refreshLockTTL)
+	if err != nil {
+		log.Printf("WARN: 获取热门榜单刷新锁失败: %v", err)
+		return
+	}
+	if !ok {
+		return
+	}
+	defer unlock()
+
+	start := time.Now()
+	if err := s.Refresh(); err != nil {
+		log.Printf("热门榜单刷新失败: %v", err)
+		return
+	}
+	log.Printf("热门榜单刷新完成，耗时 %v", time.Since(start))
+}
+
+// Refresh 重新计算所有商品的热度分并写入 Redis 有序集合
+func (s *TrendingService) Refresh() error {
+	ctx := context.Background()
+	now := time.Now()
+
+	var products []db.SpecialProduct
+	if err := s.DB.Select("product_id", "category", "sales", "publish_date").
+		Where("is_active = ? AND is_violation = ?", true, false).
+		Find(&products).Error; err != nil {
+		return fmt.Errorf("查询商品失败: %w", err)
+	}
+
+	views, err := s.decayedViews(ctx, now)
+	if err != nil {
+		return err
+	}
+	favorites, err := s.decayedFavorites(now)
+	if err != nil {
+		return err
+	}
+
+	rankings := make(map[string][]*redis.Z)
+	for _, p := range products {
+		ageDays := now.Sub(p.PublishDate).Hours() / 24
+		sales := float64(p.Sales) * decay(ageDays, salesHalfLifeDays)
+		score := viewWeight*views[p.ProductID] + favoriteWeight*favorites[p.ProductID] + salesWeight*sales
+		if score <= 0 {
+			continue
+		}
+		member := &redis.Z{Score: score, Member: p.ProductID}
+		rankings[""] = append(rankings[""], member)
+		rankings[p.Category] = append(rankings[p.Category], member)
+	}
+
+	// 先写入临时键再 RENAME，读取方不会看到写了一半的榜单
+	pipe := db.RDB.Pipeline()
+	for category, members := range rankings {
+		key := rankingKey(category)
+		tmpKey := key + ":tmp"
+		pipe.Del(ctx, tmpKey)
+		pipe.ZAdd(ctx, tmpKey, members...)
+		pipe.Rename(ctx, tmpKey, key)
+		pipe.Expire(ctx, key, rankingTTL)
+	}
+	pipe.Set(ctx, "trending:refreshed_at", now.Unix(), 2*refreshInterval)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("写入热门榜单失败: %w", err)
+	}
+	return nil
+}
+
+// decayedViews 汇总最近几天的浏览量，越早的浏览权重越低
+func (s *TrendingService) decayedViews(ctx context.Context, now time.Time) (map[uint]float64, error) {
+	result := make(map[uint]float64)
+	for d := 0; d < scoreWindowDays; d++ {
+		counts, err := db.RDB.HGetAll(ctx, viewsKey(now.AddDate(0, 0, -d))).Result()
+		if err != nil {
+			return nil, fmt.Errorf("读取浏览量失败: %w", err)
+		}
+		weight := decay(float64(d), signalHalfLifeDays)
+		for pidStr, countStr := range counts {
+			pid, err := strconv.ParseUint(pidStr, 10, 32)
+			if err != nil {
+				continue
+			}
+			count, _ := strconv.ParseFloat(countStr, 64)
+			result[uint(pid)] += count * weight
+		}
+	}
+	return result, nil
+}
+
+// decayedFavorites 汇总最近几天的收藏数，越早的收藏权重越低
+func (s *TrendingService) decayedFavorites(now time.Time) (map[uint]float64, error) {
+	var rows []struct {
+		ProductID uint
+		Days      int
+		Count     int64
+	}
+	if err := s.DB.Model(&db.Favorite{}).
+		Select("product_id, DATEDIFF(?, favorite_time) AS days, COUNT(*) AS count", now).
+		Where("favorite_time >= ?", now.AddDate(0, 0, -scoreWindowDays)).
+		Group("product_id, days").
+		Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("统计收藏失败: %w", err)
+	}
+
+	result := make(map[uint]float64)
+	for _, row := range rows {
+		result[row.ProductID] += float64(row.Count) * decay(float64(row.Days), signalHalfLifeDays)
+	}
+	return result, nil
+}
+
+// decay 指数衰减系数
+func decay(ageDays, halfLife float64) float64 {
+	if ageDays < 0 {
+		ageDays = 0
+	}
+	return math.Pow(0.5, ageDays/halfLife)
+}
+
+// GetTrending 获取热门商品榜单，category 为空时返回全站榜单
+func (s *TrendingService) GetTrending(category string, limit int) ([]TrendingProduct, error) {
+	if limit <= 0 {
+		limit = defaultLimit
+	}
+	if limit > maxLimit {
+		limit = maxLimit
+	}
+	ctx := context.Background()
+	key := rankingKey(category)
+
+	// 榜单尚未生成（如服务刚启动），同步刷新一次
+	refreshed, err := db.RDB.Exists(ctx, "trending:refreshed_at").Result()
+	if err != nil {
+		return nil, fmt.Errorf("读取热门榜单失败: %w", err)
+	}
+	if refreshed == 0 {
+		s.refreshWithLock()
+	}
+
+	members, err := db.RDB.ZRevRangeWithScores(ctx, key, 0, int64(limit-1)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("读取热门榜单失败: %w", err)
+	}
+	if len(members) == 0 {
+		return []TrendingProduct{}, nil
+	}
+
+	var ids []uint
+	scores := make(map[uint]float64, len(members))
+	for _, m := range members {
+		pid, err := strconv.ParseUint(fmt.Sprint(m.Member), 10, 32)
+		if err != nil {
+			continue
+		}
+		ids = append(ids, uint(pid))
+		scores[uint(pid)] = m.Score
+	}
+
+	// 榜单刷新间隔内商品可能已下架，这里再过滤一次
+	var products []db.SpecialProduct
+	if err := s.DB.Preload("SKUs").
+		Where("product_id IN ? AND is_active = ? AND is_violation = ?", ids, true, false).
+		Find(&products).Error; err != nil {
+		return nil, fmt.Errorf("查询商品失败: %w", err)
+	}
+	productMap := make(map[uint]db.SpecialProduct, len(products))
+	for _, p := range products {
+		p.FillPriceRange()
+		productMap[p.ProductID] = p
+	}
+
+	result := make([]TrendingProduct, 0, len(ids))
+	for _, id := range ids {
+		if p, ok := productMap[id]; ok {
+			result = append(result, TrendingProduct{SpecialProduct: p, Score: scores[id]})
+		}
+	}
+	return result, nil
+}